@@ -0,0 +1,628 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"simple-web-app/internal"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Exit codes
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// usageError is returned by commands when they were called with invalid arguments
+type usageError struct {
+	message string
+}
+
+func (e usageError) Error() string {
+	return e.message
+}
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(args []string, stdout io.Writer, stderr io.Writer) error
+}
+
+func commands() []command {
+	return []command{
+		{"serve", "[-reset] [-seed empty|always|never]", "Migrate the database and start the web server (default)", serveCommand},
+		{"migrate", "up | down [N] | status | goto N", "Apply, roll back or inspect database migrations", migrateCommand},
+		{"seed", "[-if-empty]", "Load the sample releases and artists", seedCommand},
+		{"reset", "", "Delete the database and recreate it with no data", resetCommand},
+		{"reindex-fts", "", "Rebuild the releases_fts and artists_fts search indexes from the base tables", reindexFtsCommand},
+		{"import", "discogs|musicbrainz|csv [-batch-size N] [-columns MAP] [-dry-run] FILE...", "Import Discogs XML or MusicBrainz JSON dumps, or releases from CSV", importCommand},
+		{"scan", "[-batch-size N] DIR...", "Build releases from the tags of the MP3, FLAC and M4A files in music directories", scanCommand},
+		{"export", "[json|csv] [-o FILE] [-q QUERY] [-sort SORT]", "Write releases with their artists as JSON, or as CSV optionally filtered by a search", exportCommand},
+		{"config", "print", "Print the config loaded from the config file, environment and flags", configCommand},
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: simple-web-app <command> [config flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.summary)
+		if cmd.args != "" {
+			fmt.Fprintf(w, "  %-12s   %s %s\n", "", cmd.name, cmd.args)
+		}
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Every command accepts the config flags, run a command with -h to list them.")
+}
+
+// run executes the command named by args[0] and returns the process exit code
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) > 0 && (args[0] == "help" || args[0] == "-h" || args[0] == "--help") {
+		printUsage(stdout)
+		return exitOK
+	}
+
+	// Serve when no command is given so existing deployments keep working
+	name := "serve"
+	if len(args) > 0 && (len(args[0]) == 0 || args[0][0] != '-') {
+		name, args = args[0], args[1:]
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name != name {
+			continue
+		}
+
+		err := cmd.run(args, stdout, stderr)
+		var usageErr usageError
+		switch {
+		case err == nil:
+			return exitOK
+		case errors.Is(err, flag.ErrHelp):
+			return exitOK
+		case errors.As(err, &usageErr):
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			fmt.Fprintf(stderr, "Usage: simple-web-app %s %s\n", cmd.name, cmd.args)
+			return exitUsage
+		default:
+			fmt.Fprintf(stderr, "Error: %s failed: %v\n", cmd.name, err)
+			return exitError
+		}
+	}
+
+	fmt.Fprintf(stderr, "Error: unknown command %q\n\n", name)
+	printUsage(stderr)
+	return exitUsage
+}
+
+// newFlagSet creates a flag set with the config flags whose parse errors are
+// reported as usage errors
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	internal.RegisterConfigFlags(flags)
+	return flags
+}
+
+// parseFlags parses args, allowing flags before and after positional arguments,
+// and loads the config from the config file, environment and flags. The
+// positional arguments are returned.
+func parseFlags(flags *flag.FlagSet, args []string) (internal.Config, []string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return internal.Config{}, nil, err
+			}
+			return internal.Config{}, nil, usageError{err.Error()}
+		}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional, args = append(positional, args[0]), args[1:]
+	}
+
+	config, err := internal.LoadConfig(flags, os.Getenv)
+	return config, positional, err
+}
+
+// withDB opens the configured database for the duration of fn
+func withDB(config internal.Config, fn func(db *sql.DB) error) error {
+	db, err := internal.InitDB(config.DBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return fn(db)
+}
+
+func serveCommand(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("serve", stderr)
+	reset := flags.Bool("reset", false, "Delete the database and recreate it before starting")
+	seed := flags.String("seed", internal.SeedEmpty, "When to load sample data: empty, always or never")
+	config, args, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return usageError{"serve takes no arguments"}
+	}
+
+	if !internal.IsValidSeedMode(*seed) {
+		return usageError{fmt.Sprintf("invalid -seed %q: must be empty, always or never", *seed)}
+	}
+
+	// Only throw away existing data when an operator asks for it
+	if *reset {
+		if err := internal.ResetDb(config.DBPath); err != nil {
+			return err
+		}
+	}
+
+	return withDB(config, func(db *sql.DB) error {
+		if err := internal.RunMigrations(db, config.MigrationsDir); err != nil {
+			return err
+		}
+
+		// Edits made while an import has the sync triggers paused are caught
+		// up by its final reindex, but an import killed before then would
+		// leave search silently out of date
+		recovered, err := internal.RecoverFtsSync(db)
+		if err != nil {
+			return err
+		}
+		if recovered {
+			log.Println("Warning: search index sync was left paused by an interrupted import or scan, rebuilt the search indexes")
+		}
+
+		seeded, err := internal.SeedIfNeeded(db, *seed)
+		if err != nil {
+			return fmt.Errorf("failed to seed db: %w", err)
+		}
+		if !seeded {
+			log.Println("Skipped seeding, using existing data")
+		}
+
+		// Initialize Echo
+		e := echo.New()
+
+		// Load templates
+		e.Renderer = &internal.Template{TemplateDir: config.TemplateDir}
+
+		internal.SetupRoutes(e, db, config)
+
+		// Start server
+		if err := e.Start(config.Address()); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	})
+}
+
+func migrateCommand(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("migrate", stderr)
+	config, args, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		return usageError{"missing migrate subcommand"}
+	}
+
+	dir := config.MigrationsDir
+	subcommand, args := args[0], args[1:]
+	return withDB(config, func(db *sql.DB) error {
+		switch subcommand {
+		case "up":
+			if len(args) != 0 {
+				return usageError{"migrate up takes no arguments"}
+			}
+			if err := internal.RunMigrations(db, dir); err != nil {
+				return err
+			}
+
+		case "down":
+			steps := 1
+			if len(args) > 1 {
+				return usageError{"migrate down takes at most one argument"}
+			}
+			if len(args) == 1 {
+				steps, err = strconv.Atoi(args[0])
+				if err != nil || steps < 1 {
+					return usageError{fmt.Sprintf("invalid number of steps %q", args[0])}
+				}
+			}
+			if err := internal.MigrateDown(db, dir, steps); err != nil {
+				return err
+			}
+
+		case "goto":
+			if len(args) != 1 {
+				return usageError{"migrate goto takes a version"}
+			}
+			version, err := strconv.ParseUint(args[0], 10, 0)
+			if err != nil || version < 1 {
+				return usageError{fmt.Sprintf("invalid version %q", args[0])}
+			}
+			if err := internal.MigrateTo(db, dir, uint(version)); err != nil {
+				return err
+			}
+
+		case "status":
+			if len(args) != 0 {
+				return usageError{"migrate status takes no arguments"}
+			}
+
+		default:
+			return usageError{fmt.Sprintf("unknown migrate subcommand %q", subcommand)}
+		}
+
+		status, err := internal.GetMigrationStatus(db, dir)
+		if err != nil {
+			return err
+		}
+		printMigrationStatus(stdout, status)
+		return nil
+	})
+}
+
+func printMigrationStatus(w io.Writer, status internal.MigrationStatus) {
+	state := "clean"
+	if status.Dirty {
+		state = "dirty, fix the database and run migrate goto to recover"
+	}
+
+	if status.Version == 0 {
+		fmt.Fprintf(w, "No migrations applied (latest is %d)\n", status.Latest)
+		return
+	}
+
+	fmt.Fprintf(w, "Database is at version %d of %d (%s)\n", status.Version, status.Latest, state)
+	if !status.Dirty && status.Version < status.Latest {
+		fmt.Fprintf(w, "%d migration(s) pending, run migrate up to apply them\n", status.Latest-status.Version)
+	}
+}
+
+func seedCommand(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("seed", stderr)
+	ifEmpty := flags.Bool("if-empty", false, "Only seed when the database has no releases or artists")
+	config, args, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return usageError{"seed takes no arguments"}
+	}
+
+	mode := internal.SeedAlways
+	if *ifEmpty {
+		mode = internal.SeedEmpty
+	}
+
+	return withDB(config, func(db *sql.DB) error {
+		seeded, err := internal.SeedIfNeeded(db, mode)
+		if err != nil {
+			return err
+		}
+
+		if seeded {
+			fmt.Fprintln(stdout, "Seeded the database with sample data")
+		} else {
+			fmt.Fprintln(stdout, "Database already has data, nothing seeded")
+		}
+		return nil
+	})
+}
+
+func resetCommand(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("reset", stderr)
+	config, args, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return usageError{"reset takes no arguments"}
+	}
+
+	if err := internal.ResetDb(config.DBPath); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Reset %s, run migrate up to recreate the tables\n", config.DBPath)
+	return nil
+}
+
+func reindexFtsCommand(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("reindex-fts", stderr)
+	config, args, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return usageError{"reindex-fts takes no arguments"}
+	}
+
+	return withDB(config, func(db *sql.DB) error {
+		if err := internal.ReindexFts(db); err != nil {
+			return err
+		}
+
+		fmt.Fprintln(stdout, "Rebuilt releases_fts and artists_fts")
+		return nil
+	})
+}
+
+// importOptions are the flags of the import command
+type importOptions struct {
+	batchSize int
+	columns   internal.CSVColumns
+	dryRun    bool
+}
+
+// importFunc imports a file, reporting progress to progress, and returns a summary of what it imported
+type importFunc func(db *sql.DB, file *os.File, options importOptions, stdout io.Writer, progress io.Writer) (string, error)
+
+// importers are the sources of the import command
+var importers = map[string]importFunc{
+	"discogs": func(db *sql.DB, file *os.File, options importOptions, stdout io.Writer, progress io.Writer) (string, error) {
+		stats, err := internal.ImportDiscogs(db, file, options.batchSize, progress)
+		return stats.String(), err
+	},
+	"musicbrainz": func(db *sql.DB, file *os.File, options importOptions, stdout io.Writer, progress io.Writer) (string, error) {
+		checkpoint, err := dumpCheckpoint(file)
+		if err != nil {
+			return "", err
+		}
+		stats, err := internal.ImportMusicBrainz(db, file, checkpoint, options.batchSize, progress)
+		return stats.String(), err
+	},
+	"csv": func(db *sql.DB, file *os.File, options importOptions, stdout io.Writer, progress io.Writer) (string, error) {
+		report, err := internal.ImportCSV(db, file, options.columns, options.dryRun)
+		if err != nil {
+			return "", err
+		}
+
+		for _, rowErr := range report.Errors {
+			fmt.Fprintf(stdout, "%s: %s\n", file.Name(), rowErr)
+		}
+		summary := fmt.Sprintf("created %d releases, updated %d, created %d artists, rejected %d rows",
+			report.Created, report.Updated, report.ArtistsCreated, len(report.Errors))
+		if options.dryRun {
+			summary = "dry run, nothing written: " + summary
+		}
+		if len(report.Errors) > 0 {
+			return "", fmt.Errorf("%s", summary)
+		}
+		return summary, nil
+	},
+}
+
+func importCommand(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("import", stderr)
+	batchSize := flags.Int("batch-size", internal.DefaultImportBatchSize, "Number of records imported per transaction")
+	columns := flags.String("columns", "", "CSV columns of release fields, like name=Album,year=Released,artists=Artist")
+	dryRun := flags.Bool("dry-run", false, "Validate a CSV file and report rejected rows without importing it")
+	config, args, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		return usageError{"missing import source"}
+	}
+	if *batchSize < 1 {
+		return usageError{fmt.Sprintf("invalid -batch-size %d", *batchSize)}
+	}
+
+	source, paths := args[0], args[1:]
+	importer, ok := importers[source]
+	if !ok {
+		return usageError{fmt.Sprintf("unknown import source %q", source)}
+	}
+	if len(paths) == 0 {
+		return usageError{fmt.Sprintf("import %s takes at least one file", source)}
+	}
+	if source != "csv" && (*columns != "" || *dryRun) {
+		return usageError{"-columns and -dry-run only apply to import csv"}
+	}
+
+	options := importOptions{batchSize: *batchSize, dryRun: *dryRun}
+	options.columns, err = internal.ParseCSVColumns(*columns)
+	if err != nil {
+		return usageError{err.Error()}
+	}
+
+	return withDB(config, func(db *sql.DB) error {
+		if options.dryRun {
+			return importFiles(db, importer, paths, options, stdout, stderr)
+		}
+
+		// Rebuild the search indexes once at the end instead of row by row,
+		// even when an import fails so they match what was committed
+		if err := internal.PauseFtsSync(db); err != nil {
+			return err
+		}
+
+		importErr := importFiles(db, importer, paths, options, stdout, stderr)
+		if err := internal.ReindexFts(db); err != nil {
+			return err
+		}
+		return importErr
+	})
+}
+
+func importFiles(
+	db *sql.DB,
+	importer importFunc,
+	paths []string,
+	options importOptions,
+	stdout io.Writer,
+	stderr io.Writer,
+) error {
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", path, err)
+		}
+
+		fmt.Fprintf(stderr, "Importing %s\n", path)
+		summary, err := importer(db, file, options, stdout, stderr)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		fmt.Fprintf(stdout, "%s: %s\n", path, summary)
+	}
+	return nil
+}
+
+// dumpCheckpoint identifies a dump file by its path, size and modification
+// time, so an interrupted import only resumes with the same file
+func dumpCheckpoint(file *os.File) (string, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	path, err := filepath.Abs(file.Name())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %d %d", path, info.Size(), info.ModTime().Unix()), nil
+}
+
+func scanCommand(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("scan", stderr)
+	batchSize := flags.Int("batch-size", internal.DefaultImportBatchSize, "Number of files scanned per transaction")
+	config, dirs, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if len(dirs) == 0 {
+		return usageError{"scan takes at least one directory"}
+	}
+	if *batchSize < 1 {
+		return usageError{fmt.Sprintf("invalid -batch-size %d", *batchSize)}
+	}
+
+	return withDB(config, func(db *sql.DB) error {
+		// Like imports, rebuild the search indexes once at the end
+		if err := internal.PauseFtsSync(db); err != nil {
+			return err
+		}
+
+		scanErr := func() error {
+			for _, dir := range dirs {
+				fmt.Fprintf(stderr, "Scanning %s\n", dir)
+				stats, err := internal.ScanLibrary(db, dir, *batchSize, stderr)
+				if err != nil {
+					return fmt.Errorf("%s: %w", dir, err)
+				}
+				fmt.Fprintf(stdout, "%s: %s\n", dir, stats)
+			}
+			return nil
+		}()
+		if err := internal.ReindexFts(db); err != nil {
+			return err
+		}
+		return scanErr
+	})
+}
+
+func exportCommand(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("export", stderr)
+	output := flags.String("o", "", "Write to FILE instead of standard output")
+	search := flags.String("q", "", "Only export CSV rows matching a releases search")
+	sort := flags.String("sort", "", "Order of CSV rows: relevance, year, year_desc, name or artist")
+	config, args, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	format := "json"
+	if len(args) > 1 {
+		return usageError{"export takes at most one format"}
+	}
+	if len(args) == 1 {
+		format = args[0]
+	}
+	if format != "json" && format != "csv" {
+		return usageError{fmt.Sprintf("unknown export format %q, use json or csv", format)}
+	}
+	if format == "json" && (*search != "" || *sort != "") {
+		return usageError{"-q and -sort only apply to export csv"}
+	}
+	if *sort != "" && !internal.IsValidReleaseSort(*sort) {
+		return usageError{fmt.Sprintf("invalid -sort %q", *sort)}
+	}
+
+	searchQuery, err := internal.ParseSearchQuery(*search)
+	if err != nil {
+		var validationErr *internal.ValidationError
+		if errors.As(err, &validationErr) {
+			return usageError{"invalid -q: " + validationErr.Fields["q"]}
+		}
+		return err
+	}
+
+	return withDB(config, func(db *sql.DB) error {
+		w := stdout
+		var file *os.File
+		if *output != "" {
+			file, err = os.Create(*output)
+			if err != nil {
+				return fmt.Errorf("failed to create %s: %w", *output, err)
+			}
+			defer file.Close()
+			w = file
+		}
+
+		var exported int
+		if format == "csv" {
+			exported, err = internal.ExportCSV(db, w, searchQuery, *sort)
+		} else {
+			exported, err = internal.ExportJSON(db, w)
+		}
+		if err != nil {
+			return err
+		}
+		// Close reports write errors the OS deferred, like a full disk
+		if file != nil {
+			if err := file.Close(); err != nil {
+				return fmt.Errorf("failed to write %s: %w", *output, err)
+			}
+		}
+
+		fmt.Fprintf(stderr, "Exported %d releases\n", exported)
+		return nil
+	})
+}
+
+func configCommand(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := newFlagSet("config", stderr)
+	config, args, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 || args[0] != "print" {
+		return usageError{"config takes the print subcommand"}
+	}
+
+	return config.WriteYAML(stdout)
+}
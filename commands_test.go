@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	// Commands that get past their arguments only ever touch this database
+	dbPath := filepath.Join(t.TempDir(), "data.db")
+
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{"help", []string{"help"}, exitOK, "Commands:", ""},
+		{"--help", []string{"--help"}, exitOK, "Commands:", ""},
+		{"-h", []string{"-h"}, exitOK, "Commands:", ""},
+		{"command -h", []string{"migrate", "-h"}, exitOK, "", "-migrations"},
+		{"unknown command", []string{"frobnicate"}, exitUsage, "", `Error: unknown command "frobnicate"`},
+		{"unknown flag", []string{"seed", "-nope"}, exitUsage, "", "flag provided but not defined: -nope"},
+		{"missing migrate subcommand", []string{"migrate"}, exitUsage, "", "Error: missing migrate subcommand\nUsage: simple-web-app migrate up | down [N] | status | goto N"},
+		{"unknown migrate subcommand", []string{"migrate", "sideways", "-db", dbPath}, exitUsage, "", `Error: unknown migrate subcommand "sideways"`},
+		{"invalid migrate down steps", []string{"migrate", "down", "none", "-db", dbPath}, exitUsage, "", `Error: invalid number of steps "none"`},
+		{"missing import source", []string{"import"}, exitUsage, "", "Error: missing import source"},
+		{"unknown import source", []string{"import", "itunes", "library.xml"}, exitUsage, "", `Error: unknown import source "itunes"`},
+		{"missing import file", []string{"import", "csv"}, exitUsage, "", "Error: import csv takes at least one file"},
+		{"csv flags on another source", []string{"import", "discogs", "-dry-run", "dump.xml"}, exitUsage, "", "Error: -columns and -dry-run only apply to import csv"},
+		{"invalid batch size", []string{"scan", "-batch-size", "0", "music"}, exitUsage, "", "Error: invalid -batch-size 0"},
+		{"missing scan directory", []string{"scan"}, exitUsage, "", "Error: scan takes at least one directory"},
+		{"unknown export format", []string{"export", "xml"}, exitUsage, "", `Error: unknown export format "xml", use json or csv`},
+		{"search on json export", []string{"export", "json", "-q", "queen"}, exitUsage, "", "Error: -q and -sort only apply to export csv"},
+		{"invalid export search", []string{"export", "csv", "-q", "mood:calm"}, exitUsage, "", `Error: invalid -q: Unknown filter "mood:"`},
+		{"seed arguments", []string{"seed", "everything"}, exitUsage, "", "Error: seed takes no arguments"},
+		{"reset arguments", []string{"reset", "now"}, exitUsage, "", "Error: reset takes no arguments"},
+		{"invalid seed mode", []string{"serve", "-seed", "sometimes"}, exitUsage, "", `Error: invalid -seed "sometimes": must be empty, always or never`},
+		{"serve arguments", []string{"-db", dbPath, "extra"}, exitUsage, "", "Error: serve takes no arguments"},
+		{"missing config subcommand", []string{"config"}, exitUsage, "", "Error: config takes the print subcommand"},
+		{"config print", []string{"config", "print", "-port", "9000"}, exitOK, "port: 9000", ""},
+		{"failed command", []string{"config", "print", "-config", filepath.Join(t.TempDir(), "missing.yaml")}, exitError, "", "Error: config failed:"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(test.args, &stdout, &stderr)
+
+			assert.Equal(t, test.code, code, stderr.String())
+			assert.Contains(t, stdout.String(), test.stdout)
+			assert.Contains(t, stderr.String(), test.stderr)
+			if test.code == exitOK {
+				assert.NotContains(t, stderr.String(), "Error:")
+			}
+		})
+	}
+}
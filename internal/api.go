@@ -0,0 +1,400 @@
+package internal
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ValidationErrorResponse struct {
+	Message string            `json:"message"`
+	Errors  map[string]string `json:"errors"`
+}
+
+type ArtistsResponse struct {
+	Artists    []ArtistSummary `json:"artists"`
+	Pagination Pagination      `json:"pagination"`
+}
+
+type LabelsResponse struct {
+	Labels     []LabelSummary `json:"labels"`
+	Pagination Pagination     `json:"pagination"`
+}
+
+// TagReleasesResponse is a genre or tag with a page of its releases
+type TagReleasesResponse struct {
+	TagDetail
+	Releases   []Release  `json:"releases"`
+	Pagination Pagination `json:"pagination"`
+}
+
+type ReleasesResponse struct {
+	Releases   []Release     `json:"releases"`
+	Pagination Pagination    `json:"pagination"`
+	Facets     ReleaseFacets `json:"facets"`
+}
+
+func setupApiRoutes(e *echo.Echo, db *sql.DB, config Config) {
+	api := e.Group("/api/v1")
+
+	api.GET("/releases", func(c echo.Context) error {
+		// Read query parameters
+		pageStr := c.QueryParam("page")
+		limitStr := c.QueryParam("page_size")
+		searchQuery, err := releaseSearchParams(c)
+		if err != nil {
+			return apiError(c, err, "Failed to load releases")
+		}
+		sort := releaseSort(c.QueryParam("sort"), searchQuery)
+
+		// Get releases with pagination and search
+		items, pagination, err := getPaginatedReleases(db, pageStr, limitStr, config.DefaultPageSize, searchQuery, sort, e.Logger, c.Request())
+		if err != nil {
+			return apiError(c, err, "Failed to load releases")
+		}
+
+		facets, err := getReleaseFacets(db, searchQuery)
+		if err != nil {
+			return apiError(c, err, "Failed to load releases")
+		}
+
+		// Load typed releases with all of their artists
+		releaseIds := make([]int, 0, len(items))
+		versionCounts := map[int]int{}
+		for _, item := range items {
+			releaseIds = append(releaseIds, item["release_id"].(int))
+			versionCounts[item["release_id"].(int)] = item["version_count"].(int)
+		}
+
+		releases, err := getReleasesByIds(db, releaseIds)
+		if err != nil {
+			e.Logger.Printf("Failed to get releases by id: %v", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load releases")
+		}
+		// Collapsed rows say how many versions of their master matched
+		if searchQuery.CollapseMasters {
+			for i := range releases {
+				releases[i].VersionCount = versionCounts[releases[i].Id]
+			}
+		}
+
+		return c.JSON(http.StatusOK, ReleasesResponse{
+			Releases:   releases,
+			Pagination: pagination,
+			Facets:     facets,
+		})
+	})
+
+	api.GET("/releases/:id", func(c echo.Context) error {
+		releaseId, err := paramId(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Release not found")
+		}
+
+		release, err := getReleaseDetail(db, releaseId)
+		if err != nil {
+			return apiError(c, err, "Failed to load release")
+		}
+
+		return c.JSON(http.StatusOK, release)
+	})
+
+	api.POST("/releases", func(c echo.Context) error {
+		var input ReleaseInput
+		if err := c.Bind(&input); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid release")
+		}
+
+		release, err := createRelease(db, input)
+		if err != nil {
+			return apiError(c, err, "Failed to create release")
+		}
+
+		return c.JSON(http.StatusCreated, release)
+	})
+
+	api.PUT("/releases/:id", func(c echo.Context) error {
+		releaseId, err := paramId(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Release not found")
+		}
+
+		var input ReleaseInput
+		if err := c.Bind(&input); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid release")
+		}
+
+		release, err := updateRelease(db, releaseId, input)
+		if err != nil {
+			return apiError(c, err, "Failed to update release")
+		}
+
+		return c.JSON(http.StatusOK, release)
+	})
+
+	api.DELETE("/releases/:id", func(c echo.Context) error {
+		releaseId, err := paramId(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Release not found")
+		}
+
+		if err := deleteRelease(db, releaseId); err != nil {
+			return apiError(c, err, "Failed to delete release")
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	api.GET("/releases/:id/tracks", func(c echo.Context) error {
+		releaseId, err := paramId(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Release not found")
+		}
+
+		if _, err := getRelease(db, releaseId); err != nil {
+			return apiError(c, err, "Failed to load tracks")
+		}
+		tracks, err := getReleaseTracks(db, releaseId)
+		if err != nil {
+			return apiError(c, err, "Failed to load tracks")
+		}
+
+		return c.JSON(http.StatusOK, tracks)
+	})
+
+	api.POST("/releases/:id/tracks", func(c echo.Context) error {
+		releaseId, err := paramId(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Release not found")
+		}
+
+		var input TrackInput
+		if err := c.Bind(&input); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid track")
+		}
+
+		track, err := createTrack(db, releaseId, input)
+		if err != nil {
+			return apiError(c, err, "Failed to create track")
+		}
+
+		return c.JSON(http.StatusCreated, track)
+	})
+
+	api.PUT("/releases/:id/tracks/:track_id", func(c echo.Context) error {
+		releaseId, err := paramId(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Track not found")
+		}
+		trackId, err := paramNamedId(c, "track_id")
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Track not found")
+		}
+
+		var input TrackInput
+		if err := c.Bind(&input); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid track")
+		}
+
+		track, err := updateTrack(db, releaseId, trackId, input)
+		if err != nil {
+			return apiError(c, err, "Failed to update track")
+		}
+
+		return c.JSON(http.StatusOK, track)
+	})
+
+	api.DELETE("/releases/:id/tracks/:track_id", func(c echo.Context) error {
+		releaseId, err := paramId(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Track not found")
+		}
+		trackId, err := paramNamedId(c, "track_id")
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Track not found")
+		}
+
+		if err := deleteTrack(db, releaseId, trackId); err != nil {
+			return apiError(c, err, "Failed to delete track")
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	api.GET("/artists", func(c echo.Context) error {
+		// Read query parameters
+		pageStr := c.QueryParam("page")
+		limitStr := c.QueryParam("page_size")
+		searchQuery := c.QueryParam("q")
+
+		artists, pagination, err := getPaginatedArtists(db, pageStr, limitStr, config.DefaultPageSize, searchQuery, c.Request())
+		if err != nil {
+			return apiError(c, err, "Failed to load artists")
+		}
+
+		return c.JSON(http.StatusOK, ArtistsResponse{
+			Artists:    artists,
+			Pagination: pagination,
+		})
+	})
+
+	api.GET("/artists/:id", func(c echo.Context) error {
+		artistId, err := paramId(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Artist not found")
+		}
+
+		artist, err := getArtistDetail(db, artistId)
+		if err != nil {
+			return apiError(c, err, "Failed to load artist")
+		}
+
+		return c.JSON(http.StatusOK, artist)
+	})
+
+	api.POST("/artists", func(c echo.Context) error {
+		var input ArtistInput
+		if err := c.Bind(&input); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid artist")
+		}
+
+		artist, err := createArtist(db, input)
+		if err != nil {
+			return apiError(c, err, "Failed to create artist")
+		}
+
+		return c.JSON(http.StatusCreated, artist)
+	})
+
+	api.PUT("/artists/:id", func(c echo.Context) error {
+		artistId, err := paramId(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Artist not found")
+		}
+
+		var input ArtistInput
+		if err := c.Bind(&input); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid artist")
+		}
+
+		artist, err := updateArtist(db, artistId, input)
+		if err != nil {
+			return apiError(c, err, "Failed to update artist")
+		}
+
+		return c.JSON(http.StatusOK, artist)
+	})
+
+	api.DELETE("/artists/:id", func(c echo.Context) error {
+		artistId, err := paramId(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Artist not found")
+		}
+
+		if err := deleteArtist(db, artistId); err != nil {
+			return apiError(c, err, "Failed to delete artist")
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	api.GET("/labels", func(c echo.Context) error {
+		// Read query parameters
+		pageStr := c.QueryParam("page")
+		limitStr := c.QueryParam("page_size")
+		searchQuery := c.QueryParam("q")
+
+		labels, pagination, err := getPaginatedLabels(db, pageStr, limitStr, config.DefaultPageSize, searchQuery, c.Request())
+		if err != nil {
+			return apiError(c, err, "Failed to load labels")
+		}
+
+		return c.JSON(http.StatusOK, LabelsResponse{
+			Labels:     labels,
+			Pagination: pagination,
+		})
+	})
+
+	api.GET("/labels/:id", func(c echo.Context) error {
+		labelId, err := paramId(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Label not found")
+		}
+
+		label, err := getLabelDetail(db, labelId)
+		if err != nil {
+			return apiError(c, err, "Failed to load label")
+		}
+
+		return c.JSON(http.StatusOK, label)
+	})
+
+	api.GET("/masters/:id", func(c echo.Context) error {
+		masterId, err := paramId(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Master not found")
+		}
+
+		master, err := getMasterDetail(db, masterId)
+		if err != nil {
+			return apiError(c, err, "Failed to load master")
+		}
+
+		return c.JSON(http.StatusOK, master)
+	})
+
+	for _, t := range []taxonomy{genreTaxonomy, tagTaxonomy} {
+		// Every genre or tag with its number of releases, keyed by "genres" or "tags"
+		api.GET("/"+t.Plural, func(c echo.Context) error {
+			tags, err := getTags(db, t)
+			if err != nil {
+				return apiError(c, err, "Failed to load "+t.Plural)
+			}
+
+			return c.JSON(http.StatusOK, map[string][]TagSummary{t.Plural: tags})
+		})
+
+		api.GET("/"+t.Plural+"/:slug", func(c echo.Context) error {
+			slug, err := url.PathUnescape(c.Param("slug"))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusNotFound, t.SingularTitle()+" not found")
+			}
+
+			tag, err := getTagDetail(db, t, slug)
+			if err != nil {
+				return apiError(c, err, "Failed to load "+t.Name)
+			}
+
+			releases, pagination, err := getPaginatedTagReleases(db, t, tag.Slug, c.QueryParam("page"), c.QueryParam("page_size"), config.DefaultPageSize, c.Request())
+			if err != nil {
+				return apiError(c, err, "Failed to load "+t.Name)
+			}
+
+			return c.JSON(http.StatusOK, TagReleasesResponse{
+				TagDetail:  tag,
+				Releases:   releases,
+				Pagination: pagination,
+			})
+		})
+	}
+}
+
+// apiError converts errors from the data layer into JSON responses
+func apiError(c echo.Context, err error, message string) error {
+	var validationErr *ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return c.JSON(http.StatusUnprocessableEntity, ValidationErrorResponse{
+			Message: "Validation failed",
+			Errors:  validationErr.Fields,
+		})
+	case errors.Is(err, errNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "Not found")
+	default:
+		c.Logger().Errorf("%s: %v", message, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, message)
+	}
+}
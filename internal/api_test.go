@@ -0,0 +1,417 @@
+package internal
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiRoutes(t *testing.T) {
+	e := echo.New()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	createTestTables(db)
+	seedTestReleases(db)
+	seedTestArtists(db)
+	seedTestReleaseArtists(db)
+	populateReleasesFtsTable(db)
+	SetupRoutes(e, db, DefaultConfig())
+
+	t.Run("GET /api/v1/releases", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/releases?page=2&page_size=5", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON)
+
+		var response ReleasesResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Len(t, response.Releases, 5)
+		assert.Equal(t, Release{
+			Id:      6,
+			Name:    "Album 6",
+			Year:    1996,
+			Formats: []Format{},
+			Credit:  "Artist 6",
+			Credits: []Credit{{Artist: Artist{Id: 6, Name: "Artist 6"}, Role: RoleMain}},
+			Artists: []Artist{{Id: 6, Name: "Artist 6"}},
+			Labels:  []ReleaseLabel{},
+			Genres:  []Tag{},
+			Tags:    []Tag{},
+		}, response.Releases[0])
+		assert.Equal(t, 2, response.Pagination.Page)
+		assert.Equal(t, 30, response.Pagination.TotalCount)
+		assert.Equal(t, "/api/v1/releases?page=3&page_size=5", *response.Pagination.NextUrl)
+	})
+
+	t.Run("GET /api/v1/releases with search", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/releases?q=%22Album+12%22", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response ReleasesResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Len(t, response.Releases, 1)
+		assert.Equal(t, "Album 12", response.Releases[0].Name)
+		assert.Equal(t, 1, response.Pagination.TotalCount)
+	})
+
+	t.Run("GET /api/v1/releases/:id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/releases/3", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"id": 3,
+			"name": "Album 3",
+			"year": 1993,
+			"barcode": "",
+			"master_id": 0,
+			"country": "",
+			"release_date": "",
+			"formats": [],
+			"credit": "Artist 3",
+			"credits": [{"id": 3, "name": "Artist 3", "role": "main", "join_phrase": ""}],
+			"artists": [{"id": 3, "name": "Artist 3"}],
+			"labels": [],
+			"genres": [],
+			"tags": [],
+			"master": null,
+			"versions": [],
+			"related_releases": [],
+			"tracks": []
+		}`, rec.Body.String())
+	})
+
+	t.Run("GET /api/v1/releases/:id with unknown id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/releases/999", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("GET /api/v1/releases with no results", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/releases?q=nothing", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[]`, string(mustMarshalField(t, rec.Body.Bytes(), "releases")))
+	})
+
+	t.Run("GET /api/v1/releases with filters", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/releases?q=artist:%22Artist+1%22+year:2000..2005+-album+-%22Album+2%22", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[]`, string(mustMarshalField(t, rec.Body.Bytes(), "releases")))
+
+		req = httptest.NewRequest(http.MethodGet, "/api/v1/releases?q=artist:%22Artist+1%22+year:2000..2005", nil)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		var response ReleasesResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, []string{"Album 10", "Album 11", "Album 12", "Album 13", "Album 14", "Album 15"}, releaseNames(response.Releases))
+	})
+
+	t.Run("GET /api/v1/releases sorted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/releases?sort=year_desc&page_size=3", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		var response ReleasesResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, []string{"Album 30", "Album 29", "Album 28"}, releaseNames(response.Releases))
+		assert.Equal(t, "/api/v1/releases?page=2&page_size=3&sort=year_desc", *response.Pagination.NextUrl)
+	})
+
+	t.Run("GET /api/v1/releases with facets", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/releases?q=album&decade=2010&artist=Artist+21", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"decades": [{"decade": 2010, "count": 1}],
+			"artists": [{"name": "Artist 21", "count": 1}]
+		}`, string(mustMarshalField(t, rec.Body.Bytes(), "facets")))
+
+		var response ReleasesResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, []string{"Album 21"}, releaseNames(response.Releases))
+	})
+
+	t.Run("GET /api/v1/releases with cursors", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/releases?after=&page_size=5", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		var response ReleasesResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, []string{"Album 1", "Album 2", "Album 3", "Album 4", "Album 5"}, releaseNames(response.Releases))
+		assert.Equal(t, PaginationCursor, response.Pagination.Mode)
+		assert.Nil(t, response.Pagination.PrevCursor)
+		assert.Equal(t, "/api/v1/releases?after="+*response.Pagination.NextCursor+"&page_size=5", *response.Pagination.NextUrl)
+
+		var raw struct {
+			Pagination map[string]any `json:"pagination"`
+		}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &raw))
+		assert.Contains(t, raw.Pagination, "nextCursor")
+		assert.NotContains(t, raw.Pagination, "page")
+		assert.NotContains(t, raw.Pagination, "totalCount")
+		assert.NotContains(t, raw.Pagination, "totalPages")
+
+		req = httptest.NewRequest(http.MethodGet, *response.Pagination.NextUrl, nil)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		response = ReleasesResponse{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, []string{"Album 6", "Album 7", "Album 8", "Album 9", "Album 10"}, releaseNames(response.Releases))
+		assert.NotNil(t, response.Pagination.PrevCursor)
+		assert.NotNil(t, response.Pagination.NextCursor)
+	})
+
+	t.Run("GET /api/v1/releases with invalid search", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/releases?q=year:1995..1990", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.JSONEq(t, `{
+			"message": "Validation failed",
+			"errors": {"q": "Year range must start before it ends"}
+		}`, rec.Body.String())
+	})
+}
+
+func releaseNames(releases []Release) []string {
+	names := []string{}
+	for _, release := range releases {
+		names = append(names, release.Name)
+	}
+	return names
+}
+
+// mustMarshalField returns the raw JSON of a top level field in body
+func mustMarshalField(t *testing.T, body []byte, field string) json.RawMessage {
+	t.Helper()
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	return fields[field]
+}
+
+func TestApiWriteRoutes(t *testing.T) {
+	e := echo.New()
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen')")
+	SetupRoutes(e, db, DefaultConfig())
+
+	send := func(method string, target string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var release Release
+
+	t.Run("POST /api/v1/releases", func(t *testing.T) {
+		rec := send(http.MethodPost, "/api/v1/releases", `{"name": "Innuendo", "year": 1991, "artist_ids": [1]}`)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &release))
+		assert.Equal(t, "Innuendo", release.Name)
+		assert.Equal(t, []Artist{{Id: 1, Name: "Queen"}}, release.Artists)
+	})
+
+	t.Run("POST /api/v1/releases with labels", func(t *testing.T) {
+		rec := send(http.MethodPost, "/api/v1/releases", `{
+			"name": "Jazz",
+			"year": 1978,
+			"barcode": "077774615426",
+			"artist_ids": [1],
+			"labels": [{"name": "EMI", "catno": "EMA 788"}]
+		}`)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"barcode":"077774615426"`)
+		assert.Contains(t, rec.Body.String(), `"labels":[{"id":1,"name":"EMI","catno":"EMA 788"}]`)
+		assert.Equal(t, []string{"Queen"}, searchCredits(t, db, "catno:ema788"))
+	})
+
+	t.Run("POST /api/v1/releases without artists", func(t *testing.T) {
+		rec := send(http.MethodPost, "/api/v1/releases", `{"name": "Lonely Album", "year": 2000}`)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var lonely Release
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &lonely))
+
+		// Releases without artists are listed, searched and suggested like any other
+		for _, target := range []string{"/api/v1/releases?q=lonely", "/api/v1/releases?page_size=100"} {
+			rec = send(http.MethodGet, target, "")
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Contains(t, rec.Body.String(), fmt.Sprintf(`{"id":%d,"name":"Lonely Album"`, lonely.Id), target)
+		}
+
+		rec = send(http.MethodGet, "/search/suggest?q=lonely", "")
+		assert.Contains(t, rec.Body.String(), "Lonely Album")
+	})
+
+	t.Run("Deleting the only artist keeps the release listed", func(t *testing.T) {
+		rec := send(http.MethodPost, "/api/v1/artists", `{"name": "Freddie"}`)
+		var artist Artist
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &artist))
+		rec = send(http.MethodPost, "/api/v1/releases", fmt.Sprintf(`{"name": "Mr. Bad Guy", "year": 1985, "artist_ids": [%d]}`, artist.Id))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		rec = send(http.MethodDelete, fmt.Sprintf("/api/v1/artists/%d", artist.Id), "")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, []string{""}, searchCredits(t, db, "bad guy"))
+	})
+
+	t.Run("POST /api/v1/releases with invalid release", func(t *testing.T) {
+		rec := send(http.MethodPost, "/api/v1/releases", `{"name": "", "year": 1991}`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+		var response ValidationErrorResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Contains(t, response.Errors, "name")
+	})
+
+	t.Run("PUT /api/v1/releases/:id", func(t *testing.T) {
+		rec := send(http.MethodPut, fmt.Sprintf("/api/v1/releases/%d", release.Id), `{"name": "Innuendo", "year": 1992, "artist_ids": [1]}`)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"year":1992`)
+	})
+
+	t.Run("PUT /api/v1/releases/:id with unknown id", func(t *testing.T) {
+		rec := send(http.MethodPut, "/api/v1/releases/999", `{"name": "Innuendo", "year": 1992}`)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("PUT /api/v1/artists/:id", func(t *testing.T) {
+		rec := send(http.MethodPut, "/api/v1/artists/1", `{"name": "Queen + Paul Rodgers"}`)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []string{"Queen + Paul Rodgers"}, searchCredits(t, db, "Innuendo"))
+	})
+
+	t.Run("POST /api/v1/releases/:id/tracks", func(t *testing.T) {
+		rec := send(http.MethodPost, fmt.Sprintf("/api/v1/releases/%d/tracks", release.Id), `{"title": "The Show Must Go On", "duration": "4:31", "artist_ids": [1]}`)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		var track Track
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &track))
+		assert.Equal(t, 1, track.Disc)
+		assert.Equal(t, 1, track.Position)
+		assert.Equal(t, Duration(271), track.Duration)
+		assert.Equal(t, []string{"Queen + Paul Rodgers"}, searchCredits(t, db, "track:\"must go on\""))
+
+		rec = send(http.MethodPost, fmt.Sprintf("/api/v1/releases/%d/tracks", release.Id), `{"title": "", "duration": 90000}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		var response ValidationErrorResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Contains(t, response.Errors, "title")
+		assert.Contains(t, response.Errors, "duration")
+
+		rec = send(http.MethodPost, "/api/v1/releases/999/tracks", `{"title": "Innuendo"}`)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("GET and PUT /api/v1/releases/:id/tracks", func(t *testing.T) {
+		rec := send(http.MethodGet, fmt.Sprintf("/api/v1/releases/%d/tracks", release.Id), "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var tracks []Track
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tracks))
+		if !assert.Len(t, tracks, 1) {
+			return
+		}
+
+		rec = send(http.MethodPut, fmt.Sprintf("/api/v1/releases/%d/tracks/%d", release.Id, tracks[0].Id), `{"position": 12, "title": "The Show Must Go On", "duration": null}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, fmt.Sprintf(`{
+			"id": %d,
+			"release_id": %d,
+			"disc": 1,
+			"position": 12,
+			"title": "The Show Must Go On",
+			"duration": null,
+			"artists": []
+		}`, tracks[0].Id, release.Id), rec.Body.String())
+
+		rec = send(http.MethodGet, "/api/v1/releases/999/tracks", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		rec = send(http.MethodDelete, fmt.Sprintf("/api/v1/releases/%d/tracks/999", release.Id), "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("DELETE /api/v1/releases/:id", func(t *testing.T) {
+		rec := send(http.MethodDelete, fmt.Sprintf("/api/v1/releases/%d", release.Id), "")
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Empty(t, searchCredits(t, db, "Innuendo"))
+		assert.Equal(t, 0, countRows(t, db, "tracks"))
+	})
+
+	t.Run("POST and DELETE /api/v1/artists", func(t *testing.T) {
+		rec := send(http.MethodPost, "/api/v1/artists", `{"name": "Bowie"}`)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var artist Artist
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &artist))
+
+		rec = send(http.MethodDelete, fmt.Sprintf("/api/v1/artists/%d", artist.Id), "")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = send(http.MethodDelete, fmt.Sprintf("/api/v1/artists/%d", artist.Id), "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestApiDefaultPageSize(t *testing.T) {
+	e := echo.New()
+	db := openMigratedTestDB(t)
+	if err := SeedDB(db); err != nil {
+		t.Fatalf("Failed to seed database: %v", err)
+	}
+
+	config := DefaultConfig()
+	config.DefaultPageSize = 3
+	SetupRoutes(e, db, config)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/releases", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	var response ReleasesResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Len(t, response.Releases, 3)
+	assert.Equal(t, 10, response.Pagination.TotalPages)
+}
@@ -0,0 +1,166 @@
+package internal
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+func setupArtistRoutes(e *echo.Echo, db *sql.DB, config Config) {
+	// renderArtistForm renders the create/edit form, or just the form partial for HTMX requests
+	renderArtistForm := func(c echo.Context, status int, artistId int, input ArtistInput, fieldErrors map[string]string) error {
+		title := "New Artist"
+		if artistId != 0 {
+			title = "Edit Artist"
+		}
+
+		data := map[string]interface{}{
+			"Title":        title,
+			"ArtistId":     artistId,
+			"Input":        input,
+			"Errors":       fieldErrors,
+			"IncludeHTMX":  true,
+			"CurrentRoute": c.Request().URL.Path,
+		}
+
+		if isHtmxRequest(c) {
+			return c.Render(status, "artist_form_partial", data)
+		}
+		return c.Render(status, "artist_form", data)
+	}
+
+	// handleArtistError re-renders the form for validation errors and maps everything else to a status
+	handleArtistError := func(c echo.Context, err error, artistId int, input ArtistInput) error {
+		var validationErr *ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			return renderArtistForm(c, invalidFormStatus(c), artistId, input, validationErr.Fields)
+		case errors.Is(err, errNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Artist not found")
+		default:
+			e.Logger.Printf("Failed to save artist: %v", err)
+			return c.String(http.StatusInternalServerError, "Failed to save artist")
+		}
+	}
+
+	e.GET("/artists", func(c echo.Context) error {
+		// Read query parameters
+		pageStr := c.QueryParam("page")
+		limitStr := c.QueryParam("page_size")
+		searchQuery := c.QueryParam("q")
+
+		artists, pagination, err := getPaginatedArtists(db, pageStr, limitStr, config.DefaultPageSize, searchQuery, c.Request())
+		if err != nil {
+			e.Logger.Printf("Failed to get artists: %v", err)
+			return c.String(http.StatusInternalServerError, "Failed to load artists")
+		}
+
+		// Render appropriate template (full page or HTMX partial)
+		if isHtmxRequest(c) {
+			return c.Render(http.StatusOK, "artists_partial", map[string]interface{}{
+				"Artists":    artists,
+				"Pagination": pagination,
+			})
+		}
+
+		data := map[string]interface{}{
+			"Title":        "Artists",
+			"Artists":      artists,
+			"Query":        searchQuery,
+			"Pagination":   pagination,
+			"IncludeHTMX":  true,
+			"CurrentRoute": c.Request().URL.Path,
+		}
+
+		return c.Render(http.StatusOK, "artists", data)
+	})
+
+	e.GET("/artists/:id", func(c echo.Context) error {
+		artistId, err := paramId(c)
+		if err != nil {
+			return renderNotFound(c, "This artist doesn't exist.")
+		}
+
+		artist, err := getArtistDetail(db, artistId)
+		if errors.Is(err, errNotFound) {
+			return renderNotFound(c, "This artist doesn't exist.")
+		}
+		if err != nil {
+			e.Logger.Printf("Failed to get artist: %v", err)
+			return c.String(http.StatusInternalServerError, "Failed to load artist")
+		}
+
+		data := map[string]interface{}{
+			"Title":        artist.Name,
+			"Artist":       artist,
+			"CurrentRoute": c.Request().URL.Path,
+		}
+
+		return c.Render(http.StatusOK, "artist", data)
+	})
+
+	e.GET("/artists/new", func(c echo.Context) error {
+		return renderArtistForm(c, http.StatusOK, 0, ArtistInput{}, nil)
+	})
+
+	e.POST("/artists", func(c echo.Context) error {
+		var input ArtistInput
+		if err := c.Bind(&input); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid artist")
+		}
+
+		if _, err := createArtist(db, input); err != nil {
+			return handleArtistError(c, err, 0, input)
+		}
+
+		return redirectAfterSubmit(c, "/releases/new")
+	})
+
+	e.GET("/artists/:id/edit", func(c echo.Context) error {
+		artistId, err := paramId(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Artist not found")
+		}
+
+		artist, err := getArtist(db, artistId)
+		if err != nil {
+			return handleArtistError(c, err, artistId, ArtistInput{})
+		}
+
+		return renderArtistForm(c, http.StatusOK, artistId, ArtistInput{Name: artist.Name}, nil)
+	})
+
+	e.PUT("/artists/:id", func(c echo.Context) error {
+		artistId, err := paramId(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Artist not found")
+		}
+
+		var input ArtistInput
+		if err := c.Bind(&input); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid artist")
+		}
+
+		if _, err := updateArtist(db, artistId, input); err != nil {
+			return handleArtistError(c, err, artistId, input)
+		}
+
+		return redirectAfterSubmit(c, "/artists/"+strconv.Itoa(artistId))
+	})
+
+	e.DELETE("/artists/:id", func(c echo.Context) error {
+		artistId, err := paramId(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Artist not found")
+		}
+
+		if err := deleteArtist(db, artistId); err != nil {
+			return handleArtistError(c, err, artistId, ArtistInput{})
+		}
+
+		return redirectAfterSubmit(c, "/artists")
+	})
+}
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArtistFormRoutes(t *testing.T) {
+	e := echo.New()
+	e.Renderer = &Template{TemplateDir: "./templates"}
+
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen')")
+	SetupRoutes(e, db, DefaultConfig())
+
+	t.Run("GET /artists/:id/edit", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/artists/1/edit", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `value="Queen"`)
+		assert.Contains(t, rec.Body.String(), `hx-delete="/artists/1"`)
+	})
+
+	t.Run("POST /artists with invalid artist", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/artists", strings.NewReader(url.Values{"name": {" "}}.Encode()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Name is required")
+	})
+
+	t.Run("PUT /artists/:id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/artists/1", strings.NewReader(url.Values{"name": {"Queen II"}}.Encode()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusSeeOther, rec.Code)
+
+		artist, err := getArtist(db, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "Queen II", artist.Name)
+	})
+}
+
+func TestArtistRoutes(t *testing.T) {
+	e := echo.New()
+	e.Renderer = &Template{TemplateDir: "./templates"}
+
+	db := openMigratedTestDB(t)
+	if err := SeedDB(db); err != nil {
+		t.Fatalf("Failed to seed database: %v", err)
+	}
+	SetupRoutes(e, db, DefaultConfig())
+
+	t.Run("GET /artists", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/artists?page=2", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Page 2 of 3")
+		assert.Contains(t, rec.Body.String(), `hx-get="/artists"`)
+	})
+
+	t.Run("GET /artists with HTMX search", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/artists?q=queen", nil)
+		req.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `<a href="/artists/1" class="text-rose-800 hover:underline">Queen</a>`)
+		assert.NotContains(t, rec.Body.String(), "Oasis")
+		assert.NotContains(t, rec.Body.String(), "<html")
+	})
+
+	t.Run("GET /artists/:id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/artists/1", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "1990s")
+		assert.Contains(t, rec.Body.String(), `<a href="/releases/1" class="text-rose-800 hover:underline">Album 1</a>`)
+	})
+
+	t.Run("GET /artists/:id with unknown id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/artists/999", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("GET /api/v1/artists", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/artists?q=ee&page_size=5", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[]`, string(mustMarshalField(t, rec.Body.Bytes(), "artists")))
+
+		req = httptest.NewRequest(http.MethodGet, "/api/v1/artists?q=que", nil)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		var response ArtistsResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, []ArtistSummary{{Artist: Artist{Id: 1, Name: "Queen"}, ReleaseCount: 1}}, response.Artists)
+		assert.Equal(t, 1, response.Pagination.TotalCount)
+	})
+
+	t.Run("GET /api/v1/artists/:id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/artists/2", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"id": 2,
+			"name": "Radio",
+			"decades": [
+				{"decade": 1990, "releases": [{"id": 2, "name": "Album 2", "year": 1992, "barcode": "", "master_id": 0, "country": "", "release_date": "", "formats": [], "credit": "Radio", "credits": [{"id": 2, "name": "Radio", "role": "main", "join_phrase": ""}], "artists": [{"id": 2, "name": "Radio"}], "labels": [], "genres": [], "tags": []}]}
+			]
+		}`, rec.Body.String())
+	})
+}
@@ -0,0 +1,269 @@
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+type ArtistInput struct {
+	Name string `json:"name" form:"name"`
+}
+
+func (input *ArtistInput) validate() error {
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		return &ValidationError{Fields: map[string]string{"name": "Name is required"}}
+	}
+	return nil
+}
+
+func getArtist(db *sql.DB, artistId int) (Artist, error) {
+	var artist Artist
+	err := db.QueryRow("SELECT id, name FROM artists WHERE id = ?", artistId).Scan(&artist.Id, &artist.Name)
+	if err == sql.ErrNoRows {
+		return Artist{}, errNotFound
+	}
+	return artist, err
+}
+
+// getAllArtists lists every artist by name, for building forms
+func getAllArtists(db *sql.DB) ([]Artist, error) {
+	rows, err := db.Query("SELECT id, name FROM artists ORDER BY name COLLATE NOCASE, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	artists := []Artist{}
+	for rows.Next() {
+		var artist Artist
+		if err := rows.Scan(&artist.Id, &artist.Name); err != nil {
+			return nil, err
+		}
+		artists = append(artists, artist)
+	}
+	return artists, rows.Err()
+}
+
+func createArtist(db *sql.DB, input ArtistInput) (Artist, error) {
+	if err := input.validate(); err != nil {
+		return Artist{}, err
+	}
+
+	result, err := db.Exec("INSERT INTO artists (name) VALUES (?)", input.Name)
+	if err != nil {
+		return Artist{}, err
+	}
+
+	artistId, err := result.LastInsertId()
+	if err != nil {
+		return Artist{}, err
+	}
+
+	return Artist{Id: int(artistId), Name: input.Name}, nil
+}
+
+// updateArtist renames an artist. The artists triggers reindex every release
+// the artist is credited on.
+func updateArtist(db *sql.DB, artistId int, input ArtistInput) (Artist, error) {
+	if err := input.validate(); err != nil {
+		return Artist{}, err
+	}
+
+	result, err := db.Exec("UPDATE artists SET name = ? WHERE id = ?", input.Name, artistId)
+	if err != nil {
+		return Artist{}, err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return Artist{}, err
+	} else if affected == 0 {
+		return Artist{}, errNotFound
+	}
+
+	return Artist{Id: artistId, Name: input.Name}, nil
+}
+
+// deleteArtist removes an artist along with its release credits. The releases
+// themselves are kept.
+func deleteArtist(db *sql.DB, artistId int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM release_artists WHERE artist_id = ?", artistId); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("DELETE FROM artists WHERE id = ?", artistId)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return errNotFound
+	}
+
+	return tx.Commit()
+}
+
+type ArtistSummary struct {
+	Artist
+	ReleaseCount int `json:"release_count"`
+}
+
+// Decade groups an artist's releases by the decade they came out in, e.g. 1990
+type Decade struct {
+	Decade   int       `json:"decade"`
+	Releases []Release `json:"releases"`
+}
+
+type ArtistDetail struct {
+	Artist
+	Decades []Decade `json:"decades"`
+}
+
+// artistSearchFilter returns a WHERE clause matching artists by name. The trigram
+// index can only match 3 or more characters, so shorter queries match name prefixes.
+func artistSearchFilter(searchQuery string) (string, []interface{}) {
+	searchQuery = strings.TrimSpace(searchQuery)
+	switch {
+	case searchQuery == "":
+		return "", nil
+	case utf8.RuneCountInString(searchQuery) < 3:
+		return `WHERE artists.name LIKE ? ESCAPE '\'`, []interface{}{escapeLike(searchQuery) + "%"}
+	default:
+		// Quote the query as a phrase so FTS5 syntax characters are matched literally
+		phrase := `"` + strings.ReplaceAll(searchQuery, `"`, `""`) + `"`
+		return "WHERE artists.id IN (SELECT rowid FROM artists_fts WHERE artists_fts MATCH ?)", []interface{}{phrase}
+	}
+}
+
+func getArtistsCount(db *sql.DB, searchQuery string) (int, error) {
+	where, args := artistSearchFilter(searchQuery)
+
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM artists "+where, args...).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func getArtists(db *sql.DB, limit int, offset int, searchQuery string) ([]ArtistSummary, error) {
+	// Validate inputs
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid limit: %d", limit)
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("invalid offset: %d", offset)
+	}
+
+	where, args := artistSearchFilter(searchQuery)
+	args = append(args, limit, offset)
+
+	rows, err := db.Query(`
+		SELECT
+			artists.id,
+			artists.name,
+			(SELECT COUNT(DISTINCT release_id) FROM release_artists WHERE artist_id = artists.id) AS release_count
+		FROM artists
+		`+where+`
+		ORDER BY artists.name COLLATE NOCASE, artists.id
+		LIMIT ?
+		OFFSET ?;
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	artists := []ArtistSummary{}
+	for rows.Next() {
+		var artist ArtistSummary
+		if err := rows.Scan(&artist.Id, &artist.Name, &artist.ReleaseCount); err != nil {
+			return nil, err
+		}
+		artists = append(artists, artist)
+	}
+	return artists, rows.Err()
+}
+
+func getPaginatedArtists(
+	db *sql.DB,
+	pageStr string,
+	limitStr string,
+	defaultPageSize int,
+	searchQuery string,
+	request *http.Request,
+) ([]ArtistSummary, Pagination, error) {
+	totalCount, err := getArtistsCount(db, searchQuery)
+	if err != nil {
+		return nil, Pagination{}, fmt.Errorf("failed to get artists count: %w", err)
+	}
+
+	pagination, err := getPagination(pageStr, limitStr, defaultPageSize, totalCount, request)
+	if err != nil {
+		return nil, Pagination{}, err
+	}
+
+	artists, err := getArtists(db, pagination.Limit, pagination.Offset, searchQuery)
+	return artists, pagination, err
+}
+
+// getArtistDetail loads an artist and their discography, oldest first, grouped by decade
+func getArtistDetail(db *sql.DB, artistId int) (ArtistDetail, error) {
+	artist, err := getArtist(db, artistId)
+	if err != nil {
+		return ArtistDetail{}, err
+	}
+
+	rows, err := db.Query(`
+		SELECT DISTINCT releases.id, releases.year, releases.name
+		FROM release_artists
+		JOIN releases ON releases.id = release_artists.release_id
+		WHERE release_artists.artist_id = ?
+		ORDER BY releases.year, releases.name, releases.id;
+	`, artistId)
+	if err != nil {
+		return ArtistDetail{}, err
+	}
+	defer rows.Close()
+
+	var releaseIds []int
+	for rows.Next() {
+		var id, year int
+		var name string
+		if err := rows.Scan(&id, &year, &name); err != nil {
+			return ArtistDetail{}, err
+		}
+		releaseIds = append(releaseIds, id)
+	}
+	if err := rows.Err(); err != nil {
+		return ArtistDetail{}, err
+	}
+
+	releases, err := getReleasesByIds(db, releaseIds)
+	if err != nil {
+		return ArtistDetail{}, err
+	}
+
+	decades := []Decade{}
+	for _, release := range releases {
+		decade := release.Year / 10 * 10
+		if len(decades) == 0 || decades[len(decades)-1].Decade != decade {
+			decades = append(decades, Decade{Decade: decade})
+		}
+		last := &decades[len(decades)-1]
+		last.Releases = append(last.Releases, release)
+	}
+
+	return ArtistDetail{Artist: artist, Decades: decades}, nil
+}
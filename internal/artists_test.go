@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArtistCrud(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Hot Space', 1982)")
+
+	artist, err := createArtist(db, ArtistInput{Name: " Queen "})
+	assert.NoError(t, err)
+	assert.Equal(t, "Queen", artist.Name)
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, ?)", artist.Id)
+
+	t.Run("Blank Name", func(t *testing.T) {
+		_, err := createArtist(db, ArtistInput{Name: ""})
+
+		var validationErr *ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	t.Run("Update Reindexes Releases", func(t *testing.T) {
+		updated, err := updateArtist(db, artist.Id, ArtistInput{Name: "Queen + Adam Lambert"})
+		assert.NoError(t, err)
+		assert.Equal(t, "Queen + Adam Lambert", updated.Name)
+		assert.Equal(t, []string{"Queen + Adam Lambert"}, searchCredits(t, db, "Lambert"))
+	})
+
+	t.Run("Update Unknown Artist", func(t *testing.T) {
+		_, err := updateArtist(db, 999, ArtistInput{Name: "Nobody"})
+		assert.ErrorIs(t, err, errNotFound)
+	})
+
+	t.Run("Delete Keeps Releases", func(t *testing.T) {
+		assert.NoError(t, deleteArtist(db, artist.Id))
+		assert.Empty(t, searchCredits(t, db, "Lambert"))
+
+		release, err := getRelease(db, 1)
+		assert.NoError(t, err)
+		assert.Empty(t, release.Artists)
+
+		_, err = getArtist(db, artist.Id)
+		assert.ErrorIs(t, err, errNotFound)
+	})
+}
+
+func TestGetArtists(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'Bowie'), (3, 'Queens of the Stone Age'), (4, '50% Off')")
+	mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Under Pressure', 1981), (2, 'Hot Space', 1982)")
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1), (1, 2), (2, 1)")
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id, role) VALUES (2, 1, 'producer')")
+
+	names := func(artists []ArtistSummary) []string {
+		result := []string{}
+		for _, artist := range artists {
+			result = append(result, artist.Name)
+		}
+		return result
+	}
+
+	t.Run("Sorted By Name With Release Counts", func(t *testing.T) {
+		artists, err := getArtists(db, 10, 0, "")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"50% Off", "Bowie", "Queen", "Queens of the Stone Age"}, names(artists))
+		assert.Equal(t, 1, artists[1].ReleaseCount)
+		assert.Equal(t, 2, artists[2].ReleaseCount)
+		assert.Equal(t, 0, artists[3].ReleaseCount)
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		artists, err := getArtists(db, 10, 0, "uee")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Queen", "Queens of the Stone Age"}, names(artists))
+
+		count, err := getArtistsCount(db, "uee")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("Search Keeps Up With Renames", func(t *testing.T) {
+		mustExec(t, db, "UPDATE artists SET name = 'David Bowie' WHERE id = 2")
+
+		artists, err := getArtists(db, 10, 0, "david")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"David Bowie"}, names(artists))
+	})
+
+	t.Run("Short Search Matches Prefixes", func(t *testing.T) {
+		artists, err := getArtists(db, 10, 0, "50")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"50% Off"}, names(artists))
+
+		artists, err = getArtists(db, 10, 0, "%")
+		assert.NoError(t, err)
+		assert.Empty(t, artists)
+	})
+
+	t.Run("Search With FTS Syntax", func(t *testing.T) {
+		artists, err := getArtists(db, 10, 0, `"Queen" OR (`)
+		assert.NoError(t, err)
+		assert.Empty(t, artists)
+	})
+
+	t.Run("Paging", func(t *testing.T) {
+		artists, err := getArtists(db, 2, 2, "")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Queen", "Queens of the Stone Age"}, names(artists))
+	})
+}
+
+func TestGetArtistDetail(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'Bowie')")
+	mustExec(t, db, `INSERT INTO releases (id, name, year) VALUES
+		(1, 'Innuendo', 1991),
+		(2, 'Hot Space', 1982),
+		(3, 'Under Pressure', 1981),
+		(4, 'Made in Heaven', 1995),
+		(5, 'Heroes', 1977)`)
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1), (2, 1), (3, 1), (3, 2), (4, 1), (5, 2)")
+
+	detail, err := getArtistDetail(db, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Queen", detail.Name)
+
+	type decadeNames struct {
+		Decade int
+		Names  []string
+	}
+	var got []decadeNames
+	for _, decade := range detail.Decades {
+		group := decadeNames{Decade: decade.Decade}
+		for _, release := range decade.Releases {
+			group.Names = append(group.Names, release.Name)
+		}
+		got = append(got, group)
+	}
+	assert.Equal(t, []decadeNames{
+		{1980, []string{"Under Pressure", "Hot Space"}},
+		{1990, []string{"Innuendo", "Made in Heaven"}},
+	}, got)
+
+	_, err = getArtistDetail(db, 999)
+	assert.ErrorIs(t, err, errNotFound)
+}
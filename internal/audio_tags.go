@@ -0,0 +1,417 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// audioTags are the tags of an audio file the catalog is built from. Tags
+// can have several values, like a track by two artists.
+type audioTags struct {
+	Album        string
+	AlbumArtists []string
+	Artists      []string
+	Date         string
+}
+
+// errNoAudioTags is returned for files in a format readAudioTags doesn't read
+var errNoAudioTags = errors.New("no ID3v2, FLAC or MP4 tags")
+
+// readAudioTags reads the ID3v2 tags of MP3 files, the Vorbis comments of
+// FLAC files and the iTunes metadata of MP4 files. The format is detected
+// from the content, not the file name.
+func readAudioTags(r io.ReadSeeker) (audioTags, error) {
+	magic := make([]byte, 8)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return audioTags{}, errNoAudioTags
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return audioTags{}, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte("ID3")):
+		tags, err := readID3v2(r)
+		if err != nil {
+			return audioTags{}, err
+		}
+		// FLAC files are sometimes written with an ID3v2 tag in front
+		if flacTags, err := readFlacTags(r); err == nil {
+			return flacTags, nil
+		}
+		return tags, nil
+	case bytes.HasPrefix(magic, []byte("fLaC")):
+		return readFlacTags(r)
+	case string(magic[4:8]) == "ftyp":
+		return readMP4Tags(r)
+	}
+	return audioTags{}, errNoAudioTags
+}
+
+// id3Frames maps the ID3v2.2, v2.3 and v2.4 text frames to the tags they set
+var id3Frames = map[string]func(*audioTags, []string){
+	"TALB": func(t *audioTags, v []string) { t.Album = v[0] },
+	"TAL":  func(t *audioTags, v []string) { t.Album = v[0] },
+	"TPE2": func(t *audioTags, v []string) { t.AlbumArtists = v },
+	"TP2":  func(t *audioTags, v []string) { t.AlbumArtists = v },
+	"TPE1": func(t *audioTags, v []string) { t.Artists = v },
+	"TP1":  func(t *audioTags, v []string) { t.Artists = v },
+	"TDRC": func(t *audioTags, v []string) { t.Date = v[0] },
+	"TYER": func(t *audioTags, v []string) { t.Date = v[0] },
+	"TYE":  func(t *audioTags, v []string) { t.Date = v[0] },
+}
+
+// readID3v2 reads the ID3v2 tag at the start of r and leaves r after it
+func readID3v2(r io.Reader) (audioTags, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return audioTags{}, fmt.Errorf("failed to read ID3v2 header: %w", err)
+	}
+	version, flags := header[3], header[5]
+	if version < 2 || version > 4 {
+		return audioTags{}, fmt.Errorf("unsupported ID3v2.%d tag", version)
+	}
+
+	tag := make([]byte, syncsafeInt(header[6:10]))
+	if _, err := io.ReadFull(r, tag); err != nil {
+		return audioTags{}, fmt.Errorf("failed to read ID3v2 tag: %w", err)
+	}
+	// Before v2.4 unsynchronisation applies to the whole tag, after to each frame
+	if flags&0x80 != 0 && version < 4 {
+		tag = removeUnsynchronisation(tag)
+	}
+	if flags&0x40 != 0 && version > 2 && len(tag) >= 4 {
+		size := int(binary.BigEndian.Uint32(tag))
+		if version == 4 {
+			size = syncsafeInt(tag[:4])
+		} else {
+			size += 4
+		}
+		if size > len(tag) {
+			return audioTags{}, errors.New("invalid ID3v2 extended header")
+		}
+		tag = tag[size:]
+	}
+
+	idSize, headerSize := 4, 10
+	if version == 2 {
+		idSize, headerSize = 3, 6
+	}
+
+	var tags audioTags
+	for len(tag) >= headerSize && tag[0] != 0 {
+		id := string(tag[:idSize])
+		var size int
+		var frameFlags uint16
+		switch version {
+		case 2:
+			size = int(tag[3])<<16 | int(tag[4])<<8 | int(tag[5])
+		case 3:
+			size = int(binary.BigEndian.Uint32(tag[4:8]))
+			frameFlags = binary.BigEndian.Uint16(tag[8:10])
+		case 4:
+			size = syncsafeInt(tag[4:8])
+			frameFlags = binary.BigEndian.Uint16(tag[8:10])
+		}
+		if size > len(tag)-headerSize {
+			return audioTags{}, fmt.Errorf("ID3v2 frame %s overruns the tag", id)
+		}
+		frame := tag[headerSize : headerSize+size]
+		tag = tag[headerSize+size:]
+
+		set, ok := id3Frames[id]
+		if !ok {
+			continue
+		}
+		if version == 4 {
+			// Frames with a data length indicator start with it
+			if frameFlags&0x0001 != 0 && len(frame) >= 4 {
+				frame = frame[4:]
+			}
+			if frameFlags&0x0002 != 0 {
+				frame = removeUnsynchronisation(frame)
+			}
+		}
+		// Compressed and encrypted frames aren't supported
+		if version == 3 && frameFlags&0x00c0 != 0 || version == 4 && frameFlags&0x000c != 0 {
+			continue
+		}
+
+		if values := id3TextValues(frame); len(values) > 0 {
+			set(&tags, values)
+		}
+	}
+	return tags, nil
+}
+
+// syncsafeInt decodes an ID3v2 integer stored in the low 7 bits of each byte
+func syncsafeInt(b []byte) int {
+	n := 0
+	for _, c := range b {
+		n = n<<7 | int(c&0x7f)
+	}
+	return n
+}
+
+// removeUnsynchronisation drops the zero bytes ID3v2 inserts after 0xff
+func removeUnsynchronisation(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte{0xff, 0x00}, []byte{0xff})
+}
+
+// id3TextValues decodes a text frame. ID3v2.4 frames can hold several values
+// separated by null characters.
+func id3TextValues(frame []byte) []string {
+	if len(frame) < 1 {
+		return nil
+	}
+	encoding, data := frame[0], frame[1:]
+
+	var text string
+	switch encoding {
+	case 0:
+		// ISO-8859-1 bytes are the first 256 code points
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		text = string(runes)
+	case 1, 2:
+		text = decodeUTF16(data, encoding == 2)
+	default:
+		text = string(data)
+	}
+	return splitTagValues(strings.Split(text, "\x00"))
+}
+
+// decodeUTF16 decodes UTF-16 text with a byte order mark, or big endian
+// without one. Values after the first can start with their own mark.
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		switch {
+		case data[i] == 0xff && data[i+1] == 0xfe:
+			bigEndian = false
+		case data[i] == 0xfe && data[i+1] == 0xff:
+			bigEndian = true
+		case bigEndian:
+			units = append(units, binary.BigEndian.Uint16(data[i:]))
+		default:
+			units = append(units, binary.LittleEndian.Uint16(data[i:]))
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// splitTagValues trims values and drops the empty ones
+func splitTagValues(values []string) []string {
+	var trimmed []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			trimmed = append(trimmed, value)
+		}
+	}
+	return trimmed
+}
+
+// readFlacTags reads the Vorbis comment block of a FLAC stream at the
+// current position of r
+func readFlacTags(r io.ReadSeeker) (audioTags, error) {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != "fLaC" {
+		return audioTags{}, errors.New("not a FLAC stream")
+	}
+
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return audioTags{}, fmt.Errorf("failed to read FLAC metadata: %w", err)
+		}
+		last, blockType := header[0]&0x80 != 0, header[0]&0x7f
+		size := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+
+		if blockType == 4 {
+			block := make([]byte, size)
+			if _, err := io.ReadFull(r, block); err != nil {
+				return audioTags{}, fmt.Errorf("failed to read Vorbis comments: %w", err)
+			}
+			return parseVorbisComments(block)
+		}
+		if last {
+			return audioTags{}, nil
+		}
+		if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+			return audioTags{}, err
+		}
+	}
+}
+
+// parseVorbisComments reads the KEY=value comments of a Vorbis comment block.
+// Keys ignore case and can be repeated for several values.
+func parseVorbisComments(block []byte) (audioTags, error) {
+	errInvalid := errors.New("invalid Vorbis comment block")
+	next := func() ([]byte, error) {
+		if len(block) < 4 {
+			return nil, errInvalid
+		}
+		size := binary.LittleEndian.Uint32(block)
+		if uint64(size) > uint64(len(block)-4) {
+			return nil, errInvalid
+		}
+		value := block[4 : 4+size]
+		block = block[4+size:]
+		return value, nil
+	}
+
+	// The first field is the vendor string
+	if _, err := next(); err != nil {
+		return audioTags{}, err
+	}
+	if len(block) < 4 {
+		return audioTags{}, errInvalid
+	}
+	count := binary.LittleEndian.Uint32(block)
+	block = block[4:]
+
+	var tags audioTags
+	var dates []string
+	for i := uint32(0); i < count; i++ {
+		comment, err := next()
+		if err != nil {
+			return audioTags{}, err
+		}
+		key, value, ok := strings.Cut(string(comment), "=")
+		if !ok {
+			continue
+		}
+		values := splitTagValues([]string{value})
+		switch strings.ToUpper(key) {
+		case "ALBUM":
+			if tags.Album == "" && len(values) > 0 {
+				tags.Album = values[0]
+			}
+		case "ALBUMARTIST", "ALBUM ARTIST":
+			tags.AlbumArtists = append(tags.AlbumArtists, values...)
+		case "ARTIST":
+			tags.Artists = append(tags.Artists, values...)
+		case "DATE":
+			dates = append(dates, values...)
+		}
+	}
+	if len(dates) > 0 {
+		tags.Date = dates[0]
+	}
+	return tags, nil
+}
+
+// mp4Items maps the iTunes metadata items to the tags they set
+var mp4Items = map[string]func(*audioTags, string){
+	"\xa9alb": func(t *audioTags, v string) { t.Album = v },
+	"aART":    func(t *audioTags, v string) { t.AlbumArtists = []string{v} },
+	"\xa9ART": func(t *audioTags, v string) { t.Artists = []string{v} },
+	"\xa9day": func(t *audioTags, v string) { t.Date = v },
+}
+
+// readMP4Tags reads the moov/udta/meta/ilst metadata items of an MP4 file
+func readMP4Tags(r io.ReadSeeker) (audioTags, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return audioTags{}, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return audioTags{}, err
+	}
+
+	var tags audioTags
+	// Only these boxes are entered, every other box is skipped
+	path := []string{"moov", "udta", "meta", "ilst"}
+	depth := 0
+	for {
+		name, size, err := nextMP4Box(r, end)
+		if errors.Is(err, io.EOF) {
+			return tags, nil
+		}
+		if err != nil {
+			return audioTags{}, err
+		}
+
+		if depth < len(path) && name == path[depth] {
+			depth++
+			end, _ = r.Seek(0, io.SeekCurrent)
+			end += size
+			// meta is a full box, with a version and flags before its children
+			if name == "meta" {
+				if _, err := r.Seek(4, io.SeekCurrent); err != nil {
+					return audioTags{}, err
+				}
+			}
+			continue
+		}
+
+		if set, ok := mp4Items[name]; ok && depth == len(path) {
+			item := make([]byte, size)
+			if _, err := io.ReadFull(r, item); err != nil {
+				return audioTags{}, fmt.Errorf("failed to read MP4 item: %w", err)
+			}
+			if value, ok := mp4ItemText(item); ok {
+				set(&tags, value)
+			}
+			continue
+		}
+
+		if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+			return audioTags{}, err
+		}
+	}
+}
+
+// nextMP4Box reads the header of the box at the current position and
+// returns its type and the size of its content, or io.EOF at end
+func nextMP4Box(r io.ReadSeeker, end int64) (string, int64, error) {
+	offset, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", 0, err
+	}
+	if offset+8 > end {
+		return "", 0, io.EOF
+	}
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", 0, fmt.Errorf("failed to read MP4 box: %w", err)
+	}
+	name := string(header[4:8])
+	size, headerSize := int64(binary.BigEndian.Uint32(header)), int64(8)
+	switch size {
+	case 0:
+		// The box extends to the end of its parent
+		size = end - offset
+	case 1:
+		if _, err := io.ReadFull(r, header); err != nil {
+			return "", 0, fmt.Errorf("failed to read MP4 box: %w", err)
+		}
+		size, headerSize = int64(binary.BigEndian.Uint64(header)), 16
+	}
+	if size < headerSize || offset+size > end {
+		return "", 0, fmt.Errorf("MP4 box %q overruns its parent", name)
+	}
+	return name, size - headerSize, nil
+}
+
+// mp4ItemText returns the UTF-8 text of a metadata item's data box
+func mp4ItemText(item []byte) (string, bool) {
+	// The data box has a type and a locale before the value
+	if len(item) < 16 || string(item[4:8]) != "data" {
+		return "", false
+	}
+	size := int(binary.BigEndian.Uint32(item))
+	if size < 16 || size > len(item) || binary.BigEndian.Uint32(item[8:12]) != 1 {
+		return "", false
+	}
+	value := strings.TrimSpace(string(item[16:size]))
+	return value, value != ""
+}
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadAudioTags(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		tags audioTags
+	}{
+		{
+			"ID3v2.3",
+			"testdata/library/Queen/A Night at the Opera/01 Death on Two Legs.mp3",
+			audioTags{Album: "A Night at the Opera", AlbumArtists: []string{"Queen"}, Artists: []string{"Queen"}, Date: "1975"},
+		},
+		{
+			"ID3v2.4 With Several Artists",
+			"testdata/library/Queen/Hot Space/01 Under Pressure.mp3",
+			audioTags{Album: "Hot Space", Artists: []string{"Queen", "David Bowie"}, Date: "1982-05-21"},
+		},
+		{
+			"FLAC",
+			"testdata/library/Queen/A Night at the Opera/02 Lazing on a Sunday Afternoon.flac",
+			audioTags{Album: "A Night at the Opera", AlbumArtists: []string{"Queen"}, Artists: []string{"Queen"}, Date: "1975-11-21"},
+		},
+		{
+			"MP4",
+			"testdata/library/David Bowie/Low/01 Speed of Life.m4a",
+			audioTags{Album: "Low", AlbumArtists: []string{"David Bowie"}, Artists: []string{"David Bowie"}, Date: "1977-01-14T08:00:00Z"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tags, err := readAudioFileTags(test.path)
+			assert.NoError(t, err)
+			assert.Equal(t, test.tags, tags)
+		})
+	}
+
+	t.Run("Unsupported Files", func(t *testing.T) {
+		_, err := readAudioTags(bytes.NewReader([]byte("not really a flac file")))
+		assert.ErrorIs(t, err, errNoAudioTags)
+
+		_, err = readAudioTags(bytes.NewReader([]byte("ID3")))
+		assert.ErrorIs(t, err, errNoAudioTags)
+	})
+
+	t.Run("Truncated Tags", func(t *testing.T) {
+		data := []byte(mustReadFile(t, "testdata/library/Queen/Hot Space/01 Under Pressure.mp3"))
+		_, err := readAudioTags(bytes.NewReader(data[:30]))
+		assert.Error(t, err)
+
+		data = []byte(mustReadFile(t, "testdata/library/David Bowie/Low/01 Speed of Life.m4a"))
+		_, err = readAudioTags(bytes.NewReader(data[:len(data)-10]))
+		assert.Error(t, err)
+	})
+}
+
+func TestDecodeUTF16(t *testing.T) {
+	assert.Equal(t, "Björk", decodeUTF16([]byte{0xff, 0xfe, 'B', 0, 'j', 0, 0xf6, 0, 'r', 0, 'k', 0}, false))
+	assert.Equal(t, "Björk", decodeUTF16([]byte{0, 'B', 0, 'j', 0, 0xf6, 0, 'r', 0, 'k'}, true))
+}
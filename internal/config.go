@@ -0,0 +1,186 @@
+package internal
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigFile is loaded when it exists and no other config file is given
+const DefaultConfigFile = "config.yaml"
+
+type Config struct {
+	Port            int    `yaml:"port"`
+	DBPath          string `yaml:"db_path"`
+	TemplateDir     string `yaml:"template_dir"`
+	MigrationsDir   string `yaml:"migrations_dir"`
+	StaticDir       string `yaml:"static_dir"`
+	DefaultPageSize int    `yaml:"default_page_size"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Port:            8086,
+		DBPath:          "data.db",
+		TemplateDir:     "internal/templates",
+		MigrationsDir:   "migrations",
+		StaticDir:       "static",
+		DefaultPageSize: 10,
+	}
+}
+
+// Address is the address the web server listens on
+func (c Config) Address() string {
+	return fmt.Sprintf(":%d", c.Port)
+}
+
+func (c Config) Validate() error {
+	var problems []string
+
+	if c.Port < 1 || c.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("port must be between 1 and 65535, got %d", c.Port))
+	}
+	if c.DBPath == "" {
+		problems = append(problems, "db_path is required")
+	}
+	if c.TemplateDir == "" {
+		problems = append(problems, "template_dir is required")
+	}
+	if c.MigrationsDir == "" {
+		problems = append(problems, "migrations_dir is required")
+	}
+	if c.StaticDir == "" {
+		problems = append(problems, "static_dir is required")
+	}
+	if c.DefaultPageSize < 1 {
+		problems = append(problems, fmt.Sprintf("default_page_size must be at least 1, got %d", c.DefaultPageSize))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// configSetting describes how one Config field is read from the environment and flags
+type configSetting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+func stringSetting(field func(c *Config) *string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func intSetting(field func(c *Config) *int) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*field(c) = i
+		return nil
+	}
+}
+
+var configSettings = []configSetting{
+	{"PORT", "port", "Port the web server listens on", intSetting(func(c *Config) *int { return &c.Port })},
+	{"DB_PATH", "db", "Path of the SQLite database file", stringSetting(func(c *Config) *string { return &c.DBPath })},
+	{"TEMPLATE_DIR", "templates", "Directory containing the HTML templates", stringSetting(func(c *Config) *string { return &c.TemplateDir })},
+	{"MIGRATIONS_DIR", "migrations", "Directory containing the database migrations", stringSetting(func(c *Config) *string { return &c.MigrationsDir })},
+	{"STATIC_DIR", "static", "Directory served under /static", stringSetting(func(c *Config) *string { return &c.StaticDir })},
+	{"DEFAULT_PAGE_SIZE", "page-size", "Number of results per page when page_size is not given", intSetting(func(c *Config) *int { return &c.DefaultPageSize })},
+}
+
+// RegisterConfigFlags adds a flag for the config file and for every setting to flags
+func RegisterConfigFlags(flags *flag.FlagSet) {
+	flags.String("config", "", fmt.Sprintf("Path of a YAML config file (default %s when it exists, or $CONFIG_FILE)", DefaultConfigFile))
+	for _, setting := range configSettings {
+		flags.String(setting.flag, "", fmt.Sprintf("%s (or $%s)", setting.usage, setting.env))
+	}
+}
+
+// LoadConfig builds the config from the defaults, then the config file, then
+// environment variables, then any flags registered by RegisterConfigFlags that
+// were set on the command line. Later sources take precedence.
+func LoadConfig(flags *flag.FlagSet, getenv func(string) string) (Config, error) {
+	config := DefaultConfig()
+
+	setFlags := map[string]string{}
+	if flags != nil {
+		flags.Visit(func(f *flag.Flag) {
+			setFlags[f.Name] = f.Value.String()
+		})
+	}
+
+	// Config file
+	path, required := setFlags["config"], true
+	if path == "" {
+		path = getenv("CONFIG_FILE")
+	}
+	if path == "" {
+		path, required = DefaultConfigFile, false
+	}
+	if err := loadConfigFile(&config, path, required); err != nil {
+		return Config{}, err
+	}
+
+	// Environment variables
+	for _, setting := range configSettings {
+		if value := getenv(setting.env); value != "" {
+			if err := setting.set(&config, value); err != nil {
+				return Config{}, fmt.Errorf("invalid $%s: %w", setting.env, err)
+			}
+		}
+	}
+
+	// Flags
+	for _, setting := range configSettings {
+		if value, ok := setFlags[setting.flag]; ok {
+			if err := setting.set(&config, value); err != nil {
+				return Config{}, fmt.Errorf("invalid -%s: %w", setting.flag, err)
+			}
+		}
+	}
+
+	return config, config.Validate()
+}
+
+func loadConfigFile(config *Config, path string, required bool) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteYAML writes the config in the same format LoadConfig reads
+func (c Config) WriteYAML(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
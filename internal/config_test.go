@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	// Run from an empty directory so a config.yaml in the package is never picked up
+	workingDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(workingDir)
+
+	noEnv := func(string) string { return "" }
+
+	envFrom := func(env map[string]string) func(string) string {
+		return func(key string) string { return env[key] }
+	}
+
+	parsedFlags := func(t *testing.T, args ...string) *flag.FlagSet {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		RegisterConfigFlags(flags)
+		if err := flags.Parse(args); err != nil {
+			t.Fatalf("Failed to parse flags: %v", err)
+		}
+		return flags
+	}
+
+	writeConfigFile := func(t *testing.T, name string, contents string) string {
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatalf("Failed to write config file: %v", err)
+		}
+		return path
+	}
+
+	t.Run("Defaults", func(t *testing.T) {
+		config, err := LoadConfig(parsedFlags(t), noEnv)
+		assert.NoError(t, err)
+		assert.Equal(t, DefaultConfig(), config)
+		assert.Equal(t, ":8086", config.Address())
+	})
+
+	t.Run("Precedence", func(t *testing.T) {
+		path := writeConfigFile(t, "config.yaml", "port: 9000\ndb_path: file.db\ntemplate_dir: file-templates\ndefault_page_size: 25\n")
+		env := envFrom(map[string]string{
+			"CONFIG_FILE": path,
+			"DB_PATH":     "env.db",
+			"PORT":        "9001",
+		})
+
+		config, err := LoadConfig(parsedFlags(t, "-port", "9002"), env)
+		assert.NoError(t, err)
+		assert.Equal(t, 9002, config.Port)
+		assert.Equal(t, "env.db", config.DBPath)
+		assert.Equal(t, "file-templates", config.TemplateDir)
+		assert.Equal(t, 25, config.DefaultPageSize)
+		assert.Equal(t, "migrations", config.MigrationsDir)
+	})
+
+	t.Run("Config Flag Overrides Environment", func(t *testing.T) {
+		flagPath := writeConfigFile(t, "flag.yaml", "port: 9100\n")
+		env := envFrom(map[string]string{"CONFIG_FILE": filepath.Join(t.TempDir(), "missing.yaml")})
+
+		config, err := LoadConfig(parsedFlags(t, "-config", flagPath), env)
+		assert.NoError(t, err)
+		assert.Equal(t, 9100, config.Port)
+	})
+
+	t.Run("Default Config File Is Optional", func(t *testing.T) {
+		_, err := LoadConfig(nil, noEnv)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Explicit Config File Must Exist", func(t *testing.T) {
+		_, err := LoadConfig(parsedFlags(t, "-config", filepath.Join(t.TempDir(), "missing.yaml")), noEnv)
+		assert.Error(t, err)
+	})
+
+	t.Run("Unknown Key", func(t *testing.T) {
+		path := writeConfigFile(t, "config.yaml", "prot: 9000\n")
+
+		_, err := LoadConfig(parsedFlags(t, "-config", path), noEnv)
+		assert.ErrorContains(t, err, "prot")
+	})
+
+	t.Run("Invalid Number", func(t *testing.T) {
+		_, err := LoadConfig(parsedFlags(t), envFrom(map[string]string{"PORT": "eighty"}))
+		assert.ErrorContains(t, err, "$PORT")
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		_, err := LoadConfig(parsedFlags(t, "-port", "70000", "-db", "", "-page-size", "0"), noEnv)
+		assert.ErrorContains(t, err, "port must be between 1 and 65535")
+		assert.ErrorContains(t, err, "db_path is required")
+		assert.ErrorContains(t, err, "default_page_size must be at least 1")
+	})
+
+	t.Run("Round Trip", func(t *testing.T) {
+		config := DefaultConfig()
+		config.Port = 9200
+		config.DBPath = "/var/lib/simple-web-app/data.db"
+
+		var out bytes.Buffer
+		assert.NoError(t, config.WriteYAML(&out))
+		path := writeConfigFile(t, "config.yaml", out.String())
+
+		loaded, err := LoadConfig(parsedFlags(t, "-config", path), noEnv)
+		assert.NoError(t, err)
+		assert.Equal(t, config, loaded)
+	})
+}
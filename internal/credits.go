@@ -0,0 +1,196 @@
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Roles an artist can be credited with on a release
+const (
+	RoleMain      = "main"
+	RoleFeaturing = "featuring"
+	RoleProducer  = "producer"
+	RoleRemixer   = "remixer"
+)
+
+// CreditRoles lists the roles in the order the release form offers them
+var CreditRoles = []string{RoleMain, RoleFeaturing, RoleProducer, RoleRemixer}
+
+// JoinPhrases are the join phrases the release form suggests
+var JoinPhrases = []string{"&", "feat.", "vs.", "and", "with", "x"}
+
+// maxJoinPhraseLength keeps join phrases to a few words
+const maxJoinPhraseLength = 20
+
+// Credit is an artist credited on a release. JoinPhrase is the text between
+// this credit and the next, like "&" or "feat.", empty for the default.
+type Credit struct {
+	Artist
+	Role       string `json:"role"`
+	JoinPhrase string `json:"join_phrase"`
+}
+
+// CreditInput credits an existing artist on a release. An empty role is a
+// main credit.
+type CreditInput struct {
+	ArtistId   int    `json:"artist_id"`
+	Role       string `json:"role"`
+	JoinPhrase string `json:"join_phrase"`
+}
+
+// CreditPart is a credit in the credit string of a release, with the text
+// joining it to the next credit
+type CreditPart struct {
+	Credit
+	Join string
+}
+
+// isShownRole reports whether credits with the role are part of the credit
+// string shown with a release. Producers and remixers are listed separately.
+func isShownRole(role string) bool {
+	return role == RoleMain || role == RoleFeaturing
+}
+
+// creditParts returns the main and featuring credits in order, each with
+// the text joining it to the next. Without a join phrase credits are joined
+// with a comma, or with "feat." before a featuring credit. This matches the
+// artist_credit column of releases_fts_rows.
+func creditParts(credits []Credit) []CreditPart {
+	parts := []CreditPart{}
+	for _, credit := range credits {
+		if isShownRole(credit.Role) {
+			parts = append(parts, CreditPart{Credit: credit})
+		}
+	}
+
+	for i := 0; i < len(parts)-1; i++ {
+		switch phrase := parts[i].JoinPhrase; {
+		case phrase == "" && parts[i+1].Role == RoleFeaturing:
+			parts[i].Join = " feat. "
+		case phrase == "" || phrase == ",":
+			parts[i].Join = ", "
+		default:
+			parts[i].Join = " " + phrase + " "
+		}
+	}
+	return parts
+}
+
+// creditString renders credits the way they're shown with a release, like
+// "Queen & David Bowie"
+func creditString(credits []Credit) string {
+	var b strings.Builder
+	for _, part := range creditParts(credits) {
+		b.WriteString(part.Name)
+		b.WriteString(part.Join)
+	}
+	return b.String()
+}
+
+// mainCredits credits each artist as a main artist, in order
+func mainCredits(artistIds []int) []CreditInput {
+	credits := make([]CreditInput, len(artistIds))
+	for i, artistId := range artistIds {
+		credits[i] = CreditInput{ArtistId: artistId, Role: RoleMain}
+	}
+	return credits
+}
+
+// normalizeCredits drops blank form rows, defaults roles to main and trims
+// join phrases. It returns a message describing the first invalid credit.
+func normalizeCredits(tx *sql.Tx, credits []CreditInput) ([]CreditInput, string, error) {
+	normalized := []CreditInput{}
+	for _, credit := range credits {
+		if credit.ArtistId == 0 {
+			continue
+		}
+		if credit.Role == "" {
+			credit.Role = RoleMain
+		}
+		credit.JoinPhrase = strings.TrimSpace(credit.JoinPhrase)
+
+		var exists bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM artists WHERE id = ?)", credit.ArtistId).Scan(&exists)
+		if err != nil {
+			return nil, "", err
+		}
+		if !exists {
+			return nil, fmt.Sprintf("Artist %d does not exist", credit.ArtistId), nil
+		}
+
+		if !slices.Contains(CreditRoles, credit.Role) {
+			return nil, fmt.Sprintf("Unknown role %q, use main, featuring, producer or remixer", credit.Role), nil
+		}
+
+		if utf8.RuneCountInString(credit.JoinPhrase) > maxJoinPhraseLength {
+			return nil, fmt.Sprintf("Join phrases can be at most %d characters", maxJoinPhraseLength), nil
+		}
+
+		normalized = append(normalized, credit)
+	}
+	return normalized, "", nil
+}
+
+// setReleaseCredits replaces the artist credits of a release, keeping their
+// order. An artist credited twice with the same role keeps the first credit.
+// The release_artists triggers keep releases_fts in sync.
+func setReleaseCredits(tx *sql.Tx, releaseId int, credits []CreditInput) error {
+	if _, err := tx.Exec("DELETE FROM release_artists WHERE release_id = ?", releaseId); err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	position := 0
+	for _, credit := range credits {
+		key := fmt.Sprintf("%d\n%s", credit.ArtistId, credit.Role)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		position++
+
+		_, err := tx.Exec(
+			"INSERT INTO release_artists (release_id, artist_id, role, position, join_phrase) VALUES (?, ?, ?, ?, ?)",
+			releaseId, credit.ArtistId, credit.Role, position, credit.JoinPhrase,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getReleasesCredits loads the credits of the releases with the ids in args,
+// in credit order. placeholders is the "?,?" list matching args.
+func getReleasesCredits(db *sql.DB, placeholders string, args []interface{}) (map[int][]Credit, error) {
+	rows, err := db.Query(`
+		SELECT
+			release_artists.release_id,
+			artists.id,
+			artists.name,
+			release_artists.role,
+			release_artists.join_phrase
+		FROM release_artists
+		JOIN artists ON release_artists.artist_id = artists.id
+		WHERE release_artists.release_id IN (`+placeholders+`)
+		ORDER BY release_artists.release_id, release_artists.position, release_artists.id;
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credits := map[int][]Credit{}
+	for rows.Next() {
+		var releaseId int
+		var credit Credit
+		if err := rows.Scan(&releaseId, &credit.Id, &credit.Name, &credit.Role, &credit.JoinPhrase); err != nil {
+			return nil, err
+		}
+		credits[releaseId] = append(credits[releaseId], credit)
+	}
+	return credits, rows.Err()
+}
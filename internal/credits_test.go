@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"errors"
+	"html/template"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreditString(t *testing.T) {
+	queen := Artist{Id: 1, Name: "Queen"}
+	bowie := Artist{Id: 2, Name: "David Bowie"}
+	eno := Artist{Id: 3, Name: "Brian Eno"}
+
+	tests := []struct {
+		name     string
+		credits  []Credit
+		expected string
+	}{
+		{"No Credits", nil, ""},
+		{"Single Artist", []Credit{{Artist: queen, Role: RoleMain}}, "Queen"},
+		{"Join Phrase", []Credit{{Artist: queen, Role: RoleMain, JoinPhrase: "&"}, {Artist: bowie, Role: RoleMain}}, "Queen & David Bowie"},
+		{"Comma By Default", []Credit{{Artist: queen, Role: RoleMain}, {Artist: bowie, Role: RoleMain}}, "Queen, David Bowie"},
+		{"Featuring By Default", []Credit{{Artist: bowie, Role: RoleMain}, {Artist: queen, Role: RoleFeaturing}}, "David Bowie feat. Queen"},
+		{"Producers Left Out", []Credit{{Artist: bowie, Role: RoleMain, JoinPhrase: "vs."}, {Artist: eno, Role: RoleProducer}, {Artist: queen, Role: RoleMain}}, "David Bowie vs. Queen"},
+		{"Last Join Phrase Ignored", []Credit{{Artist: queen, Role: RoleMain, JoinPhrase: "&"}}, "Queen"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, creditString(tt.credits))
+		})
+	}
+}
+
+func TestReleaseCredits(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'David Bowie'), (3, 'David Richards')")
+
+	var underPressure Release
+
+	t.Run("Create With Credits", func(t *testing.T) {
+		var err error
+		underPressure, err = createRelease(db, ReleaseInput{
+			Name: "Under Pressure",
+			Year: 1981,
+			Credits: []CreditInput{
+				{ArtistId: 1, JoinPhrase: " & "},
+				{ArtistId: 2, Role: RoleMain},
+				{ArtistId: 3, Role: RoleProducer},
+				{ArtistId: 2, Role: RoleMain},
+				{},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "Queen & David Bowie", underPressure.Credit)
+		// Repeated credits and blank rows are skipped
+		assert.Equal(t, []Credit{
+			{Artist: Artist{Id: 1, Name: "Queen"}, Role: RoleMain, JoinPhrase: "&"},
+			{Artist: Artist{Id: 2, Name: "David Bowie"}, Role: RoleMain},
+			{Artist: Artist{Id: 3, Name: "David Richards"}, Role: RoleProducer},
+		}, underPressure.Credits)
+		assert.Equal(t, []Credit{{Artist: Artist{Id: 3, Name: "David Richards"}, Role: RoleProducer}}, underPressure.CreditsWithRole(RoleProducer))
+		assert.Equal(t, []string{"Queen", "David Bowie", "David Richards"}, artistNames(underPressure.Artists))
+	})
+
+	t.Run("Artist Ids Are Main Credits", func(t *testing.T) {
+		release, err := createRelease(db, ReleaseInput{Name: "Hot Space", Year: 1982, ArtistIds: []int{1, 2}})
+		assert.NoError(t, err)
+		assert.Equal(t, "Queen, David Bowie", release.Credit)
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		_, err := createRelease(db, ReleaseInput{Name: "Heroes", Year: 1977, Credits: []CreditInput{{ArtistId: 2, Role: "drummer"}}})
+		var validationErr *ValidationError
+		if assert.True(t, errors.As(err, &validationErr)) {
+			assert.Equal(t, map[string]string{"credits": `Unknown role "drummer", use main, featuring, producer or remixer`}, validationErr.Fields)
+		}
+
+		_, err = createRelease(db, ReleaseInput{Name: "Heroes", Year: 1977, Credits: []CreditInput{{ArtistId: 9}}})
+		if assert.True(t, errors.As(err, &validationErr)) {
+			assert.Equal(t, map[string]string{"credits": "Artist 9 does not exist"}, validationErr.Fields)
+		}
+	})
+
+	t.Run("Indexed Credit Matches Rendered Credit", func(t *testing.T) {
+		mustExec(t, db, "UPDATE release_artists SET role = 'featuring', join_phrase = '' WHERE release_id = ? AND artist_id = 2", underPressure.Id)
+		release, err := getRelease(db, underPressure.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, "Queen & David Bowie", release.Credit)
+
+		mustExec(t, db, "UPDATE release_artists SET join_phrase = '' WHERE release_id = ?", underPressure.Id)
+		release, err = getRelease(db, underPressure.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, "Queen feat. David Bowie", release.Credit)
+
+		var indexed string
+		err = db.QueryRow("SELECT artist_credit FROM releases_fts WHERE rowid = ?", underPressure.Id).Scan(&indexed)
+		assert.NoError(t, err)
+		assert.Equal(t, release.Credit, indexed)
+	})
+
+	t.Run("Listed Once With Its Credit", func(t *testing.T) {
+		// Two main artists and a producer are still one release row
+		releases, err := getReleases(db, 10, 0, mustParseSearchQuery(t, "pressure"), SortYear, nil)
+		assert.NoError(t, err)
+		if assert.Len(t, releases, 1) {
+			assert.Equal(t, underPressure.Id, releases[0]["release_id"])
+			assert.Equal(t, "Queen feat. David Bowie", releases[0]["artist_credit"])
+		}
+
+		count, err := getReleasesCount(db, mustParseSearchQuery(t, "pressure"))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("Search By Credit", func(t *testing.T) {
+		releases, err := getReleases(db, 10, 0, mustParseSearchQuery(t, `"queen feat. david"`), SortYear, nil)
+		assert.NoError(t, err)
+		if assert.NotEmpty(t, releases) {
+			assert.Equal(t, "Queen feat. David Bowie", releases[0]["artist_credit"])
+			assert.Equal(t, "<mark>Queen feat. David</mark> Bowie", string(releases[0]["artist_credit_html"].(template.HTML)))
+		}
+
+		releases, err = getReleases(db, 10, 0, mustParseSearchQuery(t, `artist:"queen feat. david bowie"`), SortYear, nil)
+		assert.NoError(t, err)
+		assert.Len(t, releases, 1)
+
+		// Producers aren't part of the credit but still find the release
+		releases, err = getReleases(db, 10, 0, mustParseSearchQuery(t, "artist:richards"), SortYear, nil)
+		assert.NoError(t, err)
+		if assert.Len(t, releases, 1) {
+			assert.Equal(t, "Queen feat. David Bowie", releases[0]["artist_credit"])
+		}
+	})
+}
@@ -0,0 +1,343 @@
+package internal
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// csvArtistSeparator separates the artists of a release in the artists column
+const csvArtistSeparator = ";"
+
+// CSVColumns names the CSV columns holding each release field. Name and
+// Year are required. Rows with an Id update that release instead of
+// creating one, and Artists holds artist names separated by semicolons.
+type CSVColumns struct {
+	Id      string
+	Name    string
+	Year    string
+	Artists string
+}
+
+// DefaultCSVColumns are the columns written by ExportCSV
+var DefaultCSVColumns = CSVColumns{Id: "id", Name: "name", Year: "year", Artists: "artists"}
+
+// ParseCSVColumns parses a mapping from release fields to CSV columns such as
+// "name=Album,year=Released". Fields left out keep their default column.
+func ParseCSVColumns(mapping string) (CSVColumns, error) {
+	columns := DefaultCSVColumns
+	if strings.TrimSpace(mapping) == "" {
+		return columns, nil
+	}
+
+	for _, pair := range strings.Split(mapping, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		field, column = strings.ToLower(strings.TrimSpace(field)), strings.TrimSpace(column)
+		if !ok || column == "" {
+			return CSVColumns{}, fmt.Errorf("invalid column mapping %q, use field=column", pair)
+		}
+
+		switch field {
+		case "id":
+			columns.Id = column
+		case "name":
+			columns.Name = column
+		case "year":
+			columns.Year = column
+		case "artists":
+			columns.Artists = column
+		default:
+			return CSVColumns{}, fmt.Errorf("unknown field %q, use id, name, year or artists", field)
+		}
+	}
+	return columns, nil
+}
+
+// csvIndexes are the positions of the release fields in a CSV row, -1 when
+// the CSV has no such column
+type csvIndexes struct {
+	id, name, year, artists int
+}
+
+// indexes finds the columns in header, ignoring case. Name and year must be
+// present, and so must id and artists when they were mapped to other columns.
+func (c CSVColumns) indexes(header []string) (csvIndexes, error) {
+	find := func(field string, column string, required bool) (int, error) {
+		for i, name := range header {
+			if strings.EqualFold(strings.TrimSpace(name), column) {
+				return i, nil
+			}
+		}
+		if required {
+			return -1, fmt.Errorf("missing %q column for %s", column, field)
+		}
+		return -1, nil
+	}
+
+	var indexes csvIndexes
+	var err error
+	if indexes.id, err = find("id", c.Id, c.Id != DefaultCSVColumns.Id); err != nil {
+		return csvIndexes{}, err
+	}
+	if indexes.name, err = find("name", c.Name, true); err != nil {
+		return csvIndexes{}, err
+	}
+	if indexes.year, err = find("year", c.Year, true); err != nil {
+		return csvIndexes{}, err
+	}
+	if indexes.artists, err = find("artists", c.Artists, c.Artists != DefaultCSVColumns.Artists); err != nil {
+		return csvIndexes{}, err
+	}
+	return indexes, nil
+}
+
+// CSVRowError is a rejected CSV row, with the reason each field is invalid
+type CSVRowError struct {
+	Line   int
+	Fields map[string]string
+}
+
+func (e CSVRowError) String() string {
+	messages := make([]string, 0, len(e.Fields))
+	for field, message := range e.Fields {
+		messages = append(messages, field+": "+message)
+	}
+	sort.Strings(messages)
+	return fmt.Sprintf("line %d: %s", e.Line, strings.Join(messages, ", "))
+}
+
+// CSVImportReport counts the releases and artists written by a CSV import,
+// or that would be written by a dry run, and lists the rejected rows
+type CSVImportReport struct {
+	Created        int
+	Updated        int
+	ArtistsCreated int
+	Errors         []CSVRowError
+}
+
+// ImportCSV reads releases from a CSV file with a header row. Valid rows
+// are imported and invalid ones are reported in the returned report. Artists
+// are matched by name, ignoring case, and created when there's none. With
+// dryRun every row is validated and counted but nothing is written.
+//
+// A malformed CSV file stops the import with an error and nothing written.
+func ImportCSV(db *sql.DB, r io.Reader, columns CSVColumns, dryRun bool) (CSVImportReport, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return CSVImportReport{}, errors.New("empty CSV file")
+	}
+	if err != nil {
+		return CSVImportReport{}, err
+	}
+	// Spreadsheet programs often start UTF-8 files with a byte order mark
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+
+	indexes, err := columns.indexes(header)
+	if err != nil {
+		return CSVImportReport{}, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return CSVImportReport{}, err
+	}
+	defer tx.Rollback()
+
+	var report CSVImportReport
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return CSVImportReport{}, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		if err := importCSVRow(tx, record, indexes, line, &report); err != nil {
+			return CSVImportReport{}, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	return report, tx.Commit()
+}
+
+// importCSVRow imports one row, or adds it to the report's errors when it's invalid
+func importCSVRow(tx *sql.Tx, record []string, indexes csvIndexes, line int, report *CSVImportReport) error {
+	field := func(index int) string {
+		if index < 0 || index >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[index])
+	}
+
+	// Skip rows left blank in the spreadsheet
+	if strings.TrimSpace(strings.Join(record, "")) == "" {
+		return nil
+	}
+
+	input := ReleaseInput{Name: field(indexes.name)}
+	fields := map[string]string{}
+	if year, err := strconv.Atoi(field(indexes.year)); err == nil {
+		input.Year = year
+	} else {
+		fields["year"] = "Year must be a number"
+	}
+
+	releaseId := 0
+	if id := field(indexes.id); id != "" {
+		var err error
+		releaseId, err = strconv.Atoi(id)
+		if err != nil || releaseId < 1 {
+			fields["id"] = "Id must be a positive number"
+		} else if exists, err := releaseExists(tx, releaseId); err != nil {
+			return err
+		} else if !exists {
+			fields["id"] = fmt.Sprintf("Release %d does not exist", releaseId)
+		}
+	}
+
+	var validationErr *ValidationError
+	if err := input.validate(tx); errors.As(err, &validationErr) {
+		for name, message := range validationErr.Fields {
+			if _, ok := fields[name]; !ok {
+				fields[name] = message
+			}
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if len(fields) > 0 {
+		report.Errors = append(report.Errors, CSVRowError{Line: line, Fields: fields})
+		return nil
+	}
+
+	var artistIds []int
+	for _, name := range strings.Split(field(indexes.artists), csvArtistSeparator) {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		artistId, created, err := findOrCreateArtist(tx, name)
+		if err != nil {
+			return err
+		}
+		if created {
+			report.ArtistsCreated++
+		}
+		artistIds = append(artistIds, artistId)
+	}
+
+	if releaseId != 0 {
+		// A release date in another year than the new one no longer applies
+		_, err := tx.Exec(`
+			UPDATE releases
+			SET name = ?, year = ?, release_date = CASE WHEN substr(release_date, 1, 4) = printf('%04d', ?) THEN release_date ELSE '' END
+			WHERE id = ?`,
+			input.Name, input.Year, input.Year, releaseId,
+		)
+		if err != nil {
+			return err
+		}
+		report.Updated++
+
+		// Rows without artists keep the release's credits, and so do rows
+		// listing the artists it's already credited, keeping the roles and
+		// join phrases entered in the form
+		if len(artistIds) == 0 {
+			return nil
+		}
+		credited, err := creditedArtistIds(tx, releaseId)
+		if err != nil {
+			return err
+		}
+		if sameArtists(credited, artistIds) {
+			return nil
+		}
+	} else {
+		result, err := tx.Exec("INSERT INTO releases (name, year) VALUES (?, ?)", input.Name, input.Year)
+		if err != nil {
+			return err
+		}
+		insertedId, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		releaseId = int(insertedId)
+		report.Created++
+	}
+
+	return setReleaseArtists(tx, releaseId, artistIds)
+}
+
+// creditedArtistIds returns the ids of the artists credited on a release in any role
+func creditedArtistIds(tx *sql.Tx, releaseId int) (map[int]bool, error) {
+	rows, err := tx.Query("SELECT artist_id FROM release_artists WHERE release_id = ?", releaseId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	artistIds := map[int]bool{}
+	for rows.Next() {
+		var artistId int
+		if err := rows.Scan(&artistId); err != nil {
+			return nil, err
+		}
+		artistIds[artistId] = true
+	}
+	return artistIds, rows.Err()
+}
+
+// sameArtists reports whether artistIds are exactly the credited artists, in any order
+func sameArtists(credited map[int]bool, artistIds []int) bool {
+	listed := map[int]bool{}
+	for _, artistId := range artistIds {
+		if !credited[artistId] {
+			return false
+		}
+		listed[artistId] = true
+	}
+	return len(listed) == len(credited)
+}
+
+func releaseExists(tx *sql.Tx, releaseId int) (bool, error) {
+	var exists bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM releases WHERE id = ?)", releaseId).Scan(&exists)
+	return exists, err
+}
+
+// findOrCreateArtist returns the oldest artist named name, ignoring case,
+// creating one when there's none
+func findOrCreateArtist(tx *sql.Tx, name string) (int, bool, error) {
+	var artistId int
+	err := tx.QueryRow("SELECT id FROM artists WHERE name = ? COLLATE NOCASE ORDER BY id LIMIT 1", name).Scan(&artistId)
+	if err == nil {
+		return artistId, false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, false, err
+	}
+
+	result, err := tx.Exec("INSERT INTO artists (name) VALUES (?)", name)
+	if err != nil {
+		return 0, false, err
+	}
+	insertedId, err := result.LastInsertId()
+	if err != nil {
+		return 0, false, err
+	}
+	return int(insertedId), true, nil
+}
@@ -0,0 +1,199 @@
+package internal
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCSVColumns(t *testing.T) {
+	columns, err := ParseCSVColumns("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultCSVColumns, columns)
+
+	columns, err = ParseCSVColumns(" name = Album ,Year=Released")
+	assert.NoError(t, err)
+	assert.Equal(t, CSVColumns{Id: "id", Name: "Album", Year: "Released", Artists: "artists"}, columns)
+
+	_, err = ParseCSVColumns("name")
+	assert.ErrorContains(t, err, "use field=column")
+
+	_, err = ParseCSVColumns("label=Label")
+	assert.ErrorContains(t, err, `unknown field "label"`)
+}
+
+func TestImportCSV(t *testing.T) {
+	spreadsheetColumns := CSVColumns{Id: "id", Name: "Album", Year: "Released", Artists: "Performers"}
+
+	t.Run("Mapped Columns", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen')")
+
+		file, err := os.Open("testdata/csv/spreadsheet.csv")
+		if err != nil {
+			t.Fatalf("Failed to open fixture: %v", err)
+		}
+		defer file.Close()
+
+		report, err := ImportCSV(db, file, spreadsheetColumns, false)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, report.Created)
+		assert.Equal(t, 0, report.Updated)
+		assert.Equal(t, 1, report.ArtistsCreated)
+
+		// Blank rows are skipped, and rejected rows keep their line in the file
+		if assert.Len(t, report.Errors, 2) {
+			assert.Equal(t, "line 5: year: Year must be a number", report.Errors[0].String())
+			assert.Equal(t, "line 6: name: Name is required", report.Errors[1].String())
+		}
+
+		assert.Equal(t, 3, countRows(t, db, "releases"))
+		assert.Equal(t, 2, countRows(t, db, "artists"))
+
+		releases, err := getReleases(db, 10, 0, mustParseSearchQuery(t, "pressure"), SortYear, nil)
+		assert.NoError(t, err)
+		if assert.Len(t, releases, 1) {
+			release, err := getRelease(db, releases[0]["release_id"].(int))
+			assert.NoError(t, err)
+			assert.Equal(t, 1981, release.Year)
+			// Artists are matched ignoring case
+			assert.Equal(t, []string{"Queen", "David Bowie"}, artistNames(release.Artists))
+		}
+	})
+
+	t.Run("Dry Run Writes Nothing", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+
+		report, err := ImportCSV(db, strings.NewReader(mustReadFile(t, "testdata/csv/spreadsheet.csv")), spreadsheetColumns, true)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, report.Created)
+		assert.Equal(t, 2, report.ArtistsCreated)
+		assert.Len(t, report.Errors, 2)
+
+		assert.Equal(t, 0, countRows(t, db, "releases"))
+		assert.Equal(t, 0, countRows(t, db, "artists"))
+	})
+
+	t.Run("Updates By Id", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'David Bowie')")
+		mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Jazz', 1977)")
+		mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1)")
+
+		csv := "id,name,year,artists\n1,Jazz,1978,David Bowie\n7,Ghost,1990,\nx,Bad Id,1990,\n"
+		report, err := ImportCSV(db, strings.NewReader(csv), DefaultCSVColumns, false)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, report.Created)
+		assert.Equal(t, 1, report.Updated)
+		assert.Equal(t, 0, report.ArtistsCreated)
+		if assert.Len(t, report.Errors, 2) {
+			assert.Equal(t, "line 3: id: Release 7 does not exist", report.Errors[0].String())
+			assert.Equal(t, "line 4: id: Id must be a positive number", report.Errors[1].String())
+		}
+
+		release, err := getRelease(db, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 1978, release.Year)
+		assert.Equal(t, []string{"David Bowie"}, artistNames(release.Artists))
+	})
+
+	t.Run("Updates Keep Release Dates In The Same Year", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		mustExec(t, db, "INSERT INTO releases (id, name, year, release_date) VALUES (1, 'A Night at the Opera', 1975, '1975-11-21')")
+
+		report, err := ImportCSV(db, strings.NewReader("id,name,year\n1,A Night at the Opera,1975\n"), DefaultCSVColumns, false)
+		assert.NoError(t, err)
+		assert.Equal(t, CSVImportReport{Updated: 1}, report)
+		release, err := getRelease(db, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "1975-11-21", release.ReleaseDate)
+
+		// A new year clears the date, so the release can still be saved in the form
+		report, err = ImportCSV(db, strings.NewReader("id,name,year\n1,A Night at the Opera,1980\n"), DefaultCSVColumns, false)
+		assert.NoError(t, err)
+		assert.Equal(t, CSVImportReport{Updated: 1}, report)
+		release, err = getRelease(db, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 1980, release.Year)
+		assert.Empty(t, release.ReleaseDate)
+
+		_, err = updateRelease(db, 1, ReleaseInput{Name: release.Name, Year: release.Year, ReleaseDate: release.ReleaseDate})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Updates Keep Credits", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'David Bowie')")
+		mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Under Pressure', 1981)")
+		mustExec(t, db, `INSERT INTO release_artists (release_id, artist_id, role, position, join_phrase) VALUES
+			(1, 1, 'main', 1, '&'),
+			(1, 2, 'main', 2, '')`)
+
+		// Without an artists column the credits are left alone
+		report, err := ImportCSV(db, strings.NewReader("id,name,year\n1,Renamed,1981\n"), DefaultCSVColumns, false)
+		assert.NoError(t, err)
+		assert.Equal(t, CSVImportReport{Updated: 1}, report)
+		release, err := getRelease(db, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "Renamed", release.Name)
+		assert.Equal(t, "Queen & David Bowie", release.Credit)
+		assert.Equal(t, []string{"Queen & David Bowie"}, searchCredits(t, db, "Renamed"))
+
+		// Nor are they with a blank artists cell, or the same artists in any order
+		csv := "id,name,year,artists\n1,Renamed,1981,\n1,Renamed,1981,david bowie; Queen\n"
+		report, err = ImportCSV(db, strings.NewReader(csv), DefaultCSVColumns, false)
+		assert.NoError(t, err)
+		assert.Equal(t, CSVImportReport{Updated: 2}, report)
+		release, err = getRelease(db, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "Queen & David Bowie", release.Credit)
+
+		// Other artists replace the credits
+		report, err = ImportCSV(db, strings.NewReader("id,name,year,artists\n1,Renamed,1981,Queen\n"), DefaultCSVColumns, false)
+		assert.NoError(t, err)
+		assert.Equal(t, CSVImportReport{Updated: 1}, report)
+		release, err = getRelease(db, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "Queen", release.Credit)
+	})
+
+	t.Run("Missing Column", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+
+		_, err := ImportCSV(db, strings.NewReader("name,artists\nJazz,Queen\n"), DefaultCSVColumns, false)
+		assert.ErrorContains(t, err, `missing "year" column for year`)
+
+		_, err = ImportCSV(db, strings.NewReader("Album,Released\nJazz,1978\n"), spreadsheetColumns, false)
+		assert.ErrorContains(t, err, `missing "Performers" column for artists`)
+
+		_, err = ImportCSV(db, strings.NewReader(""), DefaultCSVColumns, false)
+		assert.ErrorContains(t, err, "empty CSV file")
+	})
+
+	t.Run("Malformed CSV Writes Nothing", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+
+		_, err := ImportCSV(db, strings.NewReader("name,year\nJazz,1978\n\"Unclosed,1979\n"), DefaultCSVColumns, false)
+		assert.Error(t, err)
+		assert.Equal(t, 0, countRows(t, db, "releases"))
+	})
+
+	t.Run("Round Trip", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		if err := SeedDB(db); err != nil {
+			t.Fatalf("Failed to seed database: %v", err)
+		}
+
+		var out bytes.Buffer
+		_, err := ExportCSV(db, &out, SearchQuery{}, SortYear)
+		assert.NoError(t, err)
+
+		report, err := ImportCSV(db, &out, DefaultCSVColumns, false)
+		assert.NoError(t, err)
+		assert.Equal(t, CSVImportReport{Updated: 30}, report)
+		assert.Equal(t, 30, countRows(t, db, "releases"))
+	})
+}
@@ -6,15 +6,17 @@ import (
 	"fmt"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	_ "github.com/mattn/go-sqlite3"
 	"log"
 	"os"
+	"path/filepath"
 )
 
-func InitDB() (*sql.DB, error) {
+func InitDB(dbPath string) (*sql.DB, error) {
 	// Open SQLite database
-	db, err := sql.Open("sqlite3", "./data.db")
+	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -22,41 +24,41 @@ func InitDB() (*sql.DB, error) {
 	return db, nil
 }
 
-func ResetDb() {
-	// Define the file name
-	const fileName = "data.db"
-
+func ResetDb(fileName string) error {
 	// Check if the file exists
 	if _, err := os.Stat(fileName); err == nil {
 		// If it exists, delete it
-		log.Println("Deleting existing data.db...")
+		log.Printf("Deleting existing %s...", fileName)
 		if err := os.Remove(fileName); err != nil {
-			log.Fatalf("Error deleting file: %v", err)
-			return
+			return fmt.Errorf("failed to delete %s: %w", fileName, err)
 		}
 		log.Println("File deleted successfully.")
 	} else if !os.IsNotExist(err) {
 		// Handle other errors from os.Stat
-		log.Fatalf("Error checking file: %v", err)
-		return
+		return fmt.Errorf("failed to check %s: %w", fileName, err)
 	}
 
 	// Recreate the file
-	log.Println("Recreating data.db...")
+	log.Printf("Recreating %s...", fileName)
 	file, err := os.Create(fileName)
 	if err != nil {
-		log.Fatalf("Error creating file: %v", err)
-		return
+		return fmt.Errorf("failed to create %s: %w", fileName, err)
 	}
 	defer file.Close()
 
 	log.Println("File created successfully.")
+	return nil
 }
 
-func RunMigrations(db *sql.DB, migrationsDir string) {
+func newMigrate(db *sql.DB, migrationsDir string) (*migrate.Migrate, error) {
+	migrationsDir, err := filepath.Abs(migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve migrations directory: %w", err)
+	}
+
 	driver, err := sqlite3.WithInstance(db, &sqlite3.Config{})
 	if err != nil {
-		log.Fatalf("Could not create SQLite driver: %v", err)
+		return nil, fmt.Errorf("could not create SQLite driver: %w", err)
 	}
 
 	m, err := migrate.NewWithDatabaseInstance(
@@ -65,17 +67,100 @@ func RunMigrations(db *sql.DB, migrationsDir string) {
 		driver,
 	)
 	if err != nil {
-		log.Fatalf("Could not initialize migrations: %v", err)
+		return nil, fmt.Errorf("could not initialize migrations: %w", err)
+	}
+
+	return m, nil
+}
+
+func RunMigrations(db *sql.DB, migrationsDir string) error {
+	m, err := newMigrate(db, migrationsDir)
+	if err != nil {
+		return err
 	}
 
 	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
-		log.Fatalf("Could not run migrations: %v", err)
+		return fmt.Errorf("could not run migrations: %w", err)
 	}
 
 	log.Println("Migrations applied successfully!")
+	return nil
+}
+
+// MigrateDown rolls back the given number of applied migrations
+func MigrateDown(db *sql.DB, migrationsDir string, steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("invalid number of steps: %d", steps)
+	}
+
+	m, err := newMigrate(db, migrationsDir)
+	if err != nil {
+		return err
+	}
+
+	if err := m.Steps(-steps); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("could not roll back migrations: %w", err)
+	}
+
+	return nil
+}
+
+// MigrateTo migrates up or down until version is the current migration
+func MigrateTo(db *sql.DB, migrationsDir string, version uint) error {
+	m, err := newMigrate(db, migrationsDir)
+	if err != nil {
+		return err
+	}
+
+	if err := m.Migrate(version); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("could not migrate to version %d: %w", version, err)
+	}
+
+	return nil
+}
+
+type MigrationStatus struct {
+	Version uint // Current migration, 0 when none have been applied
+	Dirty   bool // Whether the current migration failed part way through
+	Latest  uint // Newest migration in the migrations directory
 }
 
-func seedReleases(db *sql.DB) {
+func GetMigrationStatus(db *sql.DB, migrationsDir string) (MigrationStatus, error) {
+	m, err := newMigrate(db, migrationsDir)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	var status MigrationStatus
+	status.Version, status.Dirty, err = m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return MigrationStatus{}, fmt.Errorf("could not read migration version: %w", err)
+	}
+
+	absMigrationsDir, err := filepath.Abs(migrationsDir)
+	if err != nil {
+		return MigrationStatus{}, fmt.Errorf("could not resolve migrations directory: %w", err)
+	}
+
+	src, err := source.Open("file://" + absMigrationsDir)
+	if err != nil {
+		return MigrationStatus{}, fmt.Errorf("could not read migrations: %w", err)
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	for err == nil {
+		status.Latest = version
+		version, err = src.Next(version)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return MigrationStatus{}, fmt.Errorf("could not read migrations: %w", err)
+	}
+
+	return status, nil
+}
+
+func seedReleases(db *sql.DB) ([]int64, error) {
 	// Seed data for the 'releases' table
 	var releases []struct {
 		Name string
@@ -96,24 +181,31 @@ func seedReleases(db *sql.DB) {
 	// Prepare the INSERT statement
 	stmt, err := db.Prepare("INSERT INTO releases (name, year) VALUES (?, ?)")
 	if err != nil {
-		log.Fatalf("Failed to prepare statement: %v", err)
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
 	// Insert data into the table
+	var ids []int64
 	for _, release := range releases {
-		_, err := stmt.Exec(release.Name, release.Year)
+		result, err := stmt.Exec(release.Name, release.Year)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert release '%s': %w", release.Name, err)
+		}
+
+		id, err := result.LastInsertId()
 		if err != nil {
-			log.Printf("Failed to insert release '%s': %v", release.Name, err)
-		} else {
-			log.Printf("Successfully inserted release: '%s'", release.Name)
+			return nil, fmt.Errorf("failed to get id of release '%s': %w", release.Name, err)
 		}
+		ids = append(ids, id)
+		log.Printf("Successfully inserted release: '%s'", release.Name)
 	}
 
 	log.Println("Seeded releases")
+	return ids, nil
 }
 
-func seedArtists(db *sql.DB) {
+func seedArtists(db *sql.DB) ([]int64, error) {
 	// Seed data for the 'artists' table
 	type Artist struct {
 		Name string
@@ -155,102 +247,198 @@ func seedArtists(db *sql.DB) {
 	// Prepare the INSERT statement
 	stmt, err := db.Prepare("INSERT INTO artists (name) VALUES (?)")
 	if err != nil {
-		log.Fatalf("Failed to prepare statement: %v", err)
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
 	// Insert data into the table
+	var ids []int64
 	for _, artist := range artists {
-		_, err := stmt.Exec(artist.Name)
+		result, err := stmt.Exec(artist.Name)
 		if err != nil {
-			log.Printf("Failed to insert artist '%s': %v", artist.Name, err)
-		} else {
-			log.Printf("Successfully inserted artist: '%s'", artist.Name)
+			return nil, fmt.Errorf("failed to insert artist '%s': %w", artist.Name, err)
 		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get id of artist '%s': %w", artist.Name, err)
+		}
+		ids = append(ids, id)
+		log.Printf("Successfully inserted artist: '%s'", artist.Name)
 	}
 
-	log.Println("Seeded Releases")
+	log.Println("Seeded artists")
+	return ids, nil
 }
 
-func seedReleaseArtists(db *sql.DB) {
-	// Seed data for the 'release_artists' table
-	type Artist struct {
-		ReleaseId int
-		ArtistId  int
-	}
-
-	// Seed the release_artists table
+func seedReleaseArtists(db *sql.DB, releaseIds []int64, artistIds []int64) error {
+	// Seed the release_artists table, pairing each seeded release with the
+	// seeded artist at the same position
 	tx, err := db.Begin() // Use a transaction for better performance
 	if err != nil {
-		log.Fatalf("Failed to begin transaction: %v", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	stmt, err := tx.Prepare("INSERT INTO release_artists (id, release_id, artist_id) VALUES (?, ?, ?)")
+	stmt, err := tx.Prepare("INSERT INTO release_artists (release_id, artist_id) VALUES (?, ?)")
 	if err != nil {
-		log.Fatalf("Failed to prepare statement: %v", err)
+		return fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
-	for i := 1; i <= 30; i++ {
-		_, err := stmt.Exec(i, i, i)
+	for i := 0; i < len(releaseIds) && i < len(artistIds); i++ {
+		_, err := stmt.Exec(releaseIds[i], artistIds[i])
 		if err != nil {
-			log.Printf("Failed to insert row %d: %v", i, err)
-		} else {
-			fmt.Printf("Inserted row: id=%d, release_id=%d, artist_id=%d\n", i, i, i)
+			return fmt.Errorf("failed to insert row %d: %w", i+1, err)
 		}
+		log.Printf("Inserted row: release_id=%d, artist_id=%d", releaseIds[i], artistIds[i])
 	}
 
 	// Commit the transaction
 	if err := tx.Commit(); err != nil {
-		log.Fatalf("Failed to commit transaction: %v", err)
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	log.Println("Seeded release_artists")
+	return nil
 }
 
-func populateReleaseFts(db *sql.DB) {
+// ReindexFts rebuilds releases_fts and artists_fts from the base tables and
+// resumes the sync triggers if they were paused
+func ReindexFts(db *sql.DB) error {
 
 	// Populate 'release_fts' virtual table
 	tx, err := db.Begin() // Use a transaction for better performance
 	if err != nil {
-		log.Fatalf("Failed to begin transaction: %v", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Clear rows already written by the sync triggers so each release is only
+	// indexed once
+	if _, err := tx.Exec("DELETE FROM releases_fts"); err != nil {
+		return fmt.Errorf("failed to clear releases_fts: %w", err)
 	}
 
 	stmt, err := tx.Prepare(`
-		INSERT INTO releases_fts (release_id, artist_name, release_name, release_year)
-		SELECT
-			releases.id AS release_id,
-			artists.name AS artist_name,
-			releases.name AS release_name,
-			releases.year AS release_year
-		FROM
-			release_artists
-				JOIN
-			artists ON release_artists.artist_id = artists.id
-				JOIN
-			releases ON release_artists.release_id = releases.id;
+		INSERT INTO releases_fts (rowid, release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+		SELECT release_id, * FROM releases_fts_rows;
 	`)
 	if err != nil {
-		log.Fatalf("Failed to prepare statement: %v", err)
+		return fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
 	_, err = stmt.Exec()
 	if err != nil {
-		log.Printf("Failed to execute releases_fts query %v", err)
+		return fmt.Errorf("failed to execute releases_fts query: %w", err)
+	}
+
+	// Rebuild 'artists_fts' the same way
+	if _, err := tx.Exec("DELETE FROM artists_fts"); err != nil {
+		return fmt.Errorf("failed to clear artists_fts: %w", err)
+	}
+	if _, err := tx.Exec("INSERT INTO artists_fts (rowid, name) SELECT id, name FROM artists"); err != nil {
+		return fmt.Errorf("failed to execute artists_fts query: %w", err)
+	}
+
+	// Resume the sync triggers now the indexes are up to date
+	if _, err := tx.Exec("UPDATE fts_sync SET paused = 0"); err != nil {
+		return fmt.Errorf("failed to resume fts sync: %w", err)
 	}
 
 	// Commit the transaction
 	if err := tx.Commit(); err != nil {
-		log.Fatalf("Failed to commit transaction: %v", err)
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	log.Println("Populated Release FTS")
+	return nil
+}
+
+// PauseFtsSync stops the triggers that keep releases_fts in sync with the base
+// tables until the next ReindexFts. Bulk imports pause them because each
+// trigger scans the whole index.
+func PauseFtsSync(db *sql.DB) error {
+	if _, err := db.Exec("UPDATE fts_sync SET paused = 1"); err != nil {
+		return fmt.Errorf("failed to pause fts sync: %w", err)
+	}
+	return nil
 }
 
-func SeedDB(db *sql.DB) {
-	seedReleases(db)
-	seedArtists(db)
-	seedReleaseArtists(db)
-	populateReleaseFts(db)
+// RecoverFtsSync rebuilds the search indexes when the sync triggers are still
+// paused, which outside a running import or scan means one was interrupted
+// before it could reindex. It reports whether it rebuilt them.
+func RecoverFtsSync(db *sql.DB) (bool, error) {
+	var paused bool
+	if err := db.QueryRow("SELECT paused FROM fts_sync").Scan(&paused); err != nil {
+		return false, fmt.Errorf("failed to check fts sync: %w", err)
+	}
+	if !paused {
+		return false, nil
+	}
+	return true, ReindexFts(db)
+}
+
+func SeedDB(db *sql.DB) error {
+	releaseIds, err := seedReleases(db)
+	if err != nil {
+		return err
+	}
+
+	artistIds, err := seedArtists(db)
+	if err != nil {
+		return err
+	}
+
+	if err := seedReleaseArtists(db, releaseIds, artistIds); err != nil {
+		return err
+	}
+
+	return ReindexFts(db)
+}
+
+// Seed modes control when SeedIfNeeded loads the sample data
+const (
+	SeedEmpty  = "empty"  // Seed only when the database has no releases or artists
+	SeedAlways = "always" // Seed on every start, even if data already exists
+	SeedNever  = "never"  // Never seed
+)
+
+func IsValidSeedMode(mode string) bool {
+	return mode == SeedEmpty || mode == SeedAlways || mode == SeedNever
+}
+
+// IsEmptyDB reports whether the database has no releases and no artists
+func IsEmptyDB(db *sql.DB) (bool, error) {
+	var hasData bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM releases) OR EXISTS (SELECT 1 FROM artists)").Scan(&hasData)
+	if err != nil {
+		return false, err
+	}
+	return !hasData, nil
+}
+
+// SeedIfNeeded seeds the database according to mode and reports whether it did
+func SeedIfNeeded(db *sql.DB, mode string) (bool, error) {
+	switch mode {
+	case SeedNever:
+		return false, nil
+	case SeedEmpty:
+		empty, err := IsEmptyDB(db)
+		if err != nil {
+			return false, fmt.Errorf("failed to check for existing data: %w", err)
+		}
+		if !empty {
+			return false, nil
+		}
+	case SeedAlways:
+	default:
+		return false, fmt.Errorf("invalid seed mode: %q", mode)
+	}
+
+	if err := SeedDB(db); err != nil {
+		return false, err
+	}
+	return true, nil
 }
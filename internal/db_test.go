@@ -48,7 +48,9 @@ func TestResetDb(t *testing.T) {
 
 	// Step 2: Call resetDb
 	t.Log("Calling resetDb to delete and recreate the file")
-	ResetDb()
+	if err := ResetDb(fileName); err != nil {
+		t.Fatalf("Failed to reset database: %v", err)
+	}
 
 	// Verify the file is recreated
 	if _, err := os.Stat(fileName); os.IsNotExist(err) {
@@ -90,7 +92,9 @@ func TestRunMigrations(t *testing.T) {
 	}
 	migrationsDir := filepath.Join(filepath.Dir(testFilePath), "../migrations")
 
-	RunMigrations(db, migrationsDir)
+	if err := RunMigrations(db, migrationsDir); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
 
 	// Verify migrations
 	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type='table'")
@@ -129,7 +133,9 @@ func TestSeedDB(t *testing.T) {
 	defer db.Close()
 
 	createTestTables(db)
-	SeedDB(db)
+	if err := SeedDB(db); err != nil {
+		t.Fatalf("Failed to seed database: %v", err)
+	}
 
 	// Verify data in `releases` table
 	rows, err := db.Query("SELECT COUNT(*) FROM releases")
@@ -151,3 +157,168 @@ func TestSeedDB(t *testing.T) {
 
 	// Add similar assertions for `artists` and `release_artists` if necessary
 }
+
+func TestRecoverFtsSync(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen')")
+
+	recovered, err := RecoverFtsSync(db)
+	if err != nil {
+		t.Fatalf("Failed to recover fts sync: %v", err)
+	}
+	if recovered {
+		t.Errorf("Expected nothing to recover while the triggers are running")
+	}
+
+	// An import killed after pausing the triggers never reindexes
+	if err := PauseFtsSync(db); err != nil {
+		t.Fatalf("Failed to pause fts sync: %v", err)
+	}
+	mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Innuendo', 1991)")
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1)")
+	if credits := searchCredits(t, db, "Innuendo"); len(credits) != 0 {
+		t.Fatalf("Expected the paused index to miss the import, but got %v", credits)
+	}
+
+	recovered, err = RecoverFtsSync(db)
+	if err != nil {
+		t.Fatalf("Failed to recover fts sync: %v", err)
+	}
+	if !recovered {
+		t.Errorf("Expected the paused triggers to be recovered")
+	}
+	if credits := searchCredits(t, db, "Innuendo"); len(credits) != 1 {
+		t.Errorf("Expected the rebuilt index to find the import, but got %v", credits)
+	}
+
+	// Later edits are synced again
+	mustExec(t, db, "UPDATE releases SET name = 'Made in Heaven' WHERE id = 1")
+	if credits := searchCredits(t, db, "Heaven"); len(credits) != 1 {
+		t.Errorf("Expected the resumed triggers to index the edit, but got %v", credits)
+	}
+}
+
+func TestSeedIfNeeded(t *testing.T) {
+	countReleases := func(t *testing.T, db *sql.DB) int {
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM releases").Scan(&count); err != nil {
+			t.Fatalf("Failed to count releases: %v", err)
+		}
+		return count
+	}
+
+	t.Run("Empty Seeds Only An Empty Database", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+
+		seeded, err := SeedIfNeeded(db, SeedEmpty)
+		if err != nil {
+			t.Fatalf("Failed to seed: %v", err)
+		}
+		if !seeded || countReleases(t, db) != 30 {
+			t.Errorf("Expected an empty database to be seeded")
+		}
+
+		seeded, err = SeedIfNeeded(db, SeedEmpty)
+		if err != nil {
+			t.Fatalf("Failed to seed: %v", err)
+		}
+		if seeded || countReleases(t, db) != 30 {
+			t.Errorf("Expected existing data to be kept as is")
+		}
+	})
+
+	t.Run("Empty Keeps Artists Only Database", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		mustExec(t, db, "INSERT INTO artists (name) VALUES ('Queen')")
+
+		seeded, err := SeedIfNeeded(db, SeedEmpty)
+		if err != nil {
+			t.Fatalf("Failed to seed: %v", err)
+		}
+		if seeded {
+			t.Errorf("Expected a database with artists not to be seeded")
+		}
+	})
+
+	t.Run("Always", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		mustExec(t, db, "INSERT INTO releases (name, year) VALUES ('Hot Space', 1982)")
+
+		seeded, err := SeedIfNeeded(db, SeedAlways)
+		if err != nil {
+			t.Fatalf("Failed to seed: %v", err)
+		}
+		if !seeded || countReleases(t, db) != 31 {
+			t.Errorf("Expected sample data to be added to existing data")
+		}
+	})
+
+	t.Run("Never", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+
+		seeded, err := SeedIfNeeded(db, SeedNever)
+		if err != nil {
+			t.Fatalf("Failed to seed: %v", err)
+		}
+		if seeded || countReleases(t, db) != 0 {
+			t.Errorf("Expected database not to be seeded")
+		}
+	})
+
+	t.Run("Invalid Mode", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+
+		if _, err := SeedIfNeeded(db, "sometimes"); err == nil {
+			t.Errorf("Expected an error for an invalid seed mode")
+		}
+	})
+}
+
+func TestMigrationCommands(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, testFilePath, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatalf("Failed to get test file path")
+	}
+	migrationsDir := filepath.Join(filepath.Dir(testFilePath), "../migrations")
+
+	status, err := GetMigrationStatus(db, migrationsDir)
+	if err != nil {
+		t.Fatalf("Failed to get migration status: %v", err)
+	}
+	if status.Version != 0 || status.Latest < 4 {
+		t.Errorf("Expected no applied migrations and at least 4 available, got %+v", status)
+	}
+	latest := status.Latest
+
+	if err := RunMigrations(db, migrationsDir); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+	if status, _ := GetMigrationStatus(db, migrationsDir); status.Version != latest || status.Dirty {
+		t.Errorf("Expected version %d after migrating up, got %+v", latest, status)
+	}
+
+	if err := MigrateDown(db, migrationsDir, 1); err != nil {
+		t.Fatalf("Failed to roll back migration: %v", err)
+	}
+	if status, _ := GetMigrationStatus(db, migrationsDir); status.Version != latest-1 {
+		t.Errorf("Expected version %d after rolling back, got %+v", latest-1, status)
+	}
+
+	if err := MigrateTo(db, migrationsDir, 2); err != nil {
+		t.Fatalf("Failed to migrate to version 2: %v", err)
+	}
+	if status, _ := GetMigrationStatus(db, migrationsDir); status.Version != 2 {
+		t.Errorf("Expected version 2, got %+v", status)
+	}
+
+	if err := MigrateDown(db, migrationsDir, 0); err == nil {
+		t.Errorf("Expected an error when rolling back 0 steps")
+	}
+}
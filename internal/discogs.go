@@ -0,0 +1,232 @@
+package internal
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// discogsSource is the source of external IDs imported from Discogs
+const discogsSource = "discogs"
+
+// discogsArtist is an <artist> in the artists dump, or in the <artists> or
+// <extraartists> of a release. Join is the text before the next artist of a
+// release, and Role what an extra artist did, like "Producer".
+type discogsArtist struct {
+	Id   int    `xml:"id"`
+	Name string `xml:"name"`
+	Join string `xml:"join"`
+	Role string `xml:"role"`
+}
+
+// discogsRelease is a <release> in the releases dump
+type discogsRelease struct {
+	Id           int             `xml:"id,attr"`
+	Title        string          `xml:"title"`
+	Released     string          `xml:"released"`
+	Artists      []discogsArtist `xml:"artists>artist"`
+	ExtraArtists []discogsArtist `xml:"extraartists>artist"`
+}
+
+// discogsNameNumber matches the number Discogs adds to tell apart artists with the same name
+var discogsNameNumber = regexp.MustCompile(`\s+\(\d+\)$`)
+
+// ImportDiscogs streams a Discogs artists or releases XML dump, gzipped or
+// plain, from r and upserts its records in transactions of batchSize. Only
+// one record is decoded at a time, so dumps of any size use constant memory.
+// Releases are credited to their artists, with the join phrases between
+// them, and to the extra artists who produced, remixed or feature on them.
+// Artists are created when they haven't been imported yet. Releases without
+// a release year are skipped. Progress is reported to progress, which may be
+// nil.
+//
+// The releases_fts sync triggers stay active unless paused with
+// PauseFtsSync, in which case call ReindexFts once everything is imported.
+func ImportDiscogs(db *sql.DB, r io.Reader, batchSize int, progress io.Writer) (ImportStats, error) {
+	r, err := gunzipIfCompressed(r)
+	if err != nil {
+		return ImportStats{}, fmt.Errorf("failed to read gzip header: %w", err)
+	}
+
+	decoder := xml.NewDecoder(r)
+	root, err := discogsRoot(decoder)
+	if err != nil {
+		return ImportStats{}, err
+	}
+
+	var record string
+	switch root {
+	case "artists":
+		record = "artist"
+	case "releases":
+		record = "release"
+	default:
+		return ImportStats{}, fmt.Errorf("unsupported Discogs dump <%s>, expected <artists> or <releases>", root)
+	}
+
+	var stats ImportStats
+	reporter := importProgress{w: progress}
+	err = importBatches(db, discogsSource, batchSize, func(tx importTx) error {
+		start, err := nextDiscogsRecord(decoder, record)
+		if err != nil {
+			return err
+		}
+
+		if record == "artist" {
+			var artist discogsArtist
+			if err := decoder.DecodeElement(&artist, &start); err != nil {
+				return fmt.Errorf("failed to decode artist: %w", err)
+			}
+			return importDiscogsArtist(tx, artist, &stats)
+		}
+
+		var release discogsRelease
+		if err := decoder.DecodeElement(&release, &start); err != nil {
+			return fmt.Errorf("failed to decode release: %w", err)
+		}
+		return importDiscogsRelease(tx, release, &stats)
+	}, nil, func() {
+		reporter.report(stats)
+	})
+	return stats, err
+}
+
+// discogsRoot reads up to the root element of a dump and returns its name
+func discogsRoot(decoder *xml.Decoder) (string, error) {
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return "", errors.New("empty Discogs dump")
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read Discogs dump: %w", err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+// nextDiscogsRecord reads up to the next record element under the root,
+// skipping any other elements, and returns io.EOF at the end of the root
+func nextDiscogsRecord(decoder *xml.Decoder, record string) (xml.StartElement, error) {
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return xml.StartElement{}, fmt.Errorf("failed to read Discogs dump: %w", io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return xml.StartElement{}, fmt.Errorf("failed to read Discogs dump: %w", err)
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			if token.Name.Local == record {
+				return token, nil
+			}
+			if err := decoder.Skip(); err != nil {
+				return xml.StartElement{}, fmt.Errorf("failed to read Discogs dump: %w", err)
+			}
+		case xml.EndElement:
+			return xml.StartElement{}, io.EOF
+		}
+	}
+}
+
+func importDiscogsArtist(tx importTx, artist discogsArtist, stats *ImportStats) error {
+	name := discogsArtistName(artist.Name)
+	if artist.Id <= 0 || name == "" {
+		stats.Skipped++
+		return nil
+	}
+
+	if _, err := tx.upsertArtist(strconv.Itoa(artist.Id), name); err != nil {
+		return err
+	}
+	stats.Artists++
+	return nil
+}
+
+func importDiscogsRelease(tx importTx, release discogsRelease, stats *ImportStats) error {
+	name := strings.TrimSpace(release.Title)
+	releaseDate, year, ok := importedReleaseDate(release.Released)
+	if release.Id <= 0 || name == "" || !ok {
+		stats.Skipped++
+		return nil
+	}
+
+	releaseId, err := tx.upsertDatedRelease(strconv.Itoa(release.Id), name, year, releaseDate)
+	if err != nil {
+		return err
+	}
+
+	// Extra artists are credited after the main artists when their role is
+	// one of ours, the rest like "Mastered By" are left out
+	var credits []CreditInput
+	for _, artist := range release.Artists {
+		credits, err = tx.appendDiscogsCredit(credits, artist, RoleMain)
+		if err != nil {
+			return err
+		}
+	}
+	for _, artist := range release.ExtraArtists {
+		role, ok := discogsCreditRole(artist.Role)
+		if !ok {
+			continue
+		}
+		credits, err = tx.appendDiscogsCredit(credits, artist, role)
+		if err != nil {
+			return err
+		}
+	}
+	if err := setReleaseCredits(tx.tx, releaseId, credits); err != nil {
+		return fmt.Errorf("failed to set artists of release %d: %w", release.Id, err)
+	}
+
+	stats.Releases++
+	return nil
+}
+
+// appendDiscogsCredit appends the credit of a release artist to credits,
+// creating the artist when it hasn't been imported yet. Artists without an
+// id or name are left out.
+func (t importTx) appendDiscogsCredit(credits []CreditInput, artist discogsArtist, role string) ([]CreditInput, error) {
+	name := discogsArtistName(artist.Name)
+	if artist.Id <= 0 || name == "" {
+		return credits, nil
+	}
+	artistId, err := t.ensureArtist(strconv.Itoa(artist.Id), name)
+	if err != nil {
+		return nil, err
+	}
+	credit := CreditInput{ArtistId: artistId, Role: role}
+	if role == RoleMain {
+		credit.JoinPhrase = strings.TrimSpace(artist.Join)
+	}
+	return append(credits, credit), nil
+}
+
+// discogsCreditRole maps the role of a Discogs extra artist, like
+// "Co-producer" or "Remix", to the credit role it counts as
+func discogsCreditRole(role string) (string, bool) {
+	role = strings.ToLower(role)
+	switch {
+	case strings.Contains(role, "featuring"):
+		return RoleFeaturing, true
+	case strings.Contains(role, "producer"):
+		return RoleProducer, true
+	case strings.Contains(role, "remix"):
+		return RoleRemixer, true
+	}
+	return "", false
+}
+
+// discogsArtistName drops the number Discogs adds to artist names like "Nirvana (2)"
+func discogsArtistName(name string) string {
+	return discogsNameNumber.ReplaceAllString(strings.TrimSpace(name), "")
+}
@@ -0,0 +1,198 @@
+package internal
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustImportDiscogs(t *testing.T, db *sql.DB, path string, batchSize int) ImportStats {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	stats, err := ImportDiscogs(db, file, batchSize, nil)
+	if err != nil {
+		t.Fatalf("Failed to import %s: %v", path, err)
+	}
+	return stats
+}
+
+// importedRelease returns the release imported from source with externalId
+func importedRelease(t *testing.T, db *sql.DB, source string, externalId string) Release {
+	t.Helper()
+	var releaseId int
+	err := db.QueryRow(
+		"SELECT local_id FROM external_ids WHERE source = ? AND entity = 'release' AND external_id = ?",
+		source, externalId,
+	).Scan(&releaseId)
+	if err != nil {
+		t.Fatalf("Failed to find release %s from %s: %v", externalId, source, err)
+	}
+
+	release, err := getRelease(db, releaseId)
+	if err != nil {
+		t.Fatalf("Failed to get release %d: %v", releaseId, err)
+	}
+	return release
+}
+
+func artistNames(artists []Artist) []string {
+	names := []string{}
+	for _, artist := range artists {
+		names = append(names, artist.Name)
+	}
+	return names
+}
+
+func countRows(t *testing.T, db *sql.DB, table string) int {
+	t.Helper()
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count); err != nil {
+		t.Fatalf("Failed to count %s: %v", table, err)
+	}
+	return count
+}
+
+func TestImportDiscogs(t *testing.T) {
+	t.Run("Artists And Releases", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+
+		stats := mustImportDiscogs(t, db, "testdata/discogs/artists.xml", 2)
+		assert.Equal(t, ImportStats{Artists: 4, Skipped: 1}, stats)
+
+		// The releases dump is gzipped
+		stats = mustImportDiscogs(t, db, "testdata/discogs/releases.xml.gz", 2)
+		assert.Equal(t, ImportStats{Releases: 4, Skipped: 1}, stats)
+
+		assert.Equal(t, 5, countRows(t, db, "artists"))
+		assert.Equal(t, 4, countRows(t, db, "releases"))
+
+		release := importedRelease(t, db, discogsSource, "249504")
+		assert.Equal(t, "Under Pressure", release.Name)
+		assert.Equal(t, 1981, release.Year)
+		assert.Equal(t, []string{"Queen", "David Bowie"}, artistNames(release.Artists))
+		assert.Equal(t, "Queen & David Bowie", release.Credit)
+		assert.Equal(t, "1981-10-26", release.ReleaseDate)
+
+		// Unknown months and days are left out of the release date
+		assert.Equal(t, "1999-03", importedRelease(t, db, discogsSource, "1").ReleaseDate)
+
+		release = importedRelease(t, db, discogsSource, "3002")
+		assert.Equal(t, 1968, release.Year)
+		assert.Equal(t, "1968", release.ReleaseDate)
+		assert.Equal(t, []string{"Nirvana"}, artistNames(release.Artists))
+
+		// Artists missing from the artists dump are created from the credits
+		release = importedRelease(t, db, discogsSource, "4711")
+		assert.Equal(t, []string{"Various"}, artistNames(release.Artists))
+
+		// The sync triggers kept the search index up to date
+		releases, err := getReleases(db, 10, 0, mustParseSearchQuery(t, "stockholm"), SortYear, nil)
+		assert.NoError(t, err)
+		assert.Len(t, releases, 1)
+		assert.Equal(t, "The Persuader", releases[0]["artist_credit"])
+	})
+
+	t.Run("Reimport Updates Rows", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		mustImportDiscogs(t, db, "testdata/discogs/artists.xml", DefaultImportBatchSize)
+		mustImportDiscogs(t, db, "testdata/discogs/releases.xml.gz", DefaultImportBatchSize)
+		before := importedRelease(t, db, discogsSource, "249504")
+
+		mustImportDiscogs(t, db, "testdata/discogs/artists.xml", DefaultImportBatchSize)
+		mustImportDiscogs(t, db, "testdata/discogs/releases.xml.gz", DefaultImportBatchSize)
+		assert.Equal(t, 5, countRows(t, db, "artists"))
+		assert.Equal(t, 4, countRows(t, db, "releases"))
+		assert.Equal(t, 5, countRows(t, db, "release_artists"))
+
+		stats := mustImportDiscogs(t, db, "testdata/discogs/releases_update.xml", DefaultImportBatchSize)
+		assert.Equal(t, ImportStats{Releases: 1}, stats)
+
+		after := importedRelease(t, db, discogsSource, "249504")
+		assert.Equal(t, before.Id, after.Id)
+		assert.Equal(t, "Under Pressure (Rah Mix)", after.Name)
+		assert.Equal(t, 1999, after.Year)
+		assert.Equal(t, "1999-12-06", after.ReleaseDate)
+		assert.Equal(t, "Queen", after.Credit)
+
+		// Extra artists are credited when their role is one of ours
+		assert.Equal(t, []string{"Queen", "Brian May", "Mark Wallis"}, artistNames(after.Artists))
+		assert.Len(t, after.CreditsWithRole(RoleProducer), 1)
+		assert.Equal(t, "Mark Wallis", after.CreditsWithRole(RoleRemixer)[0].Name)
+
+		releases, err := getReleases(db, 10, 0, mustParseSearchQuery(t, "pressure"), SortYear, nil)
+		assert.NoError(t, err)
+		assert.Len(t, releases, 1)
+	})
+
+	t.Run("Deleted Rows Are Imported Again", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		mustImportDiscogs(t, db, "testdata/discogs/releases.xml.gz", DefaultImportBatchSize)
+		release := importedRelease(t, db, discogsSource, "1")
+		assert.NoError(t, deleteRelease(db, release.Id))
+
+		mustImportDiscogs(t, db, "testdata/discogs/releases.xml.gz", DefaultImportBatchSize)
+		assert.Equal(t, "Stockholm", importedRelease(t, db, discogsSource, "1").Name)
+		assert.Equal(t, 4, countRows(t, db, "releases"))
+	})
+
+	t.Run("Paused Sync", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		assert.NoError(t, PauseFtsSync(db))
+		mustImportDiscogs(t, db, "testdata/discogs/releases.xml.gz", DefaultImportBatchSize)
+		assert.Equal(t, 0, countRows(t, db, "releases_fts"))
+
+		assert.NoError(t, ReindexFts(db))
+		assert.Equal(t, 4, countRows(t, db, "releases_fts"))
+
+		// Reindexing resumes the triggers
+		mustImportDiscogs(t, db, "testdata/discogs/releases_update.xml", DefaultImportBatchSize)
+		releases, err := getReleases(db, 10, 0, mustParseSearchQuery(t, `"rah mix"`), SortYear, nil)
+		assert.NoError(t, err)
+		assert.Len(t, releases, 1)
+	})
+
+	t.Run("Progress", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		var dump strings.Builder
+		dump.WriteString("<artists>")
+		for i := 1; i <= importProgressInterval; i++ {
+			dump.WriteString("<artist><id>" + strconv.Itoa(i) + "</id><name>Artist</name></artist>")
+		}
+		dump.WriteString("</artists>")
+
+		var progress bytes.Buffer
+		stats, err := ImportDiscogs(db, strings.NewReader(dump.String()), DefaultImportBatchSize, &progress)
+		assert.NoError(t, err)
+		assert.Equal(t, importProgressInterval, stats.Artists)
+		assert.Equal(t, "Progress: imported 10000 artists and 0 releases, skipped 0 records\n", progress.String())
+	})
+
+	t.Run("Invalid Dumps", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		for dump, message := range map[string]string{
+			"":                                       "empty Discogs dump",
+			"<labels><label/></labels>":              "unsupported Discogs dump <labels>",
+			"<artists><artist><id>1</id>":            "failed to decode artist",
+			"<artists><artist><id>x</id></artist>":   "failed to decode artist",
+			"<releases><release id=\"1\"></release>": "unexpected EOF",
+		} {
+			_, err := ImportDiscogs(db, strings.NewReader(dump), DefaultImportBatchSize, nil)
+			if assert.Error(t, err, dump) {
+				assert.Contains(t, err.Error(), message)
+			}
+		}
+
+		_, err := ImportDiscogs(db, strings.NewReader("<artists></artists>"), 0, nil)
+		assert.EqualError(t, err, "invalid batch size: 0")
+	})
+}
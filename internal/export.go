@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const exportBatchSize = 500
+
+// ExportJSON writes every release with its artists to w as a JSON array. Releases
+// are loaded in batches so large catalogs are never held in memory at once.
+func ExportJSON(db *sql.DB, w io.Writer) (int, error) {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return 0, err
+	}
+
+	exported := 0
+	lastId := 0
+	for {
+		releaseIds, err := getReleaseIdsAfter(db, lastId, exportBatchSize)
+		if err != nil {
+			return exported, fmt.Errorf("failed to get release ids: %w", err)
+		}
+		if len(releaseIds) == 0 {
+			break
+		}
+
+		releases, err := getReleasesByIds(db, releaseIds)
+		if err != nil {
+			return exported, fmt.Errorf("failed to get releases: %w", err)
+		}
+
+		for _, release := range releases {
+			if exported > 0 {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return exported, err
+				}
+			}
+
+			encoded, err := json.Marshal(release)
+			if err != nil {
+				return exported, err
+			}
+			if _, err := w.Write(append([]byte("\n  "), encoded...)); err != nil {
+				return exported, err
+			}
+			exported++
+		}
+
+		lastId = releaseIds[len(releaseIds)-1]
+	}
+
+	if exported > 0 {
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return exported, err
+		}
+	}
+	_, err := io.WriteString(w, "]\n")
+	return exported, err
+}
+
+// getReleaseIdsAfter returns up to limit release ids greater than afterId, in order
+func getReleaseIdsAfter(db *sql.DB, afterId int, limit int) ([]int, error) {
+	rows, err := db.Query("SELECT id FROM releases WHERE id > ? ORDER BY id LIMIT ?", afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ExportCSV writes the releases matching query to w as CSV in sort order, in
+// the format ImportCSV reads. Each release is one row with its artists
+// separated by semicolons. Releases are read in batches with cursors so large catalogs are streamed.
+func ExportCSV(db *sql.DB, w io.Writer, query SearchQuery, sort string) (int, error) {
+	writer := csv.NewWriter(w)
+	columns := DefaultCSVColumns
+	if err := writer.Write([]string{columns.Id, columns.Name, columns.Year, columns.Artists}); err != nil {
+		return 0, err
+	}
+
+	exported := 0
+	var cursor []interface{}
+	for {
+		items, keys, err := queryReleases(db, query, sort, exportBatchSize, 0, cursor, false)
+		if err != nil {
+			return exported, fmt.Errorf("failed to get releases: %w", err)
+		}
+		if len(items) == 0 {
+			break
+		}
+
+		releaseIds := make([]int, 0, len(items))
+		for _, item := range items {
+			releaseIds = append(releaseIds, item["release_id"].(int))
+		}
+		releases, err := getReleasesByIds(db, releaseIds)
+		if err != nil {
+			return exported, fmt.Errorf("failed to get releases: %w", err)
+		}
+
+		for _, release := range releases {
+			artists := make([]string, len(release.Artists))
+			for i, artist := range release.Artists {
+				artists[i] = artist.Name
+			}
+			row := []string{strconv.Itoa(release.Id), release.Name, strconv.Itoa(release.Year), strings.Join(artists, csvArtistSeparator+" ")}
+			if err := writer.Write(row); err != nil {
+				return exported, err
+			}
+			exported++
+		}
+
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return exported, err
+		}
+		cursor = keys[len(keys)-1]
+	}
+
+	writer.Flush()
+	return exported, writer.Error()
+}
@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportJSON(t *testing.T) {
+	t.Run("Empty Catalog", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+
+		var out bytes.Buffer
+		exported, err := ExportJSON(db, &out)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, exported)
+		assert.JSONEq(t, `[]`, out.String())
+	})
+
+	t.Run("Exports Every Release", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		if err := SeedDB(db); err != nil {
+			t.Fatalf("Failed to seed database: %v", err)
+		}
+
+		var out bytes.Buffer
+		exported, err := ExportJSON(db, &out)
+		assert.NoError(t, err)
+		assert.Equal(t, 30, exported)
+
+		var releases []Release
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &releases))
+		assert.Len(t, releases, 30)
+		assert.Equal(t, Release{Id: 1, Name: "Album 1", Year: 1991, Formats: []Format{}, Credit: "Queen", Credits: []Credit{{Artist: Artist{Id: 1, Name: "Queen"}, Role: RoleMain}}, Artists: []Artist{{Id: 1, Name: "Queen"}}, Labels: []ReleaseLabel{}, Genres: []Tag{}, Tags: []Tag{}}, releases[0])
+	})
+}
+
+func TestExportCSV(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'David Bowie')")
+	mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Jazz', 1978), (2, 'Under Pressure', 1981), (3, 'Low', 1977)")
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1), (2, 1), (2, 2), (3, 2)")
+
+	t.Run("Exports Every Release Once", func(t *testing.T) {
+		var out bytes.Buffer
+		exported, err := ExportCSV(db, &out, SearchQuery{}, SortArtist)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, exported)
+		assert.Equal(t, "id,name,year,artists\n"+
+			"3,Low,1977,David Bowie\n"+
+			"1,Jazz,1978,Queen\n"+
+			"2,Under Pressure,1981,Queen; David Bowie\n", out.String())
+	})
+
+	t.Run("Exports Search Results", func(t *testing.T) {
+		var out bytes.Buffer
+		exported, err := ExportCSV(db, &out, mustParseSearchQuery(t, "year:1978..1990"), SortYear)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, exported)
+		assert.Equal(t, "id,name,year,artists\n1,Jazz,1978,Queen\n2,Under Pressure,1981,Queen; David Bowie\n", out.String())
+	})
+}
@@ -0,0 +1,199 @@
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Formats a release can come out on
+const (
+	FormatVinyl    = "vinyl"
+	FormatCD       = "cd"
+	FormatCassette = "cassette"
+	FormatDigital  = "digital"
+)
+
+// FormatNames lists the formats in the order the release form offers them
+var FormatNames = []string{FormatVinyl, FormatCD, FormatCassette, FormatDigital}
+
+// formatLabels are the names formats are shown with
+var formatLabels = map[string]string{
+	FormatVinyl:    "Vinyl",
+	FormatCD:       "CD",
+	FormatCassette: "Cassette",
+	FormatDigital:  "Digital",
+}
+
+// FormatDescriptors are the descriptors the release form suggests
+var FormatDescriptors = []string{"LP", "EP", "Single", "Album", "Compilation", `7"`, `12"`, "Reissue", "Remastered", "Limited Edition", "Stereo", "Mono"}
+
+// maxFormatQuantity and maxDescriptorLength keep formats to something that
+// fits a line
+const (
+	maxFormatQuantity   = 100
+	maxDescriptorLength = 30
+)
+
+// Format is one of the formats a release came out on, like 2 x vinyl with
+// the descriptors LP and Album
+type Format struct {
+	Name        string   `json:"name"`
+	Quantity    int      `json:"quantity"`
+	Descriptors []string `json:"descriptors"`
+}
+
+// FormatLabel returns the name a format is shown with, like "CD"
+func FormatLabel(name string) string {
+	if label, ok := formatLabels[name]; ok {
+		return label
+	}
+	return name
+}
+
+// String renders a format the way it's listed, like "2 × Vinyl, LP, Album"
+func (f Format) String() string {
+	s := FormatLabel(f.Name)
+	if f.Quantity > 1 {
+		s = strconv.Itoa(f.Quantity) + " × " + s
+	}
+	for _, descriptor := range f.Descriptors {
+		s += ", " + descriptor
+	}
+	return s
+}
+
+// DescriptorsString joins the descriptors the way they're typed in the
+// release form, like "LP, Album"
+func (f Format) DescriptorsString() string {
+	return strings.Join(f.Descriptors, ", ")
+}
+
+// formatsString renders the formats of a release on one line, like
+// "2 × Vinyl, LP + CD"
+func formatsString(formats []Format) string {
+	parts := make([]string, len(formats))
+	for i, format := range formats {
+		parts[i] = format.String()
+	}
+	return strings.Join(parts, " + ")
+}
+
+// splitDescriptors splits comma separated descriptors, as they're stored and
+// typed in the release form
+func splitDescriptors(descriptors string) []string {
+	if strings.TrimSpace(descriptors) == "" {
+		return nil
+	}
+	return strings.Split(descriptors, ",")
+}
+
+// normalizeFormats drops blank form rows, lowercases format names, defaults
+// quantities to 1 and trims descriptors, keeping the first of descriptors
+// repeated ignoring case. It returns a message describing the first invalid
+// format.
+func normalizeFormats(formats []Format) ([]Format, string) {
+	normalized := []Format{}
+	for _, format := range formats {
+		format.Name = strings.ToLower(strings.TrimSpace(format.Name))
+
+		// Descriptors are stored comma separated, so commas split them
+		descriptors := []string{}
+		seen := map[string]bool{}
+		for _, descriptor := range splitDescriptors(strings.Join(format.Descriptors, ",")) {
+			descriptor = strings.Join(strings.Fields(descriptor), " ")
+			if descriptor == "" || seen[strings.ToLower(descriptor)] {
+				continue
+			}
+			seen[strings.ToLower(descriptor)] = true
+			if utf8.RuneCountInString(descriptor) > maxDescriptorLength {
+				return nil, fmt.Sprintf("Descriptors can be at most %d characters", maxDescriptorLength)
+			}
+			descriptors = append(descriptors, descriptor)
+		}
+		format.Descriptors = descriptors
+
+		if format.Name == "" {
+			if len(descriptors) > 0 {
+				return nil, fmt.Sprintf("Descriptors %s need a format", strings.Join(descriptors, ", "))
+			}
+			continue
+		}
+		if !slices.Contains(FormatNames, format.Name) {
+			return nil, fmt.Sprintf("Unknown format %q, use vinyl, cd, cassette or digital", format.Name)
+		}
+
+		if format.Quantity == 0 {
+			format.Quantity = 1
+		}
+		if format.Quantity < 1 || format.Quantity > maxFormatQuantity {
+			return nil, fmt.Sprintf("Quantity must be between 1 and %d", maxFormatQuantity)
+		}
+
+		normalized = append(normalized, format)
+	}
+	return normalized, ""
+}
+
+// setReleaseFormats replaces the formats of a release, keeping their order
+func setReleaseFormats(tx *sql.Tx, releaseId int, formats []Format) error {
+	if _, err := tx.Exec("DELETE FROM release_formats WHERE release_id = ?", releaseId); err != nil {
+		return err
+	}
+
+	for i, format := range formats {
+		_, err := tx.Exec(
+			"INSERT INTO release_formats (release_id, name, quantity, descriptors, position) VALUES (?, ?, ?, ?, ?)",
+			releaseId, format.Name, format.Quantity, strings.Join(format.Descriptors, ", "), i+1,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getReleasesFormats loads the formats of the releases with the ids in args,
+// in order. placeholders is the "?,?" list matching args.
+func getReleasesFormats(db *sql.DB, placeholders string, args []interface{}) (map[int][]Format, error) {
+	rows, err := db.Query(`
+		SELECT release_id, name, quantity, descriptors
+		FROM release_formats
+		WHERE release_id IN (`+placeholders+`)
+		ORDER BY release_id, position, id;
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	formats := map[int][]Format{}
+	for rows.Next() {
+		var releaseId int
+		var format Format
+		var descriptors string
+		if err := rows.Scan(&releaseId, &format.Name, &format.Quantity, &descriptors); err != nil {
+			return nil, err
+		}
+		format.Descriptors = []string{}
+		for _, descriptor := range splitDescriptors(descriptors) {
+			format.Descriptors = append(format.Descriptors, strings.TrimSpace(descriptor))
+		}
+		formats[releaseId] = append(formats[releaseId], format)
+	}
+	return formats, rows.Err()
+}
+
+// formatReleaseIds is a query for the ids of releases with a format named
+// value, like vinyl, or with a format described by value, like LP, ignoring case
+func formatReleaseIds(value string) (string, []interface{}) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if slices.Contains(FormatNames, value) {
+		return "SELECT release_id FROM release_formats WHERE name = ?", []interface{}{value}
+	}
+	return `SELECT release_id FROM release_formats WHERE ', ' || lower(descriptors) || ', ' LIKE ? ESCAPE '\'`,
+		[]interface{}{"%, " + escapeLike(value) + ", %"}
+}
@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatString(t *testing.T) {
+	assert.Equal(t, "CD", Format{Name: FormatCD, Quantity: 1}.String())
+	assert.Equal(t, "2 × Vinyl, LP, Album", Format{Name: FormatVinyl, Quantity: 2, Descriptors: []string{"LP", "Album"}}.String())
+	assert.Equal(t, "2 × Vinyl, LP + CD", formatsString([]Format{
+		{Name: FormatVinyl, Quantity: 2, Descriptors: []string{"LP"}},
+		{Name: FormatCD, Quantity: 1},
+	}))
+	assert.Equal(t, "", formatsString(nil))
+}
+
+func TestNormalizeFormats(t *testing.T) {
+	formats, problem := normalizeFormats([]Format{
+		{Name: " Vinyl ", Descriptors: []string{"LP,  Limited   Edition", "lp", ""}},
+		{},
+		{Name: "cd", Quantity: 2},
+	})
+	assert.Empty(t, problem)
+	assert.Equal(t, []Format{
+		{Name: FormatVinyl, Quantity: 1, Descriptors: []string{"LP", "Limited Edition"}},
+		{Name: FormatCD, Quantity: 2, Descriptors: []string{}},
+	}, formats)
+
+	tests := []struct {
+		format  Format
+		problem string
+	}{
+		{Format{Name: "minidisc"}, `Unknown format "minidisc", use vinyl, cd, cassette or digital`},
+		{Format{Name: FormatCD, Quantity: -1}, "Quantity must be between 1 and 100"},
+		{Format{Name: FormatCD, Quantity: 101}, "Quantity must be between 1 and 100"},
+		{Format{Descriptors: []string{"LP"}}, "Descriptors LP need a format"},
+		{Format{Name: FormatVinyl, Descriptors: []string{"Limited Numbered Gatefold Edition!"}}, "Descriptors can be at most 30 characters"},
+	}
+	for _, test := range tests {
+		_, problem := normalizeFormats([]Format{test.format})
+		assert.Equal(t, test.problem, problem, "%+v", test.format)
+	}
+}
+
+func TestReleaseFormats(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'Bowie')")
+
+	var opera Release
+
+	t.Run("Create With Formats", func(t *testing.T) {
+		var err error
+		opera, err = createRelease(db, ReleaseInput{
+			Name:      "A Night at the Opera",
+			Year:      1975,
+			Country:   "UK",
+			ArtistIds: []int{1},
+			Formats: []Format{
+				{Name: FormatVinyl, Descriptors: []string{"LP", "Album", "Gatefold"}},
+				{Name: FormatCD, Quantity: 2, Descriptors: []string{"Remastered"}},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "Vinyl, LP, Album, Gatefold + 2 × CD, Remastered", opera.FormatsString())
+
+		_, err = createRelease(db, ReleaseInput{
+			Name:      "Heroes",
+			Year:      1977,
+			Country:   "Germany",
+			ArtistIds: []int{2},
+			Formats:   []Format{{Name: FormatCassette}},
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Search By Format And Country", func(t *testing.T) {
+		assert.Equal(t, []string{"Queen"}, searchCredits(t, db, "format:vinyl"))
+		assert.Equal(t, []string{"Queen"}, searchCredits(t, db, "format:Remastered"))
+		assert.Equal(t, []string{"Bowie"}, searchCredits(t, db, "format:CASSETTE"))
+		assert.Equal(t, []string{"Bowie"}, searchCredits(t, db, "-format:lp"))
+		// Descriptors match whole
+		assert.Empty(t, searchCredits(t, db, "format:fold"))
+		assert.Equal(t, []string{"Queen"}, searchCredits(t, db, "country:uk opera"))
+		assert.Equal(t, []string{"Bowie"}, searchCredits(t, db, "-country:UK"))
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		_, err := createRelease(db, ReleaseInput{Name: "Jazz", Year: 1978, Formats: []Format{{Name: "8-track"}}})
+		var validationErr *ValidationError
+		if assert.True(t, errors.As(err, &validationErr)) {
+			assert.Equal(t, map[string]string{"formats": `Unknown format "8-track", use vinyl, cd, cassette or digital`}, validationErr.Fields)
+		}
+	})
+
+	t.Run("Deleting A Release Deletes Its Formats", func(t *testing.T) {
+		assert.NoError(t, deleteRelease(db, opera.Id))
+		assert.Equal(t, 1, countRows(t, db, "release_formats"))
+	})
+}
@@ -4,18 +4,88 @@ import (
 	"database/sql"
 	"fmt"
 	"github.com/labstack/echo/v4"
+	"html/template"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 )
 
-func getReleasesCount(db *sql.DB, searchQuery string) (int, error) {
-	query := "SELECT COUNT(*) FROM releases_fts"
-	var args []interface{}
-	if searchQuery != "" {
-		query = "SELECT COUNT(*) FROM releases_fts WHERE releases_fts MATCH ?"
-		args = append(args, searchQuery)
+// Sort orders accepted by the sort parameter of the releases list
+const (
+	SortRelevance = "relevance"
+	SortYear      = "year"
+	SortYearDesc  = "year_desc"
+	SortName      = "name"
+	SortArtist    = "artist"
+)
+
+// releaseSortOrder is how the releases list is ordered for one sort parameter
+type releaseSortOrder struct {
+	// keys are the expressions rows are ordered by. They end with the
+	// releases_fts rowid so every row has a unique position for cursors.
+	keys       []string
+	descending bool
+}
+
+var releaseSortOrders = map[string]releaseSortOrder{
+	// bm25 weights: release_id is unindexed, matches in the release name
+	// count most, then the artist names and credit, then track titles and
+	// label names, then the year
+	SortRelevance: {keys: []string{"bm25(releases_fts, 0.0, 10.0, 1.0, 5.0, 2.0, 2.0, 5.0)", "release_year", "release_id", "rowid"}},
+	SortYear:      {keys: []string{"release_year", "release_id", "rowid"}},
+	SortYearDesc:  {keys: []string{"release_year", "release_id", "rowid"}, descending: true},
+	SortName:      {keys: []string{"lower(release_name)", "release_year", "release_id", "rowid"}},
+	SortArtist:    {keys: []string{"lower(COALESCE(NULLIF(artist_credit, ''), artist_names))", "release_year", "release_id", "rowid"}},
+}
+
+// releaseSort returns the sort order to use for a sort parameter and search.
+// Searches are sorted by relevance unless another order is asked for. Relevance
+// needs a full text match, so anything else falls back to year.
+func releaseSort(sort string, query SearchQuery) string {
+	if _, ok := releaseSortOrders[sort]; !ok {
+		sort = SortRelevance
 	}
+	if sort == SortRelevance && !query.ranked() {
+		return SortYear
+	}
+	return sort
+}
+
+// IsValidReleaseSort reports whether sort is one of the release sort orders
+func IsValidReleaseSort(sort string) bool {
+	_, ok := releaseSortOrders[sort]
+	return ok
+}
+
+// releaseSearchParams reads the search and facets of the releases list from
+// the q, decade and artist query parameters, and collapse=masters to list one
+// release for each master. Invalid decades are ignored like invalid page
+// numbers. Invalid searches return a *ValidationError.
+func releaseSearchParams(c echo.Context) (SearchQuery, error) {
+	query, err := ParseSearchQuery(c.QueryParam("q"))
+	if err != nil {
+		return SearchQuery{}, err
+	}
+
+	if decade, err := strconv.Atoi(c.QueryParam("decade")); err == nil && decade > 0 && decade < 10000 && decade%10 == 0 {
+		query.Decade = decade
+	}
+	query.Artist = strings.TrimSpace(c.QueryParam("artist"))
+	query.CollapseMasters = c.QueryParam("collapse") == "masters"
+	return query, nil
+}
+
+func getReleasesCount(db *sql.DB, query SearchQuery) (int, error) {
+	where, args := query.filter()
+
+	counted := "*"
+	if query.CollapseMasters {
+		counted = "DISTINCT " + masterGroupKey
+	}
+
 	var count int
-	err := db.QueryRow(query, args...).Scan(&count)
+	err := db.QueryRow("SELECT COUNT("+counted+") FROM releases_fts "+where, args...).Scan(&count)
 	if err != nil {
 		return 0, err
 	}
@@ -26,13 +96,20 @@ func getPaginatedReleases(
 	db *sql.DB,
 	pageStr string,
 	limitStr string,
-	searchQuery string,
+	defaultPageSize int,
+	searchQuery SearchQuery,
+	sort string,
 	logger echo.Logger,
 	request *http.Request,
 ) ([]map[string]interface{},
 	Pagination,
 	error,
 ) {
+	// Cursor mode skips the count and pages from the after or before cursor
+	if params := request.URL.Query(); params.Has("after") || params.Has("before") {
+		return getReleasesByCursor(db, limitStr, defaultPageSize, searchQuery, sort, request)
+	}
+
 	totalCount, err := getReleasesCount(db, searchQuery)
 	if err != nil {
 		logger.Printf("Failed to get releases count: %v", err)
@@ -41,16 +118,35 @@ func getPaginatedReleases(
 	pagination, err := getPagination(
 		pageStr,
 		limitStr,
+		defaultPageSize,
 		totalCount,
 		request,
 	)
+	if err != nil {
+		return nil, pagination, err
+	}
 
-	releases, err := getReleases(db, pagination.Limit, pagination.Offset, searchQuery, logger)
+	releases, keys, err := queryReleases(db, searchQuery, sort, pagination.Limit, pagination.Offset, nil, false)
+	if err != nil {
+		return nil, pagination, err
+	}
 
-	return releases, pagination, err
+	// Large result sets link to the next and previous pages with cursors, so
+	// paging on from here doesn't count or skip rows again
+	if totalCount > cursorPaginationThreshold && len(keys) > 0 {
+		sort = releaseSort(sort, searchQuery)
+		if pagination.NextUrl != nil {
+			pagination.setNextCursor(request, encodeReleaseCursor(sort, keys[len(keys)-1]))
+		}
+		if pagination.PrevUrl != nil {
+			pagination.setPrevCursor(request, encodeReleaseCursor(sort, keys[0]))
+		}
+	}
+
+	return releases, pagination, nil
 }
 
-func getReleases(db *sql.DB, limit int, offset int, searchQuery string, logger echo.Logger) ([]map[string]interface{}, error) {
+func getReleases(db *sql.DB, limit int, offset int, searchQuery SearchQuery, sort string, logger echo.Logger) ([]map[string]interface{}, error) {
 	// Validate inputs
 	if limit <= 0 {
 		return nil, fmt.Errorf("invalid limit: %d", limit)
@@ -59,58 +155,244 @@ func getReleases(db *sql.DB, limit int, offset int, searchQuery string, logger e
 		return nil, fmt.Errorf("invalid offset: %d", offset)
 	}
 
-	var query string
-	var args []interface{}
-	if searchQuery != "" {
-		query = `
+	items, _, err := queryReleases(db, searchQuery, sort, limit, offset, nil, false)
+	return items, err
+}
+
+// queryReleases returns up to limit releases matching searchQuery in sort
+// order, skipping offset releases. Given the sort keys of a row as a cursor,
+// only rows after it are returned, or with backward the rows just before it.
+// The sort keys of each row are returned alongside so cursors can be made from them.
+// Collapsed to masters, each master is listed at the position of its first
+// matching version, with the number of versions that match.
+func queryReleases(
+	db *sql.DB,
+	searchQuery SearchQuery,
+	sort string,
+	limit int,
+	offset int,
+	cursor []interface{},
+	backward bool,
+) ([]map[string]interface{}, [][]interface{}, error) {
+	order := releaseSortOrders[releaseSort(sort, searchQuery)]
+	where, args := searchQuery.filter()
+
+	// highlight() needs a full text match, so other queries select the names twice
+	highlights := "release_name AS release_name_highlight, artist_names AS artist_names_highlight, artist_credit AS artist_credit_highlight"
+	if searchQuery.ranked() {
+		highlights = "highlight(releases_fts, 1, char(2), char(3)) AS release_name_highlight, " +
+			"highlight(releases_fts, 3, char(2), char(3)) AS artist_names_highlight, " +
+			"highlight(releases_fts, 6, char(2), char(3)) AS artist_credit_highlight"
+	}
+
+	keyColumns := make([]string, len(order.keys))
+	keyNames := make([]string, len(order.keys))
+	for i, key := range order.keys {
+		keyNames[i] = fmt.Sprintf("sort_key_%d", i)
+		keyColumns[i] = key + " AS " + keyNames[i]
+	}
+
+	// Backward pages read the list in reverse from the cursor and are flipped back afterwards
+	descending := order.descending != backward
+	direction, comparison := "ASC", ">"
+	if descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	var conditions []string
+	if cursor != nil {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(cursor)), ", ")
+		conditions = append(conditions, "("+strings.Join(keyNames, ", ")+") "+comparison+" ("+placeholders+")")
+		args = append(args, cursor...)
+	}
+	args = append(args, limit, offset)
+
+	matched := `
 		SELECT
 			release_id,
 			release_name,
 			release_year,
-			artist_name
+			artist_names,
+			artist_credit,
+			` + highlights + `,
+			` + masterGroupKey + ` AS group_key,
+			(SELECT country FROM releases WHERE releases.id = releases_fts.release_id) AS country,
+			` + strings.Join(keyColumns, ", ") + `
 		FROM releases_fts
-		WHERE releases_fts MATCH ?
-		ORDER BY release_year ASC
-		LIMIT ?
-		OFFSET ?;
-		`
-		args = append(args, searchQuery, limit, offset)
-	} else {
-		query = `
+		` + where
+
+	// Versions are ranked in list order whichever way the page is read, so
+	// the same version stands for its master going forwards and backwards
+	versionCount := "1"
+	if searchQuery.CollapseMasters {
+		versionOrder := "ASC"
+		if order.descending {
+			versionOrder = "DESC"
+		}
+		matched = `
+		SELECT
+			*,
+			row_number() OVER (PARTITION BY group_key ORDER BY ` + strings.Join(keyNames, " "+versionOrder+", ") + " " + versionOrder + `) AS version_rank,
+			count(*) OVER (PARTITION BY group_key) AS version_count
+		FROM (` + matched + `)`
+		versionCount = "version_count"
+		conditions = append([]string{"version_rank = 1"}, conditions...)
+	}
+
+	filter := ""
+	if len(conditions) > 0 {
+		filter = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := `
 		SELECT
 			release_id,
 			release_name,
 			release_year,
-			artist_name
-		FROM releases_fts
-		ORDER BY release_year ASC
+			artist_names,
+			artist_credit,
+			release_name_highlight,
+			artist_names_highlight,
+			artist_credit_highlight,
+			group_key,
+			country,
+			` + versionCount + `,
+			` + strings.Join(keyNames, ", ") + `
+		FROM (` + matched + `)
+		` + filter + `
+		ORDER BY ` + strings.Join(keyNames, " "+direction+", ") + " " + direction + `
 		LIMIT ?
 		OFFSET ?;
 		`
-		args = append(args, limit, offset)
-	}
 
 	rows, err := db.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	defer rows.Close()
 
 	var items []map[string]interface{}
+	var keys [][]interface{}
 	for rows.Next() {
-		var releaseId int
-		var releaseName, artistName, releaseYear string
-		err := rows.Scan(&releaseId, &releaseName, &releaseYear, &artistName)
-		if err != nil {
-			return nil, err
+		var releaseId, groupKey, versionCount int
+		var releaseName, artistNames, artistCredit, releaseYear, country string
+		var releaseNameHighlight, artistNamesHighlight, artistCreditHighlight string
+		rowKeys := make([]interface{}, len(order.keys))
+		dest := []interface{}{&releaseId, &releaseName, &releaseYear, &artistNames, &artistCredit, &releaseNameHighlight, &artistNamesHighlight, &artistCreditHighlight, &groupKey, &country, &versionCount}
+		for i := range rowKeys {
+			dest = append(dest, &rowKeys[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, nil, err
+		}
+
+		// Text keys scan as []byte, which SQLite compares as blobs when
+		// they're passed back as a cursor
+		for i, key := range rowKeys {
+			if b, ok := key.([]byte); ok {
+				rowKeys[i] = string(b)
+			}
+		}
+
+		// Releases with only producer or remixer credits have no credit
+		// string, so they show the names of their artists
+		if artistCredit == "" {
+			artistCredit = strings.ReplaceAll(artistNames, "\n", ", ")
+			artistCreditHighlight = strings.ReplaceAll(artistNamesHighlight, "\n", ", ")
+		}
+
+		// Releases without artists have an empty list rather than one blank name
+		names := []string{}
+		if artistNames != "" {
+			names = strings.Split(artistNames, "\n")
 		}
 
 		items = append(items, map[string]interface{}{
-			"release_id":   releaseId,
-			"artist_name":  artistName,
-			"release_year": releaseYear,
-			"release_name": releaseName,
+			"release_id":    releaseId,
+			"artist_names":  names,
+			"artist_credit": artistCredit,
+			"release_year":  releaseYear,
+			"release_name":  releaseName,
+			"master_id":     max(groupKey, 0),
+			"version_count": versionCount,
+			"country":       country,
+			"formats":       "",
+
+			"release_name_html":  highlightHTML(releaseNameHighlight),
+			"artist_credit_html": highlightHTML(artistCreditHighlight),
 		})
+		keys = append(keys, rowKeys)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
 	}
 
-	return items, nil
+	if err := addItemFormats(db, items); err != nil {
+		return nil, nil, err
+	}
+
+	if backward {
+		slices.Reverse(items)
+		slices.Reverse(keys)
+	}
+	return items, keys, nil
+}
+
+// addItemFormats sets the formats of each listed release, on one line like
+// "2 × Vinyl, LP + CD"
+func addItemFormats(db *sql.DB, items []map[string]interface{}) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(items))
+	for i, item := range items {
+		args[i] = item["release_id"]
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(args)), ",")
+	formats, err := getReleasesFormats(db, placeholders, args)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		item["formats"] = formatsString(formats[item["release_id"].(int)])
+	}
+	return nil
+}
+
+// Markers highlight() puts around matched text, as char(2) and char(3) in SQL
+const (
+	highlightStart = "\x02"
+	highlightEnd   = "\x03"
+)
+
+// highlightHTML escapes text marked up by highlight() and wraps the matched
+// spans in <mark>. Only the mark tags are written unescaped, so names can't
+// inject HTML. Markers stored in a name can at worst move a highlight.
+func highlightHTML(marked string) template.HTML {
+	var b strings.Builder
+	inMatch := false
+	for {
+		i := strings.IndexAny(marked, highlightStart+highlightEnd)
+		if i < 0 {
+			b.WriteString(template.HTMLEscapeString(marked))
+			break
+		}
+		b.WriteString(template.HTMLEscapeString(marked[:i]))
+
+		switch marker := marked[i : i+1]; {
+		case marker == highlightStart && !inMatch:
+			b.WriteString("<mark>")
+			inMatch = true
+		case marker == highlightEnd && inMatch:
+			b.WriteString("</mark>")
+			inMatch = false
+		}
+		marked = marked[i+1:]
+	}
+	if inMatch {
+		b.WriteString("</mark>")
+	}
+	return template.HTML(b.String())
 }
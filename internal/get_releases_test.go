@@ -3,9 +3,13 @@ package internal
 import (
 	"database/sql"
 	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
 	"testing"
 
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestGetReleases(t *testing.T) {
@@ -22,7 +26,7 @@ func TestGetReleases(t *testing.T) {
 	populateReleasesFtsTable(db)
 
 	t.Run("Valid Limit and Offset", func(t *testing.T) {
-		releases, err := getReleases(db, 5, 0, "", nil)
+		releases, err := getReleases(db, 5, 0, SearchQuery{}, "", nil)
 		if err != nil {
 			t.Fatalf("Failed to fetch releases: %v", err)
 		}
@@ -33,7 +37,7 @@ func TestGetReleases(t *testing.T) {
 	})
 
 	t.Run("Offset Exceeds Data", func(t *testing.T) {
-		releases, err := getReleases(db, 5, 100, "", nil)
+		releases, err := getReleases(db, 5, 100, SearchQuery{}, "", nil)
 		if err != nil {
 			t.Fatalf("Unexpected error: %v", err)
 		}
@@ -44,11 +48,187 @@ func TestGetReleases(t *testing.T) {
 	})
 
 	t.Run("Invalid Limit", func(t *testing.T) {
-		_, err := getReleases(db, -1, 0, "", nil)
+		_, err := getReleases(db, -1, 0, SearchQuery{}, "", nil)
 		if err == nil {
 			t.Fatalf("Expected error for invalid limit, but got nil")
 		}
 	})
+
+	t.Run("Invalid Page Size", func(t *testing.T) {
+		request := &http.Request{URL: &url.URL{Path: "/releases"}}
+		_, _, err := getPaginatedReleases(db, "", "", 0, SearchQuery{}, "", nil, request)
+		if err == nil {
+			t.Fatalf("Expected error for invalid page size, but got nil")
+		}
+	})
+}
+
+func TestGetReleasesMultiArtist(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	createTestTables(db)
+	seedTestReleases(db)
+	seedTestArtists(db)
+	seedTestReleaseArtists(db)
+	if err := seedMultiArtistReleases(db); err != nil {
+		t.Fatal(err)
+	}
+	populateReleasesFtsTable(db)
+
+	t.Run("One Row Per Release", func(t *testing.T) {
+		releases, err := getReleases(db, 10, 0, mustParseSearchQuery(t, "split"), SortYear, nil)
+		assert.NoError(t, err)
+		if assert.Len(t, releases, 1) {
+			assert.Equal(t, 32, releases[0]["release_id"])
+			assert.Equal(t, []string{"Artist 1", "Artist 2", "Artist 3"}, releases[0]["artist_names"])
+			assert.Equal(t, "Artist 1, Artist 2 feat. Artist 3", releases[0]["artist_credit"])
+		}
+	})
+
+	t.Run("Counts Releases", func(t *testing.T) {
+		count, err := getReleasesCount(db, SearchQuery{})
+		assert.NoError(t, err)
+		assert.Equal(t, 32, count)
+
+		count, err = getReleasesCount(db, SearchQuery{Artist: "Artist 2"})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, count)
+	})
+
+	t.Run("Pages Through Each Release Once", func(t *testing.T) {
+		seen := map[int]bool{}
+		for offset := 0; offset < 40; offset += 5 {
+			releases, err := getReleases(db, 5, offset, SearchQuery{}, SortYear, nil)
+			assert.NoError(t, err)
+			for _, release := range releases {
+				id := release["release_id"].(int)
+				assert.False(t, seen[id], "release %d listed twice", id)
+				seen[id] = true
+			}
+		}
+		assert.Len(t, seen, 32)
+	})
+
+	t.Run("Artist Facets Count Releases", func(t *testing.T) {
+		facets, err := getReleaseFacets(db, mustParseSearchQuery(t, "year:2021.."))
+		assert.NoError(t, err)
+		assert.Equal(t, []ArtistFacet{{Name: "Artist 1", Count: 2}, {Name: "Artist 2", Count: 2}, {Name: "Artist 3", Count: 1}}, facets.Artists)
+		assert.Equal(t, []DecadeFacet{{Decade: 2020, Count: 2}}, facets.Decades)
+	})
+}
+
+func TestReleaseSort(t *testing.T) {
+	tests := []struct {
+		sort        string
+		searchQuery string
+		expected    string
+	}{
+		{"", "", SortYear},
+		{"", "queen", SortRelevance},
+		{"", "u2", SortYear},
+		{"", "-queen year:1990", SortYear},
+		{"", `"unterminated`, SortYear},
+		{"relevance", "", SortYear},
+		{"relevance", "queen", SortRelevance},
+		{"name", "queen", SortName},
+		{"year_desc", "", SortYearDesc},
+		{"artist", "", SortArtist},
+		{"bogus", "queen", SortRelevance},
+		{"bogus", "", SortYear},
+	}
+
+	for _, test := range tests {
+		query, _ := ParseSearchQuery(test.searchQuery)
+		assert.Equal(t, test.expected, releaseSort(test.sort, query), "releaseSort(%q, %q)", test.sort, test.searchQuery)
+	}
+}
+
+func TestGetReleasesSorted(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'bowie'), (3, 'Queen Latifah')")
+	mustExec(t, db, `INSERT INTO releases (id, name, year) VALUES
+		(1, 'Live at Wembley', 1992),
+		(2, 'Queen', 1973),
+		(3, 'black tie', 1993),
+		(4, 'All Hail the Queen', 1989)`)
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1), (2, 1), (3, 2), (4, 3)")
+
+	names := func(searchQuery string, sort string) []string {
+		t.Helper()
+		releases, err := getReleases(db, 10, 0, mustParseSearchQuery(t, searchQuery), sort, nil)
+		if err != nil {
+			t.Fatalf("Failed to fetch releases: %v", err)
+		}
+		result := []string{}
+		for _, release := range releases {
+			result = append(result, release["release_name"].(string))
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"Queen", "All Hail the Queen", "Live at Wembley", "black tie"}, names("", ""))
+	assert.Equal(t, []string{"black tie", "Live at Wembley", "All Hail the Queen", "Queen"}, names("", SortYearDesc))
+	assert.Equal(t, []string{"All Hail the Queen", "black tie", "Live at Wembley", "Queen"}, names("", SortName))
+	assert.Equal(t, []string{"black tie", "Queen", "Live at Wembley", "All Hail the Queen"}, names("", SortArtist))
+
+	// A release named after the search ranks above releases that only match by artist
+	assert.Equal(t, "Queen", names("queen", "")[0])
+	assert.Equal(t, []string{"Queen", "All Hail the Queen", "Live at Wembley"}, names("queen", SortYear))
+}
+
+func TestHighlightHTML(t *testing.T) {
+	tests := []struct {
+		marked   string
+		expected template.HTML
+	}{
+		{"Night Shift", "Night Shift"},
+		{"Night \x02Shi\x03ft", "Night <mark>Shi</mark>ft"},
+		{"\x02Queen\x03 & \x02Queen\x03", "<mark>Queen</mark> &amp; <mark>Queen</mark>"},
+		{"<b>\x02bold\x03</b>", "&lt;b&gt;<mark>bold</mark>&lt;/b&gt;"},
+		{"\x02<script>alert(1)</script>\x03", "<mark>&lt;script&gt;alert(1)&lt;/script&gt;</mark>"},
+		{"stray\x03 \x02\x02nested\x03", "stray <mark>nested</mark>"},
+		{"unclosed \x02match", "unclosed <mark>match</mark>"},
+		{`"quoted" 'single'`, "&#34;quoted&#34; &#39;single&#39;"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, highlightHTML(test.marked), "highlightHTML(%q)", test.marked)
+	}
+}
+
+func TestGetReleasesHighlights(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, '<script>alert(\"queen\")</script>')")
+	mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Queen II', 1974), (2, 'Night Shift', 1990)")
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1), (2, 2)")
+
+	highlights := func(searchQuery string) []string {
+		t.Helper()
+		releases, err := getReleases(db, 10, 0, mustParseSearchQuery(t, searchQuery), SortYear, nil)
+		if err != nil {
+			t.Fatalf("Failed to fetch releases: %v", err)
+		}
+		result := []string{}
+		for _, release := range releases {
+			result = append(result, fmt.Sprintf("%s / %s", release["release_name_html"], release["artist_credit_html"]))
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{
+		"<mark>Queen</mark> II / <mark>Queen</mark>",
+		"Night Shift / &lt;script&gt;alert(&#34;<mark>queen</mark>&#34;)&lt;/script&gt;",
+	}, highlights("queen"))
+	assert.Equal(t, []string{"<mark>Queen</mark> II / Queen"}, highlights("release:queen"))
+	assert.Equal(t, []string{"<mark>Queen II</mark> / Queen"}, highlights(`"queen ii" -shift`))
+	assert.Equal(t, []string{"Night <mark>Shi</mark>ft / &lt;script&gt;alert(&#34;queen&#34;)&lt;/script&gt;"}, highlights("shi"))
+
+	// Searches without a full text match aren't highlighted but are still escaped
+	assert.Equal(t, []string{"Night Shift / &lt;script&gt;alert(&#34;queen&#34;)&lt;/script&gt;"}, highlights("year:1990"))
 }
 
 // Unique seeding functions for the test context
@@ -1,6 +1,12 @@
 package internal
 
-import "strconv"
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
 
 // Helper to parse integer with a default fallback
 func defaultInt(valStr string, defaultValue int) int {
@@ -10,3 +16,58 @@ func defaultInt(valStr string, defaultValue int) int {
 	}
 	return val
 }
+
+// Helper to parse the :id path parameter, treating anything invalid as not found
+func paramId(c echo.Context) (int, error) {
+	return paramNamedId(c, "id")
+}
+
+// Helper to parse an id path parameter such as :track_id like paramId
+func paramNamedId(c echo.Context, name string) (int, error) {
+	id, err := strconv.Atoi(c.Param(name))
+	if err != nil || id < 1 {
+		return 0, errNotFound
+	}
+	return id, nil
+}
+
+// Helper to check whether the request was made by HTMX
+func isHtmxRequest(c echo.Context) bool {
+	return c.Request().Header.Get("HX-Request") == "true"
+}
+
+// Helper to send the client to another page after a form submission. HTMX
+// requests get an HX-Redirect header instead of a 303 so the whole page loads.
+func redirectAfterSubmit(c echo.Context, url string) error {
+	if isHtmxRequest(c) {
+		c.Response().Header().Set("HX-Redirect", url)
+		return c.NoContent(http.StatusOK)
+	}
+	return c.Redirect(http.StatusSeeOther, url)
+}
+
+// Helper to render the not found page with a 404 status
+func renderNotFound(c echo.Context, message string) error {
+	return c.Render(http.StatusNotFound, "not_found", map[string]interface{}{
+		"Title":        "Not Found",
+		"Message":      message,
+		"CurrentRoute": c.Request().URL.Path,
+	})
+}
+
+// Helper to pick the status for a form re-rendered with validation errors.
+// HTMX only swaps successful responses, so HTMX requests get a 200.
+func invalidFormStatus(c echo.Context) int {
+	if isHtmxRequest(c) {
+		return http.StatusOK
+	}
+	return http.StatusUnprocessableEntity
+}
+
+// likeEscaper escapes the LIKE wildcards for patterns using ESCAPE '\'
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Helper to escape s for use in a LIKE pattern with ESCAPE '\'
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,265 @@
+package internal
+
+import (
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DefaultImportBatchSize is the number of records imported per transaction
+const DefaultImportBatchSize = 1000
+
+// importProgressInterval is the number of records read between progress reports
+const importProgressInterval = 10000
+
+// Kinds of rows that can have external IDs
+const (
+	entityRelease = "release"
+	entityArtist  = "artist"
+)
+
+// ImportStats counts the records read by an import
+type ImportStats struct {
+	Artists  int // Artists inserted or updated
+	Releases int // Releases inserted or updated
+	Skipped  int // Records missing data the catalog requires
+}
+
+func (s ImportStats) String() string {
+	return fmt.Sprintf("imported %d artists and %d releases, skipped %d records", s.Artists, s.Releases, s.Skipped)
+}
+
+func (s ImportStats) total() int {
+	return s.Artists + s.Releases + s.Skipped
+}
+
+// importCounts are the stats of an import or scan reported as progress
+type importCounts interface {
+	fmt.Stringer
+	total() int
+}
+
+// importProgress writes the import stats to w every importProgressInterval records
+type importProgress struct {
+	w        io.Writer
+	reported int
+}
+
+func (p *importProgress) report(stats importCounts) {
+	if p.w == nil || stats.total()-p.reported < importProgressInterval {
+		return
+	}
+	p.reported = stats.total()
+	fmt.Fprintf(p.w, "Progress: %s\n", stats)
+}
+
+// importTx upserts the records of one batch, matching them to the rows
+// created by earlier imports from source through their external IDs
+type importTx struct {
+	tx     *sql.Tx
+	source string
+}
+
+// importBatches calls importRecord in transactions of up to batchSize records
+// until it returns io.EOF. beforeCommit, when not nil, runs at the end of each
+// transaction, and committed after it. Batches committed before an error are
+// kept.
+func importBatches(
+	db *sql.DB,
+	source string,
+	batchSize int,
+	importRecord func(importTx) error,
+	beforeCommit func(importTx) error,
+	committed func(),
+) error {
+	if batchSize < 1 {
+		return fmt.Errorf("invalid batch size: %d", batchSize)
+	}
+
+	for done := false; !done; {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+
+		for i := 0; i < batchSize; i++ {
+			err := importRecord(importTx{tx: tx, source: source})
+			if errors.Is(err, io.EOF) {
+				done = true
+				break
+			}
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		if beforeCommit != nil {
+			if err := beforeCommit(importTx{tx: tx, source: source}); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		committed()
+	}
+	return nil
+}
+
+// localId returns the id of the entity row imported with externalId
+func (t importTx) localId(entity string, externalId string) (int, bool, error) {
+	var id int
+	err := t.tx.QueryRow(
+		"SELECT local_id FROM external_ids WHERE source = ? AND entity = ? AND external_id = ?",
+		t.source, entity, externalId,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to look up %s %s: %w", entity, externalId, err)
+	}
+	return id, true, nil
+}
+
+// upsert runs update with args and the local id when externalId was imported
+// before, and otherwise runs insert with args and records the new row's id
+func (t importTx) upsert(entity string, externalId string, update string, insert string, args ...interface{}) (int, error) {
+	id, ok, err := t.localId(entity, externalId)
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		if _, err := t.tx.Exec(update, append(args, id)...); err != nil {
+			return 0, fmt.Errorf("failed to update %s %s: %w", entity, externalId, err)
+		}
+		return id, nil
+	}
+
+	result, err := t.tx.Exec(insert, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert %s %s: %w", entity, externalId, err)
+	}
+	insertedId, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get id of %s %s: %w", entity, externalId, err)
+	}
+	id = int(insertedId)
+
+	_, err = t.tx.Exec(
+		"INSERT INTO external_ids (source, entity, external_id, local_id) VALUES (?, ?, ?, ?)",
+		t.source, entity, externalId, id,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record %s %s: %w", entity, externalId, err)
+	}
+	return id, nil
+}
+
+func (t importTx) upsertArtist(externalId string, name string) (int, error) {
+	return t.upsert(entityArtist, externalId,
+		"UPDATE artists SET name = ? WHERE id = ?",
+		"INSERT INTO artists (name) VALUES (?)",
+		name,
+	)
+}
+
+// ensureArtist returns the artist imported with externalId, inserting it when
+// there's none. Unlike upsertArtist it leaves existing names alone, so
+// release credits don't overwrite the names from an artists dump.
+func (t importTx) ensureArtist(externalId string, name string) (int, error) {
+	id, ok, err := t.localId(entityArtist, externalId)
+	if err != nil || ok {
+		return id, err
+	}
+	return t.upsertArtist(externalId, name)
+}
+
+// upsertDatedRelease inserts or updates a release with its year and release
+// date. releaseDate is normalized like parseReleaseDate's, or empty when only
+// the year is known.
+func (t importTx) upsertDatedRelease(externalId string, name string, year int, releaseDate string) (int, error) {
+	return t.upsert(entityRelease, externalId,
+		"UPDATE releases SET name = ?, year = ?, release_date = ? WHERE id = ?",
+		"INSERT INTO releases (name, year, release_date) VALUES (?, ?, ?)",
+		name, year, releaseDate,
+	)
+}
+
+// getImportCheckpoint returns the number of lines of dump committed by an
+// unfinished import from source, or 0 when there's none
+func getImportCheckpoint(db *sql.DB, source string, dump string) (int, error) {
+	var line int
+	err := db.QueryRow("SELECT line FROM import_checkpoints WHERE source = ? AND dump = ?", source, dump).Scan(&line)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get checkpoint: %w", err)
+	}
+	return line, nil
+}
+
+// setCheckpoint records that the lines of dump up to line are imported
+func (t importTx) setCheckpoint(dump string, line int) error {
+	_, err := t.tx.Exec(`
+		INSERT INTO import_checkpoints (source, dump, line) VALUES (?, ?, ?)
+		ON CONFLICT (source, dump) DO UPDATE SET line = excluded.line
+	`, t.source, dump, line)
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
+
+// clearImportCheckpoint forgets the checkpoint of a finished import
+func clearImportCheckpoint(db *sql.DB, source string, dump string) error {
+	if _, err := db.Exec("DELETE FROM import_checkpoints WHERE source = ? AND dump = ?", source, dump); err != nil {
+		return fmt.Errorf("failed to clear checkpoint: %w", err)
+	}
+	return nil
+}
+
+// importedReleaseDate returns the normalized release date and year of a date
+// from a dump. Dates that only have a usable year, like 1999-13, keep the
+// year with an empty release date.
+func importedReleaseDate(date string) (string, int, bool) {
+	if normalized, year, ok := parseReleaseDate(date); ok {
+		return normalized, year, true
+	}
+	year, ok := releaseDateYear(date)
+	return "", year, ok
+}
+
+// releaseDateYear returns the year of a release date such as 1999, 1999-03,
+// 1999-03-00 or 1999-03-15
+func releaseDateYear(date string) (int, bool) {
+	date = strings.TrimSpace(date)
+	if len(date) < 4 {
+		return 0, false
+	}
+	year, err := strconv.Atoi(date[:4])
+	if err != nil || year < 1 {
+		return 0, false
+	}
+	return year, true
+}
+
+// gunzipIfCompressed returns a reader of the uncompressed content of r,
+// whether or not it's gzipped
+func gunzipIfCompressed(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(buffered)
+	}
+	return buffered, nil
+}
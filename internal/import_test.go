@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseDateYear(t *testing.T) {
+	for date, expected := range map[string]int{"1999": 1999, "1999-03-00": 1999, "1981-10": 1981, "1981-10-26": 1981, " 1968 ": 1968} {
+		year, ok := releaseDateYear(date)
+		assert.True(t, ok, date)
+		assert.Equal(t, expected, year, date)
+	}
+	for _, date := range []string{"", "?", "19", "0000", "Unknown"} {
+		_, ok := releaseDateYear(date)
+		assert.False(t, ok, date)
+	}
+}
+
+func TestImportedReleaseDate(t *testing.T) {
+	tests := []struct {
+		date        string
+		releaseDate string
+		year        int
+	}{
+		{"1999-03-00", "1999-03", 1999},
+		{"1995-00-00", "1995", 1995},
+		{"1981-10-26", "1981-10-26", 1981},
+		// Only the year of dates that aren't real is kept
+		{"1999-13", "", 1999},
+		{"1999-02-30", "", 1999},
+	}
+	for _, test := range tests {
+		releaseDate, year, ok := importedReleaseDate(test.date)
+		assert.True(t, ok, test.date)
+		assert.Equal(t, test.releaseDate, releaseDate, test.date)
+		assert.Equal(t, test.year, year, test.date)
+	}
+
+	_, _, ok := importedReleaseDate("Unknown")
+	assert.False(t, ok)
+}
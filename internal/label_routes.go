@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+func setupLabelRoutes(e *echo.Echo, db *sql.DB, config Config) {
+	e.GET("/labels", func(c echo.Context) error {
+		// Read query parameters
+		pageStr := c.QueryParam("page")
+		limitStr := c.QueryParam("page_size")
+		searchQuery := c.QueryParam("q")
+
+		labels, pagination, err := getPaginatedLabels(db, pageStr, limitStr, config.DefaultPageSize, searchQuery, c.Request())
+		if err != nil {
+			e.Logger.Printf("Failed to get labels: %v", err)
+			return c.String(http.StatusInternalServerError, "Failed to load labels")
+		}
+
+		// Render appropriate template (full page or HTMX partial)
+		if isHtmxRequest(c) {
+			return c.Render(http.StatusOK, "labels_partial", map[string]interface{}{
+				"Labels":     labels,
+				"Pagination": pagination,
+			})
+		}
+
+		data := map[string]interface{}{
+			"Title":        "Labels",
+			"Labels":       labels,
+			"Query":        searchQuery,
+			"Pagination":   pagination,
+			"IncludeHTMX":  true,
+			"CurrentRoute": c.Request().URL.Path,
+		}
+
+		return c.Render(http.StatusOK, "labels", data)
+	})
+
+	e.GET("/labels/:id", func(c echo.Context) error {
+		labelId, err := paramId(c)
+		if err != nil {
+			return renderNotFound(c, "This label doesn't exist.")
+		}
+
+		label, err := getLabelDetail(db, labelId)
+		if errors.Is(err, errNotFound) {
+			return renderNotFound(c, "This label doesn't exist.")
+		}
+		if err != nil {
+			e.Logger.Printf("Failed to get label: %v", err)
+			return c.String(http.StatusInternalServerError, "Failed to load label")
+		}
+
+		data := map[string]interface{}{
+			"Title":        label.Name,
+			"Label":        label,
+			"CurrentRoute": c.Request().URL.Path,
+		}
+
+		return c.Render(http.StatusOK, "label", data)
+	})
+}
@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabelRoutes(t *testing.T) {
+	e := echo.New()
+	e.Renderer = &Template{TemplateDir: "./templates"}
+
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen')")
+	mustExec(t, db, "INSERT INTO labels (id, name) VALUES (1, 'EMI'), (2, 'Elektra')")
+	mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Jazz', 1978)")
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1)")
+	mustExec(t, db, "INSERT INTO release_labels (release_id, label_id, catno) VALUES (1, 1, 'EMA 788')")
+	SetupRoutes(e, db, DefaultConfig())
+
+	t.Run("GET /labels", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/labels?q=emi", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `<a href="/labels/1" class="text-rose-800 hover:underline">EMI</a>`)
+		assert.NotContains(t, rec.Body.String(), "Elektra")
+	})
+
+	t.Run("GET /labels/:id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/labels/1", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		body := rec.Body.String()
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, body, "EMA 788")
+		assert.Contains(t, body, `href="/releases/1"`)
+		assert.Contains(t, body, `href="/artists/1"`)
+
+		req = httptest.NewRequest(http.MethodGet, "/labels/2", nil)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Contains(t, rec.Body.String(), "No releases yet.")
+	})
+
+	t.Run("GET /labels/:id with unknown id", func(t *testing.T) {
+		for _, target := range []string{"/labels/999", "/labels/abc"} {
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusNotFound, rec.Code, target)
+			assert.Contains(t, rec.Body.String(), "This label doesn&#39;t exist.", target)
+		}
+	})
+
+	t.Run("GET /releases/:id shows labels", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/releases/1", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Contains(t, rec.Body.String(), `<a href="/labels/1" class="text-rose-800 hover:underline">EMI</a> &ndash; EMA 788`)
+	})
+
+	t.Run("GET /api/v1/labels", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/labels", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var response LabelsResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, []LabelSummary{
+			{Label: Label{Id: 2, Name: "Elektra"}, ReleaseCount: 0},
+			{Label: Label{Id: 1, Name: "EMI"}, ReleaseCount: 1},
+		}, response.Labels)
+		assert.Equal(t, 2, response.Pagination.TotalCount)
+	})
+
+	t.Run("GET /api/v1/labels/:id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/labels/1", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"id": 1,
+			"name": "EMI",
+			"releases": [{
+				"id": 1,
+				"name": "Jazz",
+				"year": 1978,
+				"barcode": "",
+				"master_id": 0,
+				"country": "",
+				"release_date": "",
+				"formats": [],
+				"credit": "Queen",
+				"credits": [{"id": 1, "name": "Queen", "role": "main", "join_phrase": ""}],
+				"artists": [{"id": 1, "name": "Queen"}],
+				"labels": [{"id": 1, "name": "EMI", "catno": "EMA 788"}],
+				"genres": [],
+				"tags": [],
+				"catno": "EMA 788"
+			}]
+		}`, rec.Body.String())
+
+		req = httptest.NewRequest(http.MethodGet, "/api/v1/labels/999", nil)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
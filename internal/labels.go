@@ -0,0 +1,274 @@
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+type Label struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// ReleaseLabel is a label a release came out on, with the release's catalog
+// number on that label
+type ReleaseLabel struct {
+	Label
+	Catno string `json:"catno"`
+}
+
+// ReleaseLabelInput credits a label on a release by name. Labels are matched
+// by name ignoring case and created when there's none.
+type ReleaseLabelInput struct {
+	Name  string `json:"name"`
+	Catno string `json:"catno"`
+}
+
+// barcodeLengths are the digit counts of EAN-8, UPC-A, EAN-13 and GTIN-14 barcodes
+var barcodeLengths = map[int]bool{8: true, 12: true, 13: true, 14: true}
+
+// normalizeBarcode removes the spaces and dashes barcodes are often printed with
+func normalizeBarcode(barcode string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, barcode)
+}
+
+// validBarcode reports whether a normalized barcode is a whole number of digits
+// of a known length
+func validBarcode(barcode string) bool {
+	if !barcodeLengths[len(barcode)] {
+		return false
+	}
+	for _, r := range barcode {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// catnoKey is the form catalog numbers are looked up by: lowercase letters and
+// digits only, so "CDP 7 46208 2" and "cdp-7462082" are the same number
+func catnoKey(catno string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, catno)
+}
+
+// ensureLabel returns the id of the label named name, creating it when there's none
+func ensureLabel(tx *sql.Tx, name string) (int, error) {
+	var labelId int
+	err := tx.QueryRow("SELECT id FROM labels WHERE name = ? COLLATE NOCASE ORDER BY id LIMIT 1", name).Scan(&labelId)
+	if err == nil {
+		return labelId, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	result, err := tx.Exec("INSERT INTO labels (name) VALUES (?)", name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// setReleaseLabels replaces the labels and catalog numbers of a release. The
+// release_labels triggers keep releases_fts in sync.
+func setReleaseLabels(tx *sql.Tx, releaseId int, labels []ReleaseLabelInput) error {
+	if _, err := tx.Exec("DELETE FROM release_labels WHERE release_id = ?", releaseId); err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for _, label := range labels {
+		key := strings.ToLower(label.Name) + "\n" + catnoKey(label.Catno)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		labelId, err := ensureLabel(tx, label.Name)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			"INSERT INTO release_labels (release_id, label_id, catno, catno_key) VALUES (?, ?, ?, ?)",
+			releaseId, labelId, label.Catno, catnoKey(label.Catno),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getLabel(db *sql.DB, labelId int) (Label, error) {
+	var label Label
+	err := db.QueryRow("SELECT id, name FROM labels WHERE id = ?", labelId).Scan(&label.Id, &label.Name)
+	if err == sql.ErrNoRows {
+		return Label{}, errNotFound
+	}
+	return label, err
+}
+
+type LabelSummary struct {
+	Label
+	ReleaseCount int `json:"release_count"`
+}
+
+// LabelRelease is a release on a label with its catalog number there
+type LabelRelease struct {
+	Release
+	Catno string `json:"catno"`
+}
+
+type LabelDetail struct {
+	Label
+	Releases []LabelRelease `json:"releases"`
+}
+
+// labelSearchFilter returns a WHERE clause matching labels whose name contains searchQuery
+func labelSearchFilter(searchQuery string) (string, []interface{}) {
+	searchQuery = strings.TrimSpace(searchQuery)
+	if searchQuery == "" {
+		return "", nil
+	}
+	return `WHERE labels.name LIKE ? ESCAPE '\'`, []interface{}{"%" + escapeLike(searchQuery) + "%"}
+}
+
+func getLabelsCount(db *sql.DB, searchQuery string) (int, error) {
+	where, args := labelSearchFilter(searchQuery)
+
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM labels "+where, args...).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func getLabels(db *sql.DB, limit int, offset int, searchQuery string) ([]LabelSummary, error) {
+	// Validate inputs
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid limit: %d", limit)
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("invalid offset: %d", offset)
+	}
+
+	where, args := labelSearchFilter(searchQuery)
+	args = append(args, limit, offset)
+
+	rows, err := db.Query(`
+		SELECT
+			labels.id,
+			labels.name,
+			(SELECT COUNT(DISTINCT release_id) FROM release_labels WHERE label_id = labels.id) AS release_count
+		FROM labels
+		`+where+`
+		ORDER BY labels.name COLLATE NOCASE, labels.id
+		LIMIT ?
+		OFFSET ?;
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	labels := []LabelSummary{}
+	for rows.Next() {
+		var label LabelSummary
+		if err := rows.Scan(&label.Id, &label.Name, &label.ReleaseCount); err != nil {
+			return nil, err
+		}
+		labels = append(labels, label)
+	}
+	return labels, rows.Err()
+}
+
+func getPaginatedLabels(
+	db *sql.DB,
+	pageStr string,
+	limitStr string,
+	defaultPageSize int,
+	searchQuery string,
+	request *http.Request,
+) ([]LabelSummary, Pagination, error) {
+	totalCount, err := getLabelsCount(db, searchQuery)
+	if err != nil {
+		return nil, Pagination{}, fmt.Errorf("failed to get labels count: %w", err)
+	}
+
+	pagination, err := getPagination(pageStr, limitStr, defaultPageSize, totalCount, request)
+	if err != nil {
+		return nil, Pagination{}, err
+	}
+
+	labels, err := getLabels(db, pagination.Limit, pagination.Offset, searchQuery)
+	return labels, pagination, err
+}
+
+// getLabelDetail loads a label and its releases, oldest first. A release
+// with several catalog numbers on the label is listed once for each.
+func getLabelDetail(db *sql.DB, labelId int) (LabelDetail, error) {
+	label, err := getLabel(db, labelId)
+	if err != nil {
+		return LabelDetail{}, err
+	}
+
+	rows, err := db.Query(`
+		SELECT releases.id, release_labels.catno
+		FROM release_labels
+		JOIN releases ON releases.id = release_labels.release_id
+		WHERE release_labels.label_id = ?
+		ORDER BY releases.year, releases.name, releases.id, release_labels.catno_key;
+	`, labelId)
+	if err != nil {
+		return LabelDetail{}, err
+	}
+	defer rows.Close()
+
+	var releaseIds []int
+	var catnos []string
+	for rows.Next() {
+		var id int
+		var catno string
+		if err := rows.Scan(&id, &catno); err != nil {
+			return LabelDetail{}, err
+		}
+		releaseIds = append(releaseIds, id)
+		catnos = append(catnos, catno)
+	}
+	if err := rows.Err(); err != nil {
+		return LabelDetail{}, err
+	}
+
+	releases, err := getReleasesByIds(db, releaseIds)
+	if err != nil {
+		return LabelDetail{}, err
+	}
+	byId := map[int]Release{}
+	for _, release := range releases {
+		byId[release.Id] = release
+	}
+
+	detail := LabelDetail{Label: label, Releases: []LabelRelease{}}
+	for i, id := range releaseIds {
+		if release, ok := byId[id]; ok {
+			detail.Releases = append(detail.Releases, LabelRelease{Release: release, Catno: catnos[i]})
+		}
+	}
+	return detail, nil
+}
@@ -0,0 +1,189 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatnoKey(t *testing.T) {
+	assert.Equal(t, "cdp7462082", catnoKey("CDP 7 46208 2"))
+	assert.Equal(t, "cdp7462082", catnoKey("cdp-7462082"))
+	assert.Equal(t, "emi3335", catnoKey(" EMI.3335 "))
+	assert.Equal(t, "", catnoKey("--"))
+}
+
+func TestBarcode(t *testing.T) {
+	assert.Equal(t, "077774620826", normalizeBarcode("0 77774-62082 6"))
+	assert.True(t, validBarcode("077774620826"))
+	assert.True(t, validBarcode("12345670"))
+	assert.False(t, validBarcode("1234567"))
+	assert.False(t, validBarcode("07777462082X"))
+}
+
+func TestReleaseLabels(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'Bowie')")
+	mustExec(t, db, "INSERT INTO labels (id, name) VALUES (1, 'EMI')")
+
+	var hotSpace Release
+
+	t.Run("Create With Labels And Barcode", func(t *testing.T) {
+		var err error
+		hotSpace, err = createRelease(db, ReleaseInput{
+			Name:      "Hot Space",
+			Year:      1982,
+			Barcode:   "0 77774-62082 6",
+			ArtistIds: []int{1},
+			Labels: []ReleaseLabelInput{
+				{Name: " emi ", Catno: "CDP 7 46208 2"},
+				{Name: "Elektra", Catno: "E1-60128"},
+				{Name: "EMI", Catno: "cdp-7462082"},
+				{},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "077774620826", hotSpace.Barcode)
+		// Labels are matched by name ignoring case and repeated catalog numbers are skipped
+		assert.Equal(t, []ReleaseLabel{
+			{Label: Label{Id: 1, Name: "EMI"}, Catno: "CDP 7 46208 2"},
+			{Label: Label{Id: 2, Name: "Elektra"}, Catno: "E1-60128"},
+		}, hotSpace.Labels)
+		assert.Equal(t, 2, countRows(t, db, "labels"))
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		_, err := createRelease(db, ReleaseInput{
+			Name:    "Heroes",
+			Year:    1977,
+			Barcode: "12345",
+			Labels:  []ReleaseLabelInput{{Catno: "PL 12522"}},
+		})
+		var validationErr *ValidationError
+		if assert.True(t, errors.As(err, &validationErr)) {
+			assert.Equal(t, map[string]string{
+				"barcode": "Barcode must be 8, 12, 13 or 14 digits",
+				"labels":  "Catalog number PL 12522 needs a label",
+			}, validationErr.Fields)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		heroes, err := createRelease(db, ReleaseInput{
+			Name:      "Heroes",
+			Year:      1977,
+			ArtistIds: []int{2},
+			Labels:    []ReleaseLabelInput{{Name: "RCA Victor", Catno: "PL 12522"}},
+		})
+		assert.NoError(t, err)
+
+		assert.Equal(t, []string{"Queen"}, searchCredits(t, db, "label:elektra"))
+		assert.Equal(t, []string{"Bowie"}, searchCredits(t, db, "victor"))
+		assert.Equal(t, []string{"Bowie"}, searchCredits(t, db, "-label:emi"))
+
+		// Lookups ignore spacing and punctuation
+		assert.Equal(t, []string{"Queen"}, searchCredits(t, db, "catno:cdp7462082"))
+		assert.Equal(t, []string{"Bowie"}, searchCredits(t, db, `catno:"pl-12522"`))
+		assert.Equal(t, []string{"Queen"}, searchCredits(t, db, "barcode:0-77774-62082-6"))
+		assert.Equal(t, []string{"Bowie"}, searchCredits(t, db, "-barcode:077774620826"))
+		assert.Empty(t, searchCredits(t, db, "catno:cdp746208"))
+		assert.Empty(t, searchCredits(t, db, "barcode:077774620826 artist:bowie"))
+
+		// Renaming a label reindexes its releases
+		mustExec(t, db, "UPDATE labels SET name = 'RCA' WHERE id = ?", heroes.Labels[0].Id)
+		assert.Empty(t, searchCredits(t, db, "label:victor"))
+		assert.Equal(t, []string{"Bowie"}, searchCredits(t, db, "label:rca"))
+	})
+
+	t.Run("Lookups Use Indexes", func(t *testing.T) {
+		for input, index := range map[string]string{
+			"barcode:077774620826": "releases_barcode",
+			"catno:cdp7462082":     "release_labels_catno_key",
+		} {
+			where, args := mustParseSearchQuery(t, input).filter()
+			rows, err := db.Query("EXPLAIN QUERY PLAN SELECT COUNT(*) FROM releases_fts "+where, args...)
+			if err != nil {
+				t.Fatalf("Failed to explain %q: %v", input, err)
+			}
+
+			var plan []string
+			for rows.Next() {
+				var id, parent, unused int
+				var detail string
+				if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+					t.Fatalf("Failed to scan plan: %v", err)
+				}
+				plan = append(plan, detail)
+			}
+			rows.Close()
+			assert.Contains(t, strings.Join(plan, "\n"), "INDEX "+index, input)
+		}
+	})
+
+	t.Run("Update Replaces Labels", func(t *testing.T) {
+		release, err := updateRelease(db, hotSpace.Id, ReleaseInput{
+			Name:      "Hot Space",
+			Year:      1982,
+			ArtistIds: []int{1},
+			Labels:    []ReleaseLabelInput{{Name: "Elektra", Catno: "E1-60128"}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "", release.Barcode)
+		assert.Equal(t, []ReleaseLabel{{Label: Label{Id: 2, Name: "Elektra"}, Catno: "E1-60128"}}, release.Labels)
+		assert.Empty(t, searchCredits(t, db, "label:emi"))
+		assert.Empty(t, searchCredits(t, db, "barcode:077774620826"))
+	})
+
+	t.Run("Label Detail", func(t *testing.T) {
+		mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (10, 'The Works', 1984)")
+		mustExec(t, db, "INSERT INTO release_labels (release_id, label_id, catno, catno_key) VALUES (10, 2, '60332-1', '603321'), (10, 2, '9 60332-2', '9603322')")
+
+		label, err := getLabelDetail(db, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, "Elektra", label.Name)
+		if assert.Len(t, label.Releases, 3) {
+			assert.Equal(t, "Hot Space", label.Releases[0].Name)
+			assert.Equal(t, "60332-1", label.Releases[1].Catno)
+			assert.Equal(t, "9 60332-2", label.Releases[2].Catno)
+		}
+
+		_, err = getLabelDetail(db, 999)
+		assert.ErrorIs(t, err, errNotFound)
+	})
+
+	t.Run("Deletes Remove Label Credits", func(t *testing.T) {
+		assert.NoError(t, deleteRelease(db, hotSpace.Id))
+		mustExec(t, db, "DELETE FROM labels WHERE id = 2")
+		assert.Equal(t, 1, countRows(t, db, "release_labels"))
+		assert.Equal(t, []string{"Bowie"}, searchCredits(t, db, "label:rca"))
+	})
+}
+
+func TestGetLabels(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO labels (id, name) VALUES (1, 'EMI'), (2, 'Elektra'), (3, 'Blue Note')")
+	mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Jazz', 1978), (2, 'Blue Train', 1958)")
+	mustExec(t, db, "INSERT INTO release_labels (release_id, label_id, catno) VALUES (1, 1, 'EMA 788'), (1, 2, '6E-166'), (2, 3, 'BLP 1577'), (2, 3, 'BST 81577')")
+
+	labels, err := getLabels(db, 10, 0, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []LabelSummary{
+		{Label: Label{Id: 3, Name: "Blue Note"}, ReleaseCount: 1},
+		{Label: Label{Id: 2, Name: "Elektra"}, ReleaseCount: 1},
+		{Label: Label{Id: 1, Name: "EMI"}, ReleaseCount: 1},
+	}, labels)
+
+	labels, err = getLabels(db, 10, 0, "e")
+	assert.NoError(t, err)
+	assert.Len(t, labels, 3)
+
+	labels, err = getLabels(db, 10, 0, "note")
+	assert.NoError(t, err)
+	assert.Equal(t, []LabelSummary{{Label: Label{Id: 3, Name: "Blue Note"}, ReleaseCount: 1}}, labels)
+
+	count, err := getLabelsCount(db, "el")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
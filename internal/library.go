@@ -0,0 +1,470 @@
+package internal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// librarySource is the source of the external IDs of releases and artists
+// found by scanning a music library
+const librarySource = "library"
+
+// libraryExtensions are the audio files a library scan reads
+var libraryExtensions = map[string]bool{".mp3": true, ".flac": true, ".m4a": true}
+
+// libraryNameSeparator separates the names in the artist columns of library_files
+const libraryNameSeparator = "\n"
+
+// ScanStats counts the files and releases of a library scan
+type ScanStats struct {
+	Read            int // New or changed files whose tags were read
+	Unchanged       int // Files unchanged since the last scan
+	Removed         int // Files gone since the last scan
+	Untagged        int // Files without an album tag or that couldn't be read
+	Releases        int // Releases inserted or updated
+	RemovedReleases int // Releases deleted because all their files are gone
+	Undated         int // Releases left out because none of their files has a date
+}
+
+func (s ScanStats) String() string {
+	return fmt.Sprintf(
+		"read %d files, %d unchanged, %d removed, %d without an album; updated %d releases, removed %d, skipped %d without a date",
+		s.Read, s.Unchanged, s.Removed, s.Untagged, s.Releases, s.RemovedReleases, s.Undated,
+	)
+}
+
+func (s ScanStats) total() int {
+	return s.Read + s.Unchanged + s.Removed
+}
+
+// scannedFile is a row of library_files
+type scannedFile struct {
+	path       string
+	size       int64
+	mtime      int64
+	releaseKey sql.NullString
+	// linked is whether the file's release was built since its tags were saved
+	linked bool
+}
+
+// ScanLibrary walks root for MP3, FLAC and M4A files and builds releases
+// from their album, album artist, artist and date tags. Files with the same
+// album and album artist are one release, or without an album artist the
+// files with the same album in one directory. A release is dated by the
+// earliest date of its files and credits its album artists, or otherwise
+// every artist of its files. Changes are written in transactions of batchSize
+// and progress is reported to progress, which may be nil.
+//
+// The path, size and modification time of every file are kept in
+// library_files. Rescans only read the files that changed since, update the
+// releases of changed and removed files, and delete releases whose files are
+// all gone. Releases and artists are matched to earlier scans through their
+// external IDs like imports, so rescans never duplicate them.
+func ScanLibrary(db *sql.DB, root string, batchSize int, progress io.Writer) (ScanStats, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return ScanStats{}, err
+	}
+	if info, err := os.Stat(root); err != nil {
+		return ScanStats{}, err
+	} else if !info.IsDir() {
+		return ScanStats{}, fmt.Errorf("%s is not a directory", root)
+	}
+
+	known, err := getScannedFiles(db, root)
+	if err != nil {
+		return ScanStats{}, err
+	}
+
+	var stats ScanStats
+	var changed []scannedFile
+	// The releases of changed and removed files are rebuilt at the end.
+	// Files are saved unlinked from their releases until then, so releases
+	// left unbuilt by an interrupted scan are rebuilt by the next one, along
+	// with releases whose files were all removed.
+	dirty, err := getUnbuiltReleaseKeys(db)
+	if err != nil {
+		return ScanStats{}, err
+	}
+	for _, file := range known {
+		if file.releaseKey.Valid && !file.linked {
+			dirty[file.releaseKey.String] = true
+		}
+	}
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !libraryExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		file := scannedFile{path: path, size: info.Size(), mtime: info.ModTime().UnixNano()}
+		previous, ok := known[path]
+		delete(known, path)
+		if ok && previous.size == file.size && previous.mtime == file.mtime {
+			stats.Unchanged++
+			return nil
+		}
+		if previous.releaseKey.Valid {
+			dirty[previous.releaseKey.String] = true
+		}
+		changed = append(changed, file)
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to walk %s: %w", root, err)
+	}
+
+	reporter := importProgress{w: progress}
+	err = importBatches(db, librarySource, batchSize, func(tx importTx) error {
+		if len(changed) == 0 {
+			return io.EOF
+		}
+		file := changed[0]
+		changed = changed[1:]
+		return tx.scanFile(file, dirty, &stats)
+	}, nil, func() {
+		reporter.report(stats)
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	removed := make([]scannedFile, 0, len(known))
+	for _, file := range known {
+		removed = append(removed, file)
+	}
+	err = importBatches(db, librarySource, batchSize, func(tx importTx) error {
+		if len(removed) == 0 {
+			return io.EOF
+		}
+		file := removed[0]
+		removed = removed[1:]
+
+		if err := tx.unlinkScannedRelease(file.path); err != nil {
+			return err
+		}
+		if _, err := tx.tx.Exec("DELETE FROM library_files WHERE path = ?", file.path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", file.path, err)
+		}
+		if file.releaseKey.Valid {
+			dirty[file.releaseKey.String] = true
+		}
+		stats.Removed++
+		return nil
+	}, nil, func() {})
+	if err != nil {
+		return stats, err
+	}
+
+	// Sorted so releases are created in the same order on every scan
+	keys := make([]string, 0, len(dirty))
+	for key := range dirty {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	err = importBatches(db, librarySource, batchSize, func(tx importTx) error {
+		if len(keys) == 0 {
+			return io.EOF
+		}
+		key := keys[0]
+		keys = keys[1:]
+		return tx.scanRelease(key, &stats)
+	}, nil, func() {})
+	return stats, err
+}
+
+// getScannedFiles returns the files under root found by earlier scans by path
+func getScannedFiles(db *sql.DB, root string) (map[string]scannedFile, error) {
+	prefix := root + string(filepath.Separator)
+	rows, err := db.Query(
+		"SELECT path, size, mtime, release_key, release_id IS NOT NULL FROM library_files WHERE substr(path, 1, length(?)) = ?",
+		prefix, prefix,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scanned files: %w", err)
+	}
+	defer rows.Close()
+
+	files := map[string]scannedFile{}
+	for rows.Next() {
+		var file scannedFile
+		if err := rows.Scan(&file.path, &file.size, &file.mtime, &file.releaseKey, &file.linked); err != nil {
+			return nil, err
+		}
+		files[file.path] = file
+	}
+	return files, rows.Err()
+}
+
+// getUnbuiltReleaseKeys returns the keys of the releases created by earlier
+// scans that have no files left, which an interrupted scan removed the files
+// of before deleting the release
+func getUnbuiltReleaseKeys(db *sql.DB) (map[string]bool, error) {
+	rows, err := db.Query(`
+		SELECT external_id
+		FROM external_ids
+		WHERE source = ?
+		  AND entity = ?
+		  AND NOT EXISTS (SELECT 1 FROM library_files WHERE release_key = external_ids.external_id)
+	`, librarySource, entityRelease)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get releases without files: %w", err)
+	}
+	defer rows.Close()
+
+	keys := map[string]bool{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys[key] = true
+	}
+	return keys, rows.Err()
+}
+
+// unlinkScannedRelease unlinks the files of the release of the file at path
+// before the file changes or is removed, so the release is rebuilt even when
+// the scan is interrupted before it gets to it
+func (t importTx) unlinkScannedRelease(path string) error {
+	_, err := t.tx.Exec(
+		"UPDATE library_files SET release_id = NULL WHERE release_key = (SELECT release_key FROM library_files WHERE path = ?)",
+		path,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to unlink release of %s: %w", path, err)
+	}
+	return nil
+}
+
+// scanFile reads the tags of a new or changed file and saves them
+func (t importTx) scanFile(file scannedFile, dirty map[string]bool, stats *ScanStats) error {
+	tags, err := readAudioFileTags(file.path)
+	if err != nil && !errors.Is(err, errNoAudioTags) {
+		return err
+	}
+	stats.Read++
+
+	// MP4 dates can have a time, like 1977-01-14T08:00:00Z
+	date, _, _ := strings.Cut(tags.Date, "T")
+	var year sql.NullInt64
+	releaseDate, value, ok := importedReleaseDate(date)
+	if ok {
+		year = sql.NullInt64{Int64: int64(value), Valid: true}
+	}
+	if err := t.unlinkScannedRelease(file.path); err != nil {
+		return err
+	}
+
+	// Untagged files are still saved, so they aren't read again until they change
+	if key, ok := libraryReleaseKey(file.path, tags); ok {
+		file.releaseKey = sql.NullString{String: key, Valid: true}
+		dirty[key] = true
+	} else {
+		stats.Untagged++
+	}
+
+	_, err = t.tx.Exec(`
+		INSERT INTO library_files (path, size, mtime, album, album_artists, artists, year, release_date, release_key)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (path) DO UPDATE SET
+			size = excluded.size, mtime = excluded.mtime, album = excluded.album,
+			album_artists = excluded.album_artists, artists = excluded.artists,
+			year = excluded.year, release_date = excluded.release_date,
+			release_key = excluded.release_key, release_id = NULL
+	`,
+		file.path, file.size, file.mtime, tags.Album,
+		strings.Join(tags.AlbumArtists, libraryNameSeparator), strings.Join(tags.Artists, libraryNameSeparator),
+		year, releaseDate, file.releaseKey,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %w", file.path, err)
+	}
+	return nil
+}
+
+// readAudioFileTags reads the tags of the audio file at path. Files that
+// aren't in a supported format return errNoAudioTags.
+func readAudioFileTags(path string) (audioTags, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return audioTags{}, err
+	}
+	defer file.Close()
+
+	tags, err := readAudioTags(file)
+	if err != nil {
+		// Damaged tags don't stop the scan, the file is counted as untagged
+		return audioTags{}, errNoAudioTags
+	}
+	return tags, nil
+}
+
+// libraryReleaseKey identifies the release of a file by its album and album
+// artists, or by its album and directory when it has no album artist
+func libraryReleaseKey(path string, tags audioTags) (string, bool) {
+	album := strings.TrimSpace(tags.Album)
+	if album == "" {
+		return "", false
+	}
+	if len(tags.AlbumArtists) > 0 {
+		return strings.ToLower(strings.Join(tags.AlbumArtists, libraryNameSeparator) + libraryNameSeparator + album), true
+	}
+	return strings.ToLower(filepath.Dir(path)) + libraryNameSeparator + strings.ToLower(album), true
+}
+
+// scanRelease creates or updates the release of the files with key, or
+// deletes it when there are none left
+func (t importTx) scanRelease(key string, stats *ScanStats) error {
+	rows, err := t.tx.Query(
+		"SELECT album, album_artists, artists, year, release_date FROM library_files WHERE release_key = ? ORDER BY path",
+		key,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get files of %q: %w", key, err)
+	}
+	defer rows.Close()
+
+	name, year, releaseDate, files := "", 0, "", 0
+	var albumArtists, artists []string
+	var fileArtists [][]string
+	for rows.Next() {
+		var album, albumArtistNames, artistNames, fileDate string
+		var fileYear sql.NullInt64
+		if err := rows.Scan(&album, &albumArtistNames, &artistNames, &fileYear, &fileDate); err != nil {
+			return err
+		}
+		files++
+		if name == "" {
+			name = album
+		}
+		// Of the files from the earliest year, the most precise date is
+		// kept, and the earliest of those
+		switch {
+		case !fileYear.Valid:
+		case year == 0 || int(fileYear.Int64) < year:
+			year, releaseDate = int(fileYear.Int64), fileDate
+		case int(fileYear.Int64) == year && (len(fileDate) > len(releaseDate) || len(fileDate) == len(releaseDate) && fileDate < releaseDate):
+			releaseDate = fileDate
+		}
+		if albumArtists == nil && albumArtistNames != "" {
+			albumArtists = strings.Split(albumArtistNames, libraryNameSeparator)
+		}
+		if artistNames != "" {
+			names := strings.Split(artistNames, libraryNameSeparator)
+			artists = append(artists, names...)
+			fileArtists = append(fileArtists, names)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	if files == 0 {
+		return t.removeScannedRelease(key, stats)
+	}
+	if year == 0 {
+		stats.Undated++
+		return nil
+	}
+
+	releaseId, err := t.upsertDatedRelease(key, name, year, releaseDate)
+	if err != nil {
+		return err
+	}
+
+	credits, err := t.scannedCredits(albumArtists, artists, fileArtists)
+	if err != nil {
+		return err
+	}
+	if err := setReleaseCredits(t.tx, releaseId, credits); err != nil {
+		return fmt.Errorf("failed to set artists of %s: %w", name, err)
+	}
+
+	if _, err := t.tx.Exec("UPDATE library_files SET release_id = ? WHERE release_key = ?", releaseId, key); err != nil {
+		return fmt.Errorf("failed to link files of %s: %w", name, err)
+	}
+	stats.Releases++
+	return nil
+}
+
+// scannedCredits credits the album artists of a release as its main
+// artists, and the other artists of files by an album artist as featuring,
+// like a guest on one track. Artists of files by someone else, like the
+// tracks of a compilation, aren't credited. Releases without album artists
+// credit the artists of all their files as main artists. Tags have no join
+// phrases, so credits are joined the default way.
+func (t importTx) scannedCredits(albumArtists []string, artists []string, fileArtists [][]string) ([]CreditInput, error) {
+	var credits []CreditInput
+	credit := func(artist string, role string) error {
+		artistId, err := t.ensureArtist(strings.ToLower(artist), artist)
+		if err != nil {
+			return err
+		}
+		credits = append(credits, CreditInput{ArtistId: artistId, Role: role})
+		return nil
+	}
+
+	if albumArtists == nil {
+		for _, artist := range artists {
+			if err := credit(artist, RoleMain); err != nil {
+				return nil, err
+			}
+		}
+		return credits, nil
+	}
+
+	isAlbumArtist := map[string]bool{}
+	for _, artist := range albumArtists {
+		isAlbumArtist[strings.ToLower(artist)] = true
+		if err := credit(artist, RoleMain); err != nil {
+			return nil, err
+		}
+	}
+	for _, names := range fileArtists {
+		byAlbumArtist := slices.ContainsFunc(names, func(name string) bool { return isAlbumArtist[strings.ToLower(name)] })
+		if !byAlbumArtist {
+			continue
+		}
+		for _, artist := range names {
+			if !isAlbumArtist[strings.ToLower(artist)] {
+				if err := credit(artist, RoleFeaturing); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return credits, nil
+}
+
+// removeScannedRelease deletes the release created for the files with key
+func (t importTx) removeScannedRelease(key string, stats *ScanStats) error {
+	releaseId, ok, err := t.localId(entityRelease, key)
+	if err != nil || !ok {
+		return err
+	}
+
+	if _, err := t.tx.Exec("DELETE FROM release_artists WHERE release_id = ?", releaseId); err != nil {
+		return err
+	}
+	if _, err := t.tx.Exec("DELETE FROM releases WHERE id = ?", releaseId); err != nil {
+		return fmt.Errorf("failed to remove release %d: %w", releaseId, err)
+	}
+	stats.RemovedReleases++
+	return nil
+}
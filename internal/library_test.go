@@ -0,0 +1,245 @@
+package internal
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// copyLibrary copies the test library to a temporary directory that tests can change
+func copyLibrary(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	err := filepath.WalkDir("testdata/library", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(root, strings.TrimPrefix(path, "testdata/library"))
+		if entry.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0o644)
+	})
+	if err != nil {
+		t.Fatalf("Failed to copy test library: %v", err)
+	}
+	return root
+}
+
+// writeFlacFile writes a FLAC file without audio that has the Vorbis comments
+// in comments, like "ARTIST=Queen"
+func writeFlacFile(t *testing.T, path string, comments ...string) {
+	t.Helper()
+	block := binary.LittleEndian.AppendUint32(nil, 0)
+	block = binary.LittleEndian.AppendUint32(block, uint32(len(comments)))
+	for _, comment := range comments {
+		block = binary.LittleEndian.AppendUint32(block, uint32(len(comment)))
+		block = append(block, comment...)
+	}
+
+	// An empty STREAMINFO block, then the comments as the last block
+	data := append([]byte("fLaC\x00\x00\x00\x22"), make([]byte, 0x22)...)
+	data = append(data, 0x84, byte(len(block)>>16), byte(len(block)>>8), byte(len(block)))
+	data = append(data, block...)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("Failed to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func mustScanLibrary(t *testing.T, db *sql.DB, root string) ScanStats {
+	t.Helper()
+	stats, err := ScanLibrary(db, root, DefaultImportBatchSize, nil)
+	if err != nil {
+		t.Fatalf("Failed to scan %s: %v", root, err)
+	}
+	return stats
+}
+
+// scannedRelease returns the release of the file at path
+func scannedRelease(t *testing.T, db *sql.DB, path string) Release {
+	t.Helper()
+	var releaseId int
+	if err := db.QueryRow("SELECT release_id FROM library_files WHERE path = ?", path).Scan(&releaseId); err != nil {
+		t.Fatalf("Failed to find release of %s: %v", path, err)
+	}
+	release, err := getRelease(db, releaseId)
+	if err != nil {
+		t.Fatalf("Failed to get release %d: %v", releaseId, err)
+	}
+	return release
+}
+
+func TestScanLibrary(t *testing.T) {
+	t.Run("Builds Releases From Tags", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		root := copyLibrary(t)
+
+		stats := mustScanLibrary(t, db, root)
+		assert.Equal(t, ScanStats{Read: 7, Untagged: 2, Releases: 3}, stats)
+		assert.Equal(t, 3, countRows(t, db, "releases"))
+		assert.Equal(t, 2, countRows(t, db, "artists"))
+		assert.Equal(t, 7, countRows(t, db, "library_files"))
+
+		// Files of a release in different formats are grouped by album and album artist
+		release := scannedRelease(t, db, filepath.Join(root, "Queen/A Night at the Opera/01 Death on Two Legs.mp3"))
+		assert.Equal(t, "A Night at the Opera", release.Name)
+		assert.Equal(t, 1975, release.Year)
+		// The MP3 is only dated 1975, the FLAC has the full date
+		assert.Equal(t, "1975-11-21", release.ReleaseDate)
+		assert.Equal(t, []string{"Queen"}, artistNames(release.Artists))
+		assert.Equal(t, release, scannedRelease(t, db, filepath.Join(root, "Queen/A Night at the Opera/02 Lazing on a Sunday Afternoon.flac")))
+
+		// Without an album artist, a release credits the artists of its files
+		release = scannedRelease(t, db, filepath.Join(root, "Queen/Hot Space/02 Staying Power.flac"))
+		assert.Equal(t, "Hot Space", release.Name)
+		assert.Equal(t, 1982, release.Year)
+		assert.Equal(t, []string{"Queen", "David Bowie"}, artistNames(release.Artists))
+
+		release = scannedRelease(t, db, filepath.Join(root, "David Bowie/Low/01 Speed of Life.m4a"))
+		assert.Equal(t, 1977, release.Year)
+		assert.Equal(t, "1977-01-14", release.ReleaseDate)
+		assert.Equal(t, []string{"David Bowie"}, artistNames(release.Artists))
+
+		releases, err := getReleases(db, 10, 0, mustParseSearchQuery(t, "artist:bowie"), SortYear, nil)
+		assert.NoError(t, err)
+		assert.Len(t, releases, 2)
+	})
+
+	t.Run("Credits Guests As Featuring", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		root := copyLibrary(t)
+		writeFlacFile(t, filepath.Join(root, "Queen/A Night at the Opera/03 Guest Spot.flac"),
+			"ALBUM=A Night at the Opera", "ARTIST=Queen", "ARTIST=David Bowie", "ALBUMARTIST=Queen", "DATE=1975")
+		writeFlacFile(t, filepath.Join(root, "Various/Hits/01 Track.flac"),
+			"ALBUM=Hits", "ARTIST=David Bowie", "ALBUMARTIST=Various Artists", "DATE=1990")
+		mustScanLibrary(t, db, root)
+
+		release := scannedRelease(t, db, filepath.Join(root, "Queen/A Night at the Opera/03 Guest Spot.flac"))
+		assert.Equal(t, "Queen feat. David Bowie", release.Credit)
+
+		// Artists of files without an album artist are all main artists
+		release = scannedRelease(t, db, filepath.Join(root, "Queen/Hot Space/02 Staying Power.flac"))
+		assert.Equal(t, "Queen, David Bowie", release.Credit)
+
+		// Compilation tracks aren't by the album artist, so they aren't credited
+		release = scannedRelease(t, db, filepath.Join(root, "Various/Hits/01 Track.flac"))
+		assert.Equal(t, "Various Artists", release.Credit)
+	})
+
+	t.Run("Rescans Only Read Changed Files", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		root := copyLibrary(t)
+		mustScanLibrary(t, db, root)
+
+		stats := mustScanLibrary(t, db, root)
+		assert.Equal(t, ScanStats{Unchanged: 7}, stats)
+		assert.Equal(t, 3, countRows(t, db, "releases"))
+
+		// Touched files are read again and their release updated in place
+		path := filepath.Join(root, "Queen/Hot Space/01 Under Pressure.mp3")
+		before := scannedRelease(t, db, path)
+		later := time.Now().Add(time.Hour)
+		if err := os.Chtimes(path, later, later); err != nil {
+			t.Fatalf("Failed to touch %s: %v", path, err)
+		}
+
+		stats = mustScanLibrary(t, db, root)
+		assert.Equal(t, ScanStats{Read: 1, Unchanged: 6, Releases: 1}, stats)
+		assert.Equal(t, before, scannedRelease(t, db, path))
+		assert.Equal(t, 3, countRows(t, db, "releases"))
+	})
+
+	t.Run("Rescans Update Releases Of Changed Files", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		root := copyLibrary(t)
+		mustScanLibrary(t, db, root)
+
+		// Retag the FLAC of Hot Space with an earlier date
+		path := filepath.Join(root, "Queen/Hot Space/02 Staying Power.flac")
+		data := strings.Replace(mustReadFile(t, path), "DATE=1982", "DATE=1981", 1)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+		// Remove every file of Low
+		if err := os.Remove(filepath.Join(root, "David Bowie/Low/01 Speed of Life.m4a")); err != nil {
+			t.Fatalf("Failed to remove file: %v", err)
+		}
+
+		stats := mustScanLibrary(t, db, root)
+		assert.Equal(t, ScanStats{Read: 1, Unchanged: 5, Removed: 1, Releases: 1, RemovedReleases: 1}, stats)
+		release := scannedRelease(t, db, path)
+		assert.Equal(t, 1981, release.Year)
+		// The date of the other file is from a later year, so it no longer applies
+		assert.Equal(t, "1981", release.ReleaseDate)
+		assert.Equal(t, 2, countRows(t, db, "releases"))
+		assert.Equal(t, 6, countRows(t, db, "library_files"))
+	})
+
+	t.Run("Rescans Finish Interrupted Scans", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		root := copyLibrary(t)
+		mustScanLibrary(t, db, root)
+
+		path := filepath.Join(root, "Queen/Hot Space/02 Staying Power.flac")
+		data := strings.Replace(mustReadFile(t, path), "DATE=1982", "DATE=1981", 1)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+		if err := os.Remove(filepath.Join(root, "David Bowie/Low/01 Speed of Life.m4a")); err != nil {
+			t.Fatalf("Failed to remove file: %v", err)
+		}
+
+		// The scan is interrupted after the files are saved, before their
+		// releases are rebuilt
+		mustExec(t, db, "CREATE TRIGGER interrupt_update BEFORE UPDATE ON releases BEGIN SELECT RAISE(ABORT, 'interrupted'); END")
+		mustExec(t, db, "CREATE TRIGGER interrupt_delete BEFORE DELETE ON releases BEGIN SELECT RAISE(ABORT, 'interrupted'); END")
+		_, err := ScanLibrary(db, root, DefaultImportBatchSize, nil)
+		assert.ErrorContains(t, err, "interrupted")
+		mustExec(t, db, "DROP TRIGGER interrupt_update")
+		mustExec(t, db, "DROP TRIGGER interrupt_delete")
+
+		// Nothing changed on disk since, but the rescan rebuilds both releases
+		stats := mustScanLibrary(t, db, root)
+		assert.Equal(t, ScanStats{Unchanged: 6, Releases: 1, RemovedReleases: 1}, stats)
+		assert.Equal(t, 1981, scannedRelease(t, db, path).Year)
+		assert.Equal(t, 2, countRows(t, db, "releases"))
+
+		stats = mustScanLibrary(t, db, root)
+		assert.Equal(t, ScanStats{Unchanged: 6}, stats)
+	})
+
+	t.Run("Deleted Releases Are Unlinked", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		root := copyLibrary(t)
+		mustScanLibrary(t, db, root)
+
+		path := filepath.Join(root, "David Bowie/Low/01 Speed of Life.m4a")
+		assert.NoError(t, deleteRelease(db, scannedRelease(t, db, path).Id))
+
+		var releaseId sql.NullInt64
+		assert.NoError(t, db.QueryRow("SELECT release_id FROM library_files WHERE path = ?", path).Scan(&releaseId))
+		assert.False(t, releaseId.Valid)
+	})
+
+	t.Run("Missing Directory", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+
+		_, err := ScanLibrary(db, filepath.Join(t.TempDir(), "missing"), DefaultImportBatchSize, nil)
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+}
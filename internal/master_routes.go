@@ -0,0 +1,35 @@
+package internal
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+func setupMasterRoutes(e *echo.Echo, db *sql.DB) {
+	e.GET("/masters/:id", func(c echo.Context) error {
+		masterId, err := paramId(c)
+		if err != nil {
+			return renderNotFound(c, "This master doesn't exist.")
+		}
+
+		master, err := getMasterDetail(db, masterId)
+		if errors.Is(err, errNotFound) {
+			return renderNotFound(c, "This master doesn't exist.")
+		}
+		if err != nil {
+			e.Logger.Printf("Failed to get master: %v", err)
+			return c.String(http.StatusInternalServerError, "Failed to load master")
+		}
+
+		data := map[string]interface{}{
+			"Title":        master.Name,
+			"Master":       master,
+			"CurrentRoute": c.Request().URL.Path,
+		}
+
+		return c.Render(http.StatusOK, "master", data)
+	})
+}
@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMasterRoutes(t *testing.T) {
+	e := echo.New()
+	e.Renderer = &Template{TemplateDir: "./templates"}
+
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen')")
+	mustExec(t, db, "INSERT INTO masters (id, name) VALUES (1, 'Jazz'), (2, 'Innuendo')")
+	mustExec(t, db, `INSERT INTO releases (id, name, year, master_id, country) VALUES
+		(1, 'Jazz', 1978, 1, 'UK'),
+		(2, 'Jazz (2011 Remaster)', 2011, 1, 'Europe')`)
+	mustExec(t, db, "INSERT INTO release_formats (release_id, name, quantity, descriptors) VALUES (1, 'vinyl', 1, 'LP'), (2, 'cd', 2, '')")
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1), (2, 1)")
+	SetupRoutes(e, db, DefaultConfig())
+
+	t.Run("GET /masters/:id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/masters/1", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		body := rec.Body.String()
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, body, `href="/releases/1"`)
+		assert.Contains(t, body, `href="/releases/2"`)
+		assert.Contains(t, body, "2 × CD")
+		assert.Contains(t, body, `href="/artists/1"`)
+
+		req = httptest.NewRequest(http.MethodGet, "/masters/2", nil)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Contains(t, rec.Body.String(), "No versions yet.")
+	})
+
+	t.Run("GET /masters/:id with unknown id", func(t *testing.T) {
+		for _, target := range []string{"/masters/999", "/masters/abc"} {
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusNotFound, rec.Code, target)
+			assert.Contains(t, rec.Body.String(), "This master doesn&#39;t exist.", target)
+		}
+	})
+
+	t.Run("GET /api/v1/masters/:id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/masters/1", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var master MasterDetail
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &master))
+		assert.Equal(t, "Jazz", master.Name)
+		assert.Equal(t, 1978, master.Year)
+		assert.Equal(t, []int{1, 2}, releaseIds(master.Versions))
+
+		req = httptest.NewRequest(http.MethodGet, "/api/v1/masters/999", nil)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("GET /releases/:id lists other versions", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/releases/2", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		body := rec.Body.String()
+		assert.Contains(t, body, `<a href="/masters/1" class="text-rose-800 hover:underline">Jazz</a>`)
+		assert.Contains(t, body, "Other versions")
+		assert.Contains(t, body, `href="/releases/1"`)
+	})
+
+	t.Run("GET /releases collapsed to masters", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/releases?collapse=masters", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		body := rec.Body.String()
+		assert.Contains(t, body, `<a href="/masters/1" class="ml-1 text-xs text-gray-500 hover:underline">2 versions</a>`)
+		assert.NotContains(t, body, `href="/releases/2"`)
+		assert.Contains(t, body, "Show every version")
+		assert.Contains(t, body, `<input type="hidden" name="collapse" value="masters">`)
+	})
+
+	t.Run("GET /api/v1/releases collapsed to masters", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/releases?collapse=masters", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		var response ReleasesResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		if assert.Len(t, response.Releases, 1) {
+			assert.Equal(t, 2, response.Releases[0].VersionCount)
+		}
+		assert.Equal(t, []ArtistFacet{{Name: "Queen", Count: 1}}, response.Facets.Artists)
+
+		// Uncollapsed releases don't have a version count
+		req = httptest.NewRequest(http.MethodGet, "/api/v1/releases", nil)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.NotContains(t, rec.Body.String(), "version_count")
+	})
+
+	t.Run("PUT /releases/:id with a new master", func(t *testing.T) {
+		form := url.Values{
+			"name":       {"Jazz"},
+			"year":       {"1978"},
+			"artist_ids": {"1"},
+			"master_id":  {""},
+			"new_master": {"Jazz (all versions)"},
+			"country":    {"US"},
+		}
+		req := httptest.NewRequest(http.MethodPut, "/releases/1", strings.NewReader(form.Encode()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		req.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, "/releases/1", rec.Header().Get("HX-Redirect"))
+
+		release, err := getRelease(db, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "US", release.Country)
+		master, err := getMaster(db, release.MasterId)
+		assert.NoError(t, err)
+		assert.Equal(t, "Jazz (all versions)", master.Name)
+
+		req = httptest.NewRequest(http.MethodGet, "/releases/1/edit", nil)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Contains(t, rec.Body.String(), `selected>Jazz (all versions)</option>`)
+		assert.Contains(t, rec.Body.String(), `value="US"`)
+	})
+}
@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"database/sql"
+)
+
+// Master groups the versions of a release, like an album's original pressing,
+// its remasters and its pressings in other countries
+type Master struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// MasterDetail is a master with its versions, oldest first
+type MasterDetail struct {
+	Master
+	// Year is the year of the oldest version, 0 when there are no versions
+	Year     int       `json:"year"`
+	Versions []Release `json:"versions"`
+}
+
+// Original is the oldest version of the master, whose credits are shown as
+// the master's
+func (m MasterDetail) Original() Release {
+	if len(m.Versions) == 0 {
+		return Release{}
+	}
+	return m.Versions[0]
+}
+
+// maxVersionDetailLength keeps countries short enough to list
+const maxVersionDetailLength = 50
+
+func getMaster(db *sql.DB, masterId int) (Master, error) {
+	var master Master
+	err := db.QueryRow("SELECT id, name FROM masters WHERE id = ?", masterId).Scan(&master.Id, &master.Name)
+	if err == sql.ErrNoRows {
+		return Master{}, errNotFound
+	}
+	return master, err
+}
+
+// getAllMasters returns every master by name, for the release form
+func getAllMasters(db *sql.DB) ([]Master, error) {
+	rows, err := db.Query("SELECT id, name FROM masters ORDER BY name COLLATE NOCASE, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	masters := []Master{}
+	for rows.Next() {
+		var master Master
+		if err := rows.Scan(&master.Id, &master.Name); err != nil {
+			return nil, err
+		}
+		masters = append(masters, master)
+	}
+	return masters, rows.Err()
+}
+
+// createMaster adds a master for a release that's about to become its first version
+func createMaster(tx *sql.Tx, name string) (int, error) {
+	result, err := tx.Exec("INSERT INTO masters (name) VALUES (?)", name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// getMasterVersionIds returns the ids of the versions of a master, oldest
+// first. With exceptId the versions other than that release are returned.
+func getMasterVersionIds(db *sql.DB, masterId int, exceptId int) ([]int, error) {
+	rows, err := db.Query(`
+		SELECT id
+		FROM releases
+		WHERE master_id = ?
+		  AND id != ?
+		ORDER BY year, release_date, country, name, id;
+	`, masterId, exceptId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versionIds []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		versionIds = append(versionIds, id)
+	}
+	return versionIds, rows.Err()
+}
+
+// getMasterDetail loads a master and all of its versions
+func getMasterDetail(db *sql.DB, masterId int) (MasterDetail, error) {
+	master, err := getMaster(db, masterId)
+	if err != nil {
+		return MasterDetail{}, err
+	}
+
+	versionIds, err := getMasterVersionIds(db, masterId, 0)
+	if err != nil {
+		return MasterDetail{}, err
+	}
+	versions, err := getReleasesByIds(db, versionIds)
+	if err != nil {
+		return MasterDetail{}, err
+	}
+
+	detail := MasterDetail{Master: master, Versions: versions}
+	if len(versions) > 0 {
+		detail.Year = versions[0].Year
+	}
+	return detail, nil
+}
@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMasters(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen')")
+
+	var original Release
+
+	t.Run("Create With New Master", func(t *testing.T) {
+		var err error
+		original, err = createRelease(db, ReleaseInput{Name: "A Night at the Opera", Year: 1975, Country: " UK ", Formats: []Format{{Name: FormatVinyl, Descriptors: []string{"LP"}}}, NewMaster: "A Night at the Opera", ArtistIds: []int{1}})
+		assert.NoError(t, err)
+		assert.NotZero(t, original.MasterId)
+		assert.Equal(t, "UK", original.Country)
+		assert.Equal(t, "Vinyl, LP", original.FormatsString())
+	})
+
+	t.Run("Versions", func(t *testing.T) {
+		remaster, err := createRelease(db, ReleaseInput{Name: "A Night at the Opera (2011 Remaster)", Year: 2011, Country: "Europe", Formats: []Format{{Name: FormatCD, Quantity: 2}}, MasterId: original.MasterId, ArtistIds: []int{1}})
+		assert.NoError(t, err)
+		japan, err := createRelease(db, ReleaseInput{Name: "オペラ座の夜", Year: 1975, Country: "Japan", ReleaseDate: "1975-12", MasterId: original.MasterId, ArtistIds: []int{1}})
+		assert.NoError(t, err)
+
+		master, err := getMasterDetail(db, original.MasterId)
+		assert.NoError(t, err)
+		assert.Equal(t, "A Night at the Opera", master.Name)
+		assert.Equal(t, 1975, master.Year)
+		assert.Equal(t, "Queen", master.Original().Credit)
+		// Oldest first, with versions known only by year before dated ones
+		assert.Equal(t, []int{original.Id, japan.Id, remaster.Id}, releaseIds(master.Versions))
+		assert.Equal(t, "December 1975", master.Versions[1].Released())
+
+		detail, err := getReleaseDetail(db, remaster.Id)
+		assert.NoError(t, err)
+		if assert.NotNil(t, detail.Master) {
+			assert.Equal(t, original.MasterId, detail.Master.Id)
+		}
+		assert.Equal(t, []int{original.Id, japan.Id}, releaseIds(detail.Versions))
+		// Versions aren't repeated as releases by the same artists
+		assert.Empty(t, detail.RelatedReleases)
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		_, err := createRelease(db, ReleaseInput{Name: "Jazz", Year: 1978, MasterId: 99})
+		var validationErr *ValidationError
+		if assert.True(t, errors.As(err, &validationErr)) {
+			assert.Equal(t, map[string]string{"master_id": "Master 99 does not exist"}, validationErr.Fields)
+		}
+
+		_, err = createRelease(db, ReleaseInput{Name: "Jazz", Year: 1978, MasterId: original.MasterId, NewMaster: "Jazz"})
+		if assert.True(t, errors.As(err, &validationErr)) {
+			assert.Equal(t, "Pick a master or name a new one, not both", validationErr.Fields["master_id"])
+		}
+
+		_, err = createRelease(db, ReleaseInput{Name: "Jazz", Year: 1978, Country: strings.Repeat("x", 51)})
+		if assert.True(t, errors.As(err, &validationErr)) {
+			assert.Equal(t, "Country can be at most 50 characters", validationErr.Fields["country"])
+		}
+	})
+
+	t.Run("Deleting A Master Keeps Its Versions", func(t *testing.T) {
+		mustExec(t, db, "DELETE FROM masters WHERE id = ?", original.MasterId)
+
+		release, err := getRelease(db, original.Id)
+		assert.NoError(t, err)
+		assert.Zero(t, release.MasterId)
+
+		_, err = getMasterDetail(db, original.MasterId)
+		assert.ErrorIs(t, err, errNotFound)
+	})
+}
+
+func TestCollapseMasters(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'Bowie')")
+	mustExec(t, db, "INSERT INTO masters (id, name) VALUES (1, 'Jazz')")
+	mustExec(t, db, `INSERT INTO releases (id, name, year, master_id) VALUES
+		(1, 'Jazz', 1978, 1),
+		(2, 'Jazz (Remastered)', 2011, 1),
+		(3, 'Jazz', 1978, 1),
+		(4, 'Heroes', 1977, NULL),
+		(5, 'Low', 1977, NULL)`)
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1), (2, 1), (3, 1), (4, 2), (5, 2)")
+
+	search := func(q string, sort string) []string {
+		t.Helper()
+		query := mustParseSearchQuery(t, q)
+		query.CollapseMasters = true
+		releases, err := getReleases(db, 10, 0, query, sort, nil)
+		assert.NoError(t, err)
+
+		count, err := getReleasesCount(db, query)
+		assert.NoError(t, err)
+		assert.Equal(t, len(releases), count, "count for %q", q)
+
+		names := []string{}
+		for _, release := range releases {
+			names = append(names, release["release_name"].(string))
+		}
+		return names
+	}
+
+	// Each master is listed once, as its first version in sort order
+	assert.Equal(t, []string{"Heroes", "Low", "Jazz"}, search("", SortYear))
+	assert.Equal(t, []string{"Jazz (Remastered)", "Low", "Heroes"}, search("", SortYearDesc))
+	assert.Equal(t, []string{"Jazz (Remastered)"}, search("remastered", ""))
+	assert.Equal(t, []string{"Heroes", "Low"}, search("artist:bowie", SortYear))
+
+	query := SearchQuery{CollapseMasters: true}
+	releases, err := getReleases(db, 10, 0, query, SortYear, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, releases, 3) {
+		assert.Equal(t, 1, releases[0]["version_count"])
+		assert.Equal(t, 0, releases[0]["master_id"])
+		assert.Equal(t, 3, releases[2]["version_count"])
+		assert.Equal(t, 1, releases[2]["master_id"])
+	}
+
+	// Facets count masters like the results list them
+	facets, err := getReleaseFacets(db, query)
+	assert.NoError(t, err)
+	assert.Equal(t, []DecadeFacet{{Decade: 1970, Count: 3}, {Decade: 2010, Count: 1}}, facets.Decades)
+	assert.Equal(t, []ArtistFacet{{Name: "Bowie", Count: 2}, {Name: "Queen", Count: 1}}, facets.Artists)
+
+	// Without collapsing every version is listed and counted
+	releases, err = getReleases(db, 10, 0, SearchQuery{}, SortYear, nil)
+	assert.NoError(t, err)
+	assert.Len(t, releases, 5)
+
+	facets, err = getReleaseFacets(db, SearchQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, []DecadeFacet{{Decade: 1970, Count: 4}, {Decade: 2010, Count: 1}}, facets.Decades)
+	assert.Equal(t, []ArtistFacet{{Name: "Queen", Count: 3}, {Name: "Bowie", Count: 2}}, facets.Artists)
+}
+
+func releaseIds(releases []Release) []int {
+	ids := []int{}
+	for _, release := range releases {
+		ids = append(ids, release.Id)
+	}
+	return ids
+}
@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// musicBrainzSource is the source of external IDs imported from MusicBrainz
+const musicBrainzSource = "musicbrainz"
+
+// mbidPattern matches MusicBrainz identifiers, which are lowercase UUIDs
+var mbidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// musicBrainzRecord is a line of the artist or release-group JSON dumps.
+// Artists have a name, release groups a title and an artist credit.
+type musicBrainzRecord struct {
+	Id               string              `json:"id"`
+	Name             string              `json:"name"`
+	Title            string              `json:"title"`
+	FirstReleaseDate string              `json:"first-release-date"`
+	ArtistCredit     []musicBrainzCredit `json:"artist-credit"`
+}
+
+// musicBrainzCredit is one artist of an artist credit like "Queen & David Bowie"
+type musicBrainzCredit struct {
+	Name       string `json:"name"`
+	JoinPhrase string `json:"joinphrase"`
+	Artist     struct {
+		Id   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"artist"`
+}
+
+func (r musicBrainzRecord) isReleaseGroup() bool {
+	return r.Title != "" || r.ArtistCredit != nil
+}
+
+// ImportMusicBrainz reads a MusicBrainz artist or release-group JSON dump,
+// gzipped or plain, with one record per line, and upserts its records in
+// transactions of batchSize. Release groups are credited to every artist in
+// their artist credit, with its join phrases, and the artists are created
+// when they haven't been imported yet. Release groups without a first release date are skipped. Progress is
+// reported to progress, which may be nil.
+//
+// checkpoint identifies the dump, for example by its path, size and
+// modification time. The number of lines committed is saved with each batch,
+// so importing a dump with the same checkpoint after an interruption resumes
+// after them. It's cleared once the whole dump is imported. An empty
+// checkpoint always imports the whole dump.
+//
+// Like ImportDiscogs, the releases_fts sync triggers stay active unless
+// paused with PauseFtsSync.
+func ImportMusicBrainz(db *sql.DB, r io.Reader, checkpoint string, batchSize int, progress io.Writer) (ImportStats, error) {
+	r, err := gunzipIfCompressed(r)
+	if err != nil {
+		return ImportStats{}, fmt.Errorf("failed to read gzip header: %w", err)
+	}
+	lines := bufio.NewReader(r)
+
+	line := 0
+	if checkpoint != "" {
+		resumeAt, err := getImportCheckpoint(db, musicBrainzSource, checkpoint)
+		if err != nil {
+			return ImportStats{}, err
+		}
+		for ; line < resumeAt; line++ {
+			if data, err := lines.ReadBytes('\n'); err != nil && !(errors.Is(err, io.EOF) && len(data) > 0) {
+				return ImportStats{}, fmt.Errorf("dump ends before the checkpoint at line %d: %w", resumeAt, err)
+			}
+		}
+		if line > 0 && progress != nil {
+			fmt.Fprintf(progress, "Resuming after line %d\n", line)
+		}
+	}
+
+	var stats ImportStats
+	reporter := importProgress{w: progress}
+	err = importBatches(db, musicBrainzSource, batchSize, func(tx importTx) error {
+		data, err := lines.ReadBytes('\n')
+		if errors.Is(err, io.EOF) && len(data) == 0 {
+			return io.EOF
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read line %d: %w", line+1, err)
+		}
+		line++
+
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			return nil
+		}
+
+		var record musicBrainzRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if record.isReleaseGroup() {
+			return importMusicBrainzReleaseGroup(tx, record, &stats)
+		}
+		return importMusicBrainzArtist(tx, record, &stats)
+	}, func(tx importTx) error {
+		if checkpoint == "" {
+			return nil
+		}
+		return tx.setCheckpoint(checkpoint, line)
+	}, func() {
+		reporter.report(stats)
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	if checkpoint != "" {
+		return stats, clearImportCheckpoint(db, musicBrainzSource, checkpoint)
+	}
+	return stats, nil
+}
+
+func importMusicBrainzArtist(tx importTx, artist musicBrainzRecord, stats *ImportStats) error {
+	name := strings.TrimSpace(artist.Name)
+	if !mbidPattern.MatchString(artist.Id) || name == "" {
+		stats.Skipped++
+		return nil
+	}
+
+	if _, err := tx.upsertArtist(artist.Id, name); err != nil {
+		return err
+	}
+	stats.Artists++
+	return nil
+}
+
+func importMusicBrainzReleaseGroup(tx importTx, releaseGroup musicBrainzRecord, stats *ImportStats) error {
+	name := strings.TrimSpace(releaseGroup.Title)
+	releaseDate, year, ok := importedReleaseDate(releaseGroup.FirstReleaseDate)
+	if !mbidPattern.MatchString(releaseGroup.Id) || name == "" || !ok {
+		stats.Skipped++
+		return nil
+	}
+
+	releaseId, err := tx.upsertDatedRelease(releaseGroup.Id, name, year, releaseDate)
+	if err != nil {
+		return err
+	}
+
+	// Artist credits name each artist as credited, which can differ from
+	// their own name, so new artists are created with their own name
+	var credits []CreditInput
+	for _, credit := range releaseGroup.ArtistCredit {
+		name := strings.TrimSpace(credit.Artist.Name)
+		if name == "" {
+			name = strings.TrimSpace(credit.Name)
+		}
+		if !mbidPattern.MatchString(credit.Artist.Id) || name == "" {
+			continue
+		}
+
+		artistId, err := tx.ensureArtist(credit.Artist.Id, name)
+		if err != nil {
+			return err
+		}
+		credits = append(credits, CreditInput{ArtistId: artistId, Role: RoleMain, JoinPhrase: strings.TrimSpace(credit.JoinPhrase)})
+	}
+	if err := setReleaseCredits(tx.tx, releaseId, credits); err != nil {
+		return fmt.Errorf("failed to set artists of release group %s: %w", releaseGroup.Id, err)
+	}
+
+	stats.Releases++
+	return nil
+}
@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustImportMusicBrainz(t *testing.T, db *sql.DB, path string) ImportStats {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	stats, err := ImportMusicBrainz(db, file, path, DefaultImportBatchSize, nil)
+	if err != nil {
+		t.Fatalf("Failed to import %s: %v", path, err)
+	}
+	return stats
+}
+
+func mustReadFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestImportMusicBrainz(t *testing.T) {
+	t.Run("Artists And Release Groups", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+
+		stats := mustImportMusicBrainz(t, db, "testdata/musicbrainz/artist.jsonl")
+		assert.Equal(t, ImportStats{Artists: 2, Skipped: 1}, stats)
+
+		stats = mustImportMusicBrainz(t, db, "testdata/musicbrainz/release-group.jsonl")
+		assert.Equal(t, ImportStats{Releases: 4, Skipped: 1}, stats)
+
+		assert.Equal(t, 3, countRows(t, db, "artists"))
+		assert.Equal(t, 4, countRows(t, db, "releases"))
+
+		release := importedRelease(t, db, musicBrainzSource, "2b7e4c11-8d2a-4f6b-a0c3-5e9d1f2a8b02")
+		assert.Equal(t, "Under Pressure", release.Name)
+		assert.Equal(t, 1981, release.Year)
+		assert.Equal(t, []string{"Queen", "David Bowie"}, artistNames(release.Artists))
+		assert.Equal(t, "Queen & David Bowie", release.Credit)
+		assert.Equal(t, "1981-10-26", release.ReleaseDate)
+
+		// Artists are linked under their own name, not the name they're credited as
+		release = importedRelease(t, db, musicBrainzSource, "3c9a5d22-1e4b-4a7c-b1d4-6f0e2a3b9c03")
+		assert.Equal(t, 1977, release.Year)
+		assert.Equal(t, "1977-01", release.ReleaseDate)
+		assert.Equal(t, []string{"David Bowie"}, artistNames(release.Artists))
+
+		// Artists missing from the artist dump are created from the credits
+		release = importedRelease(t, db, musicBrainzSource, "5e1c7f44-3a6d-4c9e-93f6-8b2a4c5d1e05")
+		assert.Equal(t, []string{"David Bowie", "Mick Jagger"}, artistNames(release.Artists))
+
+		releases, err := getReleases(db, 10, 0, mustParseSearchQuery(t, "artist:jagger"), SortYear, nil)
+		assert.NoError(t, err)
+		assert.Len(t, releases, 1)
+	})
+
+	t.Run("Reimport Updates Rows", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		mustImportMusicBrainz(t, db, "testdata/musicbrainz/artist.jsonl")
+		mustImportMusicBrainz(t, db, "testdata/musicbrainz/release-group.jsonl")
+		mustImportMusicBrainz(t, db, "testdata/musicbrainz/artist.jsonl")
+		mustImportMusicBrainz(t, db, "testdata/musicbrainz/release-group.jsonl")
+
+		assert.Equal(t, 3, countRows(t, db, "artists"))
+		assert.Equal(t, 4, countRows(t, db, "releases"))
+		assert.Equal(t, 6, countRows(t, db, "release_artists"))
+		assert.Equal(t, 0, countRows(t, db, "import_checkpoints"))
+	})
+
+	t.Run("Resumes After An Interruption", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		dump := mustReadFile(t, "testdata/musicbrainz/release-group.jsonl")
+		lines := strings.SplitAfter(dump, "\n")
+
+		// The connection drops part way through the third line
+		interrupted := io.MultiReader(
+			strings.NewReader(lines[0]+lines[1]+lines[2][:40]),
+			iotest.ErrReader(errors.New("connection reset")),
+		)
+		stats, err := ImportMusicBrainz(db, interrupted, "release-group", 1, nil)
+		assert.ErrorContains(t, err, "failed to read line 3: connection reset")
+		assert.Equal(t, 2, stats.Releases)
+
+		line, err := getImportCheckpoint(db, musicBrainzSource, "release-group")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, line)
+
+		var progress bytes.Buffer
+		stats, err = ImportMusicBrainz(db, strings.NewReader(dump), "release-group", 1, &progress)
+		assert.NoError(t, err)
+		assert.Equal(t, ImportStats{Releases: 2, Skipped: 1}, stats)
+		assert.Equal(t, "Resuming after line 2\n", progress.String())
+		assert.Equal(t, 4, countRows(t, db, "releases"))
+
+		line, err = getImportCheckpoint(db, musicBrainzSource, "release-group")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, line)
+	})
+
+	t.Run("Other Dumps Start From The Beginning", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		mustExec(t, db, "INSERT INTO import_checkpoints (source, dump, line) VALUES ('musicbrainz', 'older dump', 3)")
+
+		stats, err := ImportMusicBrainz(db, strings.NewReader(mustReadFile(t, "testdata/musicbrainz/release-group.jsonl")), "newer dump", 2, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, stats.Releases)
+	})
+
+	t.Run("Invalid Dumps", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+
+		_, err := ImportMusicBrainz(db, strings.NewReader(`{"id": "0383dadf-2a4e-4d10-a46a-e9e041da8eb3", "name": "Queen"}`+"\n{\n"), "", 10, nil)
+		assert.ErrorContains(t, err, "line 2: unexpected end of JSON input")
+
+		_, err = ImportMusicBrainz(db, strings.NewReader(`{"title": 1975}`), "", 10, nil)
+		assert.ErrorContains(t, err, "line 1: json: cannot unmarshal number")
+
+		// Checkpoints past the end of the dump mean it's a different dump with the same name
+		mustExec(t, db, "INSERT INTO import_checkpoints (source, dump, line) VALUES ('musicbrainz', 'short', 5)")
+		_, err = ImportMusicBrainz(db, strings.NewReader("{}\n{}\n"), "short", 10, nil)
+		assert.ErrorContains(t, err, "dump ends before the checkpoint at line 5")
+	})
+}
@@ -1,13 +1,22 @@
 package internal
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
 )
 
+// Pagination modes. Page mode counts the matches and links to pages by number,
+// cursor mode links to the rows after or before an opaque cursor.
+const (
+	PaginationPages  = "pages"
+	PaginationCursor = "cursor"
+)
+
 type Pagination struct {
+	Mode       string  `json:"mode"`
 	Page       int     `json:"page"`
 	Limit      int     `json:"limit"`
 	Offset     int     `json:"offset"`
@@ -19,19 +28,37 @@ type Pagination struct {
 	PrevPage   *int    `json:"prevPage,omitempty"`
 	NextUrl    *string `json:"nextUrl,omitempty"`
 	PrevUrl    *string `json:"prevUrl,omitempty"`
+	NextCursor *string `json:"nextCursor,omitempty"`
+	PrevCursor *string `json:"prevCursor,omitempty"`
+}
+
+// MarshalJSON leaves out the page numbers and counts in cursor mode, where
+// nothing is counted and zeros would read as an empty result.
+func (p Pagination) MarshalJSON() ([]byte, error) {
+	type pagination Pagination
+	if p.Mode != PaginationCursor {
+		return json.Marshal(pagination(p))
+	}
+	return json.Marshal(struct {
+		Mode       string  `json:"mode"`
+		Limit      int     `json:"limit"`
+		NextUrl    *string `json:"nextUrl,omitempty"`
+		PrevUrl    *string `json:"prevUrl,omitempty"`
+		NextCursor *string `json:"nextCursor,omitempty"`
+		PrevCursor *string `json:"prevCursor,omitempty"`
+	}{p.Mode, p.Limit, p.NextUrl, p.PrevUrl, p.NextCursor, p.PrevCursor})
 }
 
 func getPagination(
 	pageStr string,
 	limitStr string,
+	defaultPageSize int,
 	totalCount int,
 	request *http.Request,
 ) (Pagination, error) {
-	const DefaultPageSize = 10
-
 	// Parse inputs
 	page := defaultInt(pageStr, 1)
-	limit := defaultInt(limitStr, DefaultPageSize)
+	limit := defaultInt(limitStr, defaultPageSize)
 
 	if limit <= 0 {
 		return Pagination{}, fmt.Errorf("invalid limit: %d", limit)
@@ -100,6 +127,7 @@ func getPagination(
 
 	// Return pagination metadata
 	return Pagination{
+		Mode:       PaginationPages,
 		Page:       page,
 		Limit:      limit,
 		Offset:     offset,
@@ -113,3 +141,65 @@ func getPagination(
 		PrevUrl:    prevUrl,
 	}, nil
 }
+
+// sortUrl returns the URL of the current list sorted by sort, starting from the first page
+func sortUrl(request *http.Request, sort string) string {
+	return filterUrl(request, "sort", sort)
+}
+
+// filterUrl returns the URL of the current list with the name parameter set
+// to value, or removed when value is empty, starting from the first page
+func filterUrl(request *http.Request, name string, value string) string {
+	queryParams := request.URL.Query()
+	if value == "" {
+		queryParams.Del(name)
+	} else {
+		queryParams.Set(name, value)
+	}
+	queryParams.Del("page")
+	queryParams.Del("after")
+	queryParams.Del("before")
+	return (&url.URL{
+		Path:     request.URL.Path,
+		RawQuery: queryParams.Encode(),
+	}).String()
+}
+
+// setNextCursor links the next page to the rows after cursor
+func (p *Pagination) setNextCursor(request *http.Request, cursor string) {
+	nextUrl := cursorUrl(request, "after", cursor)
+	p.NextCursor = &cursor
+	p.NextUrl = &nextUrl
+}
+
+// setPrevCursor links the previous page to the rows before cursor
+func (p *Pagination) setPrevCursor(request *http.Request, cursor string) {
+	prevUrl := cursorUrl(request, "before", cursor)
+	p.PrevCursor = &cursor
+	p.PrevUrl = &prevUrl
+}
+
+// cursorUrl returns the URL of the current list with the after or before cursor set
+func cursorUrl(request *http.Request, name string, cursor string) string {
+	queryParams := request.URL.Query()
+	queryParams.Del("page")
+	queryParams.Del("after")
+	queryParams.Del("before")
+	queryParams.Set(name, cursor)
+	return (&url.URL{
+		Path:     request.URL.Path,
+		RawQuery: queryParams.Encode(),
+	}).String()
+}
+
+// exportUrl returns the URL of every release in the current list, unpaged, at path
+func exportUrl(request *http.Request, path string) string {
+	queryParams := request.URL.Query()
+	for _, name := range []string{"page", "page_size", "after", "before"} {
+		queryParams.Del(name)
+	}
+	return (&url.URL{
+		Path:     path,
+		RawQuery: queryParams.Encode(),
+	}).String()
+}
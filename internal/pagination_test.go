@@ -26,7 +26,7 @@ func TestGetPagination(t *testing.T) {
 	t.Run("Custom Page and Limit", func(t *testing.T) {
 		req := createRequest(map[string]string{"page": "2", "page_size": "20"})
 		totalCount := 100
-		pagination, err := getPagination("2", "20", totalCount, req)
+		pagination, err := getPagination("2", "20", 10, totalCount, req)
 
 		assert.NoError(t, err)
 		assert.Equal(t, 2, pagination.Page)
@@ -41,7 +41,7 @@ func TestGetPagination(t *testing.T) {
 	t.Run("First Page with Large Limit", func(t *testing.T) {
 		req := createRequest(map[string]string{"page": "1", "page_size": "100"})
 		totalCount := 50
-		pagination, err := getPagination("1", "100", totalCount, req)
+		pagination, err := getPagination("1", "100", 10, totalCount, req)
 
 		assert.NoError(t, err)
 		assert.Equal(t, 1, pagination.Page)
@@ -52,4 +52,34 @@ func TestGetPagination(t *testing.T) {
 		assert.Nil(t, pagination.NextUrl)
 		assert.Nil(t, pagination.PrevUrl)
 	})
+	t.Run("Keeps Search and Sort", func(t *testing.T) {
+		req := createRequest(map[string]string{"page": "2", "q": "artist:queen", "sort": "year_desc"})
+		pagination, err := getPagination("2", "", 10, 30, req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "/releases?page=3&q=artist%3Aqueen&sort=year_desc", *pagination.NextUrl)
+		assert.Equal(t, "/releases?page=1&q=artist%3Aqueen&sort=year_desc", *pagination.PrevUrl)
+	})
+}
+
+func TestSortUrl(t *testing.T) {
+	req := &http.Request{
+		URL: &url.URL{
+			Path:     "/releases",
+			RawQuery: "page=3&page_size=5&q=queen&sort=year",
+		},
+	}
+
+	assert.Equal(t, "/releases?page_size=5&q=queen&sort=name", sortUrl(req, SortName))
+}
+
+func TestExportUrl(t *testing.T) {
+	req := &http.Request{
+		URL: &url.URL{
+			Path:     "/releases",
+			RawQuery: "after=abc&page=3&page_size=5&q=queen&sort=year",
+		},
+	}
+
+	assert.Equal(t, "/releases.csv?q=queen&sort=year", exportUrl(req, "/releases.csv"))
 }
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	// cursorPaginationThreshold is the number of matching rows above which
+	// page links switch from page numbers to cursors
+	cursorPaginationThreshold = 10000
+
+	// maxCursorPageSize caps page_size in cursor mode, where there's no count to cap it at
+	maxCursorPageSize = 1000
+)
+
+// releaseCursor is the content of an after or before token: the sort keys of
+// the row the page starts after or ends before
+type releaseCursor struct {
+	Sort string        `json:"s"`
+	Keys []interface{} `json:"k"`
+}
+
+// encodeReleaseCursor returns an opaque token for the row with the given sort keys
+func encodeReleaseCursor(sort string, keys []interface{}) string {
+	// Keys are only ever numbers and strings read from SQLite, which always marshal
+	data, _ := json.Marshal(releaseCursor{Sort: sort, Keys: keys})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeReleaseCursor returns the sort keys in token, or false when token
+// isn't a cursor for sort. Numbers decode as float64, which SQLite compares
+// equal to the integers they were made from.
+func decodeReleaseCursor(token string, sort string) ([]interface{}, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, false
+	}
+
+	var cursor releaseCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, false
+	}
+
+	order, ok := releaseSortOrders[sort]
+	if !ok || cursor.Sort != sort || len(cursor.Keys) != len(order.keys) {
+		return nil, false
+	}
+	for _, key := range cursor.Keys {
+		switch key.(type) {
+		case float64, string:
+		default:
+			return nil, false
+		}
+	}
+	return cursor.Keys, true
+}
+
+// getReleasesByCursor returns the page of releases after the after cursor,
+// or before the before cursor, in the request. It doesn't count matches, so
+// only the next and previous links of the pagination are set. Invalid
+// cursors start from the first page like invalid page numbers.
+func getReleasesByCursor(
+	db *sql.DB,
+	limitStr string,
+	defaultPageSize int,
+	searchQuery SearchQuery,
+	sort string,
+	request *http.Request,
+) ([]map[string]interface{},
+	Pagination,
+	error,
+) {
+	limit := defaultInt(limitStr, defaultPageSize)
+	if limit > maxCursorPageSize {
+		limit = maxCursorPageSize
+	}
+	sort = releaseSort(sort, searchQuery)
+
+	params := request.URL.Query()
+	cursor, backward := decodeReleaseCursor(params.Get("before"), sort)
+	if !backward {
+		cursor, _ = decodeReleaseCursor(params.Get("after"), sort)
+	}
+
+	// Read one extra row to find out whether there's another page
+	releases, keys, err := queryReleases(db, searchQuery, sort, limit+1, 0, cursor, backward)
+	if err != nil {
+		return nil, Pagination{}, err
+	}
+
+	more := len(releases) > limit
+	if more && backward {
+		releases, keys = releases[1:], keys[1:]
+	} else if more {
+		releases, keys = releases[:limit], keys[:limit]
+	}
+
+	// Paging backward always leaves the cursor row and what follows it as a
+	// next page, and paging forward from a cursor leaves a previous page
+	hasNext := more || backward
+	hasPrev := more && backward || !backward && cursor != nil
+
+	pagination := Pagination{Mode: PaginationCursor, Limit: limit}
+	if hasNext && len(keys) > 0 {
+		pagination.setNextCursor(request, encodeReleaseCursor(sort, keys[len(keys)-1]))
+	}
+	if hasPrev && len(keys) > 0 {
+		pagination.setPrevCursor(request, encodeReleaseCursor(sort, keys[0]))
+	}
+
+	return releases, pagination, nil
+}
@@ -0,0 +1,189 @@
+package internal
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseCursorEncoding(t *testing.T) {
+	token := encodeReleaseCursor(SortName, []interface{}{"queen", int64(1974), int64(3), int64(7)})
+	keys, ok := decodeReleaseCursor(token, SortName)
+	assert.True(t, ok)
+	assert.Equal(t, []interface{}{"queen", float64(1974), float64(3), float64(7)}, keys)
+
+	token = encodeReleaseCursor(SortRelevance, []interface{}{-1.4193548387096776e-06, int64(1974), int64(3), int64(7)})
+	keys, ok = decodeReleaseCursor(token, SortRelevance)
+	assert.True(t, ok)
+	assert.Equal(t, -1.4193548387096776e-06, keys[0])
+
+	encode := func(json string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(json))
+	}
+	for _, invalid := range []string{
+		"",
+		"not base64!",
+		encode("not json"),
+		encode(`{"s":"year","k":[1990,1]}`),
+		encode(`{"s":"name","k":[1990,1,1]}`),
+		encode(`{"s":"year","k":[1990,{"id":1},1]}`),
+		encode(`{"s":"year","k":[1990,null,1]}`),
+		encodeReleaseCursor(SortYearDesc, []interface{}{int64(1990), int64(1), int64(1)}),
+	} {
+		_, ok := decodeReleaseCursor(invalid, SortYear)
+		assert.False(t, ok, "decodeReleaseCursor(%q)", invalid)
+	}
+}
+
+func TestGetReleasesByCursor(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'bowie'), (3, 'Queen Latifah')")
+	mustExec(t, db, `INSERT INTO releases (id, name, year) VALUES
+		(1, 'Queen II', 1974),
+		(2, 'Under Pressure', 1981),
+		(3, 'queen', 1973),
+		(4, 'Heroes', 1977),
+		(5, 'Queen', 1973),
+		(6, 'All Hail the Queen', 1989),
+		(7, 'Low', 1977),
+		(8, 'Queen''s Greatest', 1981)`)
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1), (2, 1), (2, 2), (3, 1), (4, 2), (5, 1), (6, 3), (7, 2), (8, 1)")
+	mustExec(t, db, "INSERT INTO masters (id, name) VALUES (1, 'Queen'), (2, 'Heroes')")
+	mustExec(t, db, "UPDATE releases SET master_id = 1 WHERE id IN (3, 5, 1)")
+	mustExec(t, db, "UPDATE releases SET master_id = 2 WHERE id IN (4, 7)")
+
+	rowNames := func(releases []map[string]interface{}) []string {
+		names := []string{}
+		for _, release := range releases {
+			names = append(names, fmt.Sprintf("%d/%s", release["release_id"], release["artist_credit"]))
+		}
+		return names
+	}
+	request := func(params url.Values) *http.Request {
+		return &http.Request{URL: &url.URL{Path: "/releases", RawQuery: params.Encode()}}
+	}
+
+	for _, test := range []struct {
+		searchQuery string
+		sort        string
+		collapse    bool
+	}{
+		{"", SortYear, false},
+		{"", SortYearDesc, false},
+		{"", SortName, false},
+		{"", SortArtist, false},
+		{"queen", SortRelevance, false},
+		{"queen -heroes", SortName, false},
+		{"", SortYear, true},
+		{"", SortYearDesc, true},
+		{"queen", SortRelevance, true},
+	} {
+		t.Run(fmt.Sprintf("%s %s collapse=%t", test.searchQuery, test.sort, test.collapse), func(t *testing.T) {
+			query := mustParseSearchQuery(t, test.searchQuery)
+			query.CollapseMasters = test.collapse
+			all, err := getReleases(db, 100, 0, query, test.sort, nil)
+			assert.NoError(t, err)
+			expected := rowNames(all)
+
+			// Walk forward from the start following next cursors
+			var forward []string
+			var pages []Pagination
+			params := url.Values{"after": {""}}
+			for i := 0; i < 10; i++ {
+				releases, pagination, err := getReleasesByCursor(db, "3", 10, query, test.sort, request(params))
+				assert.NoError(t, err)
+				assert.Equal(t, PaginationCursor, pagination.Mode)
+				assert.LessOrEqual(t, len(releases), 3)
+				assert.Equal(t, i > 0, pagination.PrevCursor != nil, "page %d prev cursor", i)
+
+				forward = append(forward, rowNames(releases)...)
+				pages = append(pages, pagination)
+				if pagination.NextCursor == nil {
+					break
+				}
+				params = url.Values{"after": {*pagination.NextCursor}}
+			}
+			assert.Equal(t, expected, forward)
+
+			// Walk back from the last page following previous cursors
+			var backward []string
+			params = url.Values{"before": {*pages[len(pages)-1].PrevCursor}}
+			for {
+				releases, pagination, err := getReleasesByCursor(db, "3", 10, query, test.sort, request(params))
+				assert.NoError(t, err)
+				assert.NotNil(t, pagination.NextCursor)
+
+				backward = append(rowNames(releases), backward...)
+				if pagination.PrevCursor == nil {
+					break
+				}
+				params = url.Values{"before": {*pagination.PrevCursor}}
+			}
+			lastPage := len(expected) - len(expected)%3
+			if len(expected)%3 == 0 {
+				lastPage -= 3
+			}
+			assert.Equal(t, expected[:lastPage], backward)
+		})
+	}
+
+	t.Run("Invalid Cursor Starts From The First Page", func(t *testing.T) {
+		releases, pagination, err := getReleasesByCursor(db, "2", 10, SearchQuery{}, SortYear, request(url.Values{"after": {"bogus"}}))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"3/Queen", "5/Queen"}, rowNames(releases))
+		assert.Nil(t, pagination.PrevUrl)
+		assert.NotNil(t, pagination.NextUrl)
+	})
+
+	t.Run("Urls", func(t *testing.T) {
+		_, pagination, err := getReleasesByCursor(db, "2", 10, SearchQuery{}, "", request(url.Values{"after": {""}, "q": {""}, "page": {"4"}}))
+		assert.NoError(t, err)
+		assert.Equal(t, "/releases?after="+*pagination.NextCursor+"&q=", *pagination.NextUrl)
+
+		_, pagination, err = getReleasesByCursor(db, "2", 10, SearchQuery{}, "", request(url.Values{"after": {*pagination.NextCursor}}))
+		assert.NoError(t, err)
+		assert.Equal(t, "/releases?before="+*pagination.PrevCursor, *pagination.PrevUrl)
+	})
+
+	t.Run("Page Size Is Capped", func(t *testing.T) {
+		_, pagination, err := getReleasesByCursor(db, "9223372036854775807", 10, SearchQuery{}, SortYear, request(url.Values{"after": {""}}))
+		assert.NoError(t, err)
+		assert.Equal(t, maxCursorPageSize, pagination.Limit)
+	})
+}
+
+func TestLargeCatalogsLinkToCursors(t *testing.T) {
+	db := openMigratedTestDB(t)
+	// The sync triggers scan releases_fts for every inserted row, so fill it in one go instead
+	mustExec(t, db, "DROP TRIGGER releases_ai")
+	mustExec(t, db, "DROP TRIGGER release_artists_ai")
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Prolific')")
+	mustExec(t, db, `
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i <= ?)
+		INSERT INTO releases (id, name, year) SELECT i, 'Release ' || i, 1950 + i % 70 FROM n`, cursorPaginationThreshold)
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) SELECT id, 1 FROM releases")
+	mustExec(t, db, `
+		INSERT INTO releases_fts (rowid, release_id, release_name, release_year, artist_names, artist_credit)
+		SELECT id, id, name, year, 'Prolific', 'Prolific' FROM releases`)
+
+	request := &http.Request{URL: &url.URL{Path: "/releases", RawQuery: "page=3&page_size=10"}}
+	releases, pagination, err := getPaginatedReleases(db, "3", "10", 10, SearchQuery{}, SortYear, nil, request)
+	assert.NoError(t, err)
+	assert.Len(t, releases, 10)
+
+	// The page itself is still numbered, only the links use cursors
+	assert.Equal(t, PaginationPages, pagination.Mode)
+	assert.Equal(t, cursorPaginationThreshold+1, pagination.TotalCount)
+	assert.Equal(t, "/releases?after="+*pagination.NextCursor+"&page_size=10", *pagination.NextUrl)
+	assert.Equal(t, "/releases?before="+*pagination.PrevCursor+"&page_size=10", *pagination.PrevUrl)
+
+	next, _, err := getReleasesByCursor(db, "10", 10, SearchQuery{}, SortYear, &http.Request{URL: &url.URL{RawQuery: "after=" + *pagination.NextCursor}})
+	assert.NoError(t, err)
+	expected, err := getReleases(db, 10, 30, SearchQuery{}, SortYear, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, next)
+}
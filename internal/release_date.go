@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"strings"
+	"time"
+)
+
+// Precisions a release date can be known to
+const (
+	DatePrecisionYear  = "year"
+	DatePrecisionMonth = "month"
+	DatePrecisionDay   = "day"
+)
+
+// releaseDateLayouts are the layouts of release dates by precision
+var releaseDateLayouts = map[string]string{
+	DatePrecisionYear:  "2006",
+	DatePrecisionMonth: "2006-01",
+	DatePrecisionDay:   "2006-01-02",
+}
+
+// parseReleaseDate normalizes a release date given as 1975, 1975-11 or
+// 1975-11-21. Discogs style dates with 00 for an unknown month or day, like
+// 1975-11-00, are cut to the part that's known. ok is false for anything
+// that isn't a real date.
+func parseReleaseDate(date string) (normalized string, year int, ok bool) {
+	date = strings.TrimSpace(date)
+	date = strings.TrimSuffix(date, "-00")
+	date = strings.TrimSuffix(date, "-00")
+
+	precision := releaseDatePrecision(date)
+	if precision == "" {
+		return "", 0, false
+	}
+	parsed, err := time.Parse(releaseDateLayouts[precision], date)
+	if err != nil {
+		return "", 0, false
+	}
+	return date, parsed.Year(), true
+}
+
+// releaseDatePrecision returns how precisely a normalized release date is
+// known, from its length. It's empty for an empty or malformed date.
+func releaseDatePrecision(date string) string {
+	switch len(date) {
+	case 4:
+		return DatePrecisionYear
+	case 7:
+		return DatePrecisionMonth
+	case 10:
+		return DatePrecisionDay
+	}
+	return ""
+}
+
+// formatReleaseDate renders a normalized release date to its precision, like
+// "21 November 1975", "November 1975" or "1975"
+func formatReleaseDate(date string) string {
+	precision := releaseDatePrecision(date)
+	parsed, err := time.Parse(releaseDateLayouts[precision], date)
+	if precision == "" || err != nil {
+		return date
+	}
+	switch precision {
+	case DatePrecisionDay:
+		return parsed.Format("2 January 2006")
+	case DatePrecisionMonth:
+		return parsed.Format("January 2006")
+	}
+	return parsed.Format("2006")
+}
@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReleaseDate(t *testing.T) {
+	tests := []struct {
+		input      string
+		normalized string
+		year       int
+		ok         bool
+	}{
+		{"1975", "1975", 1975, true},
+		{" 1975-11 ", "1975-11", 1975, true},
+		{"1975-11-21", "1975-11-21", 1975, true},
+		{"1975-11-00", "1975-11", 1975, true},
+		{"1975-00-00", "1975", 1975, true},
+		{"1975-13", "", 0, false},
+		{"1975-02-30", "", 0, false},
+		{"21/11/1975", "", 0, false},
+		{"75", "", 0, false},
+	}
+	for _, test := range tests {
+		normalized, year, ok := parseReleaseDate(test.input)
+		assert.Equal(t, test.normalized, normalized, test.input)
+		assert.Equal(t, test.year, year, test.input)
+		assert.Equal(t, test.ok, ok, test.input)
+	}
+}
+
+func TestFormatReleaseDate(t *testing.T) {
+	assert.Equal(t, "21 November 1975", formatReleaseDate("1975-11-21"))
+	assert.Equal(t, "November 1975", formatReleaseDate("1975-11"))
+	assert.Equal(t, "1975", formatReleaseDate("1975"))
+
+	assert.Equal(t, "1975", Release{Year: 1975}.Released())
+	assert.Equal(t, DatePrecisionYear, Release{Year: 1975}.DatePrecision())
+	assert.Equal(t, DatePrecisionMonth, Release{Year: 1975, ReleaseDate: "1975-11"}.DatePrecision())
+}
+
+func TestReleaseDates(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen')")
+
+	// The year is taken from the date when it's left out
+	release, err := createRelease(db, ReleaseInput{Name: "A Night at the Opera", ReleaseDate: "1975-11-21", ArtistIds: []int{1}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1975, release.Year)
+	assert.Equal(t, "1975-11-21", release.ReleaseDate)
+
+	release, err = updateRelease(db, release.Id, ReleaseInput{Name: "A Night at the Opera", Year: 1975, ReleaseDate: "1975-11-00", ArtistIds: []int{1}})
+	assert.NoError(t, err)
+	assert.Equal(t, "November 1975", release.Released())
+
+	_, err = createRelease(db, ReleaseInput{Name: "Jazz", Year: 1978, ReleaseDate: "1979-01"})
+	var validationErr *ValidationError
+	if assert.True(t, errors.As(err, &validationErr)) {
+		assert.Equal(t, map[string]string{"release_date": "Release date must be in 1978"}, validationErr.Fields)
+	}
+
+	_, err = createRelease(db, ReleaseInput{Name: "Jazz", ReleaseDate: "November"})
+	if assert.True(t, errors.As(err, &validationErr)) {
+		assert.Equal(t, "Release date must be a year, year and month, or full date like 1975-11-21", validationErr.Fields["release_date"])
+		assert.NotEmpty(t, validationErr.Fields["year"])
+	}
+}
@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+)
+
+// artistFacetLimit is the number of artists listed in the artist facet
+const artistFacetLimit = 10
+
+type DecadeFacet struct {
+	Decade int    `json:"decade"`
+	Count  int    `json:"count"`
+	Url    string `json:"-"`
+}
+
+type ArtistFacet struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+	Url   string `json:"-"`
+}
+
+// ReleaseFacets counts the releases matching a search by decade and by artist
+type ReleaseFacets struct {
+	Decades []DecadeFacet `json:"decades"`
+	Artists []ArtistFacet `json:"artists"`
+}
+
+// getReleaseFacets counts the releases matching query in each decade, and
+// for the artists with the most matching releases. When the query collapses
+// masters, the versions of a master count once, like they're listed.
+func getReleaseFacets(db *sql.DB, query SearchQuery) (ReleaseFacets, error) {
+	facets := ReleaseFacets{Decades: []DecadeFacet{}, Artists: []ArtistFacet{}}
+	where, args := query.filter()
+
+	counted, artistCounted := "*", "DISTINCT release_artists.release_id"
+	if query.CollapseMasters {
+		counted = "DISTINCT " + masterGroupKey
+		artistCounted = "DISTINCT COALESCE(releases.master_id, -releases.id)"
+	}
+
+	rows, err := db.Query(`
+		SELECT
+			CAST(release_year AS INTEGER) / 10 * 10 AS decade,
+			COUNT(`+counted+`)
+		FROM releases_fts
+		`+where+`
+		GROUP BY decade
+		ORDER BY decade ASC
+	`, args...)
+	if err != nil {
+		return ReleaseFacets{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var facet DecadeFacet
+		if err := rows.Scan(&facet.Decade, &facet.Count); err != nil {
+			return ReleaseFacets{}, err
+		}
+		facets.Decades = append(facets.Decades, facet)
+	}
+	if err := rows.Err(); err != nil {
+		return ReleaseFacets{}, err
+	}
+
+	// releases_fts has the artists of a release on one row, so they're
+	// counted from the credits of the matching releases
+	rows, err = db.Query(`
+		SELECT
+			artists.name,
+			COUNT(`+artistCounted+`) AS release_count
+		FROM release_artists
+		JOIN artists ON artists.id = release_artists.artist_id
+		JOIN releases ON releases.id = release_artists.release_id
+		WHERE release_artists.release_id IN (SELECT release_id FROM releases_fts `+where+`)
+		GROUP BY artists.name
+		ORDER BY release_count DESC, artists.name COLLATE NOCASE ASC
+		LIMIT ?
+	`, append(args, artistFacetLimit)...)
+	if err != nil {
+		return ReleaseFacets{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var facet ArtistFacet
+		if err := rows.Scan(&facet.Name, &facet.Count); err != nil {
+			return ReleaseFacets{}, err
+		}
+		facets.Artists = append(facets.Artists, facet)
+	}
+
+	return facets, rows.Err()
+}
+
+// withUrls sets the URL of each facet to the current list narrowed by that facet
+func (f ReleaseFacets) withUrls(request *http.Request) ReleaseFacets {
+	for i, facet := range f.Decades {
+		f.Decades[i].Url = filterUrl(request, "decade", strconv.Itoa(facet.Decade))
+	}
+	for i, facet := range f.Artists {
+		f.Artists[i].Url = filterUrl(request, "artist", facet.Name)
+	}
+	return f
+}
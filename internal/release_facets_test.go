@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetReleaseFacets(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'Bowie'), (3, 'Queen Latifah')")
+	mustExec(t, db, `INSERT INTO releases (id, name, year) VALUES
+		(1, 'Hot Space', 1982),
+		(2, 'Under Pressure', 1981),
+		(3, 'Innuendo', 1991),
+		(4, 'Heroes', 1977),
+		(5, 'Black Reign', 1993),
+		(6, 'Unlinked', 1999)`)
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1), (2, 1), (2, 2), (3, 1), (4, 2), (5, 3)")
+
+	t.Run("All Releases", func(t *testing.T) {
+		facets, err := getReleaseFacets(db, SearchQuery{})
+		assert.NoError(t, err)
+
+		// Under Pressure has two artists but counts once in its decade, and
+		// Unlinked counts without any
+		assert.Equal(t, []DecadeFacet{{Decade: 1970, Count: 1}, {Decade: 1980, Count: 2}, {Decade: 1990, Count: 3}}, facets.Decades)
+		assert.Equal(t, []ArtistFacet{{Name: "Queen", Count: 3}, {Name: "Bowie", Count: 2}, {Name: "Queen Latifah", Count: 1}}, facets.Artists)
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		facets, err := getReleaseFacets(db, mustParseSearchQuery(t, "artist:queen -space"))
+		assert.NoError(t, err)
+		assert.Equal(t, []DecadeFacet{{Decade: 1980, Count: 1}, {Decade: 1990, Count: 2}}, facets.Decades)
+		// Bowie is counted for Under Pressure, which Queen also matches
+		assert.Equal(t, []ArtistFacet{{Name: "Queen", Count: 2}, {Name: "Bowie", Count: 1}, {Name: "Queen Latifah", Count: 1}}, facets.Artists)
+	})
+
+	t.Run("Picked Facets", func(t *testing.T) {
+		facets, err := getReleaseFacets(db, SearchQuery{Decade: 1980, Artist: "Queen"})
+		assert.NoError(t, err)
+		assert.Equal(t, []DecadeFacet{{Decade: 1980, Count: 2}}, facets.Decades)
+		assert.Equal(t, []ArtistFacet{{Name: "Queen", Count: 2}, {Name: "Bowie", Count: 1}}, facets.Artists)
+
+		releases, err := getReleases(db, 10, 0, SearchQuery{Decade: 1980, Artist: "Queen"}, SortYear, nil)
+		assert.NoError(t, err)
+		assert.Len(t, releases, 2)
+	})
+
+	t.Run("No Matches", func(t *testing.T) {
+		facets, err := getReleaseFacets(db, mustParseSearchQuery(t, "nothing"))
+		assert.NoError(t, err)
+		assert.Equal(t, ReleaseFacets{Decades: []DecadeFacet{}, Artists: []ArtistFacet{}}, facets)
+	})
+
+	t.Run("Top Artists Only", func(t *testing.T) {
+		for i := 10; i < 10+artistFacetLimit; i++ {
+			mustExec(t, db, "INSERT INTO artists (id, name) VALUES (?, ?)", i, fmt.Sprintf("Extra %d", i))
+			mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (6, ?)", i)
+		}
+
+		facets, err := getReleaseFacets(db, SearchQuery{})
+		assert.NoError(t, err)
+		assert.Len(t, facets.Artists, artistFacetLimit)
+		assert.Equal(t, "Queen", facets.Artists[0].Name)
+		assert.Equal(t, "Extra 10", facets.Artists[2].Name)
+	})
+}
+
+func TestReleaseFacetsWithUrls(t *testing.T) {
+	request := &http.Request{URL: &url.URL{Path: "/releases", RawQuery: "q=queen&page=2&sort=name"}}
+	facets := ReleaseFacets{
+		Decades: []DecadeFacet{{Decade: 1980, Count: 2}},
+		Artists: []ArtistFacet{{Name: "Queen & Bowie", Count: 1}},
+	}.withUrls(request)
+
+	assert.Equal(t, "/releases?decade=1980&q=queen&sort=name", facets.Decades[0].Url)
+	assert.Equal(t, "/releases?artist=Queen+%26+Bowie&q=queen&sort=name", facets.Artists[0].Url)
+}
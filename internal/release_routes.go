@@ -0,0 +1,308 @@
+package internal
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+func setupReleaseRoutes(e *echo.Echo, db *sql.DB) {
+	// renderReleaseForm renders the create/edit form, or just the form partial for HTMX requests
+	renderReleaseForm := func(c echo.Context, status int, releaseId int, input ReleaseInput, fieldErrors map[string]string) error {
+		artists, err := getAllArtists(db)
+		if err != nil {
+			e.Logger.Printf("Failed to get artists: %v", err)
+			return c.String(http.StatusInternalServerError, "Failed to load artists")
+		}
+
+		// Blank rows after the release's credits leave room to add more
+		creditRows := append([]CreditInput{}, input.Credits...)
+		if len(creditRows) == 0 {
+			creditRows = mainCredits(input.ArtistIds)
+		}
+		credited := map[int]bool{}
+		for _, credit := range creditRows {
+			credited[credit.ArtistId] = true
+		}
+		for i := 0; i < releaseFormBlankCredits; i++ {
+			creditRows = append(creditRows, CreditInput{})
+		}
+
+		creditedArtists := []Artist{}
+		for _, artist := range artists {
+			if credited[artist.Id] {
+				creditedArtists = append(creditedArtists, artist)
+			}
+		}
+
+		masters, err := getAllMasters(db)
+		if err != nil {
+			e.Logger.Printf("Failed to get masters: %v", err)
+			return c.String(http.StatusInternalServerError, "Failed to load masters")
+		}
+
+		// Styles are listed after their genre
+		genres, err := getTags(db, genreTaxonomy)
+		if err != nil {
+			e.Logger.Printf("Failed to get genres: %v", err)
+			return c.String(http.StatusInternalServerError, "Failed to load genres")
+		}
+		genreNames := map[int]string{}
+		for _, genre := range genres {
+			genreNames[genre.Id] = genre.Name
+		}
+		sortKey := func(genre TagSummary) string {
+			if genre.ParentId != 0 {
+				return strings.ToLower(genreNames[genre.ParentId] + "\x00" + genre.Name)
+			}
+			return strings.ToLower(genre.Name)
+		}
+		sort.SliceStable(genres, func(i, j int) bool { return sortKey(genres[i]) < sortKey(genres[j]) })
+
+		selectedGenres := map[int]bool{}
+		for _, genreId := range input.GenreIds {
+			selectedGenres[genreId] = true
+		}
+
+		title := "New Release"
+		if releaseId != 0 {
+			title = "Edit Release"
+		}
+
+		// Blank rows after the release's labels leave room to add more
+		labelRows := append([]ReleaseLabelInput{}, input.Labels...)
+		for i := 0; i < releaseFormBlankLabels; i++ {
+			labelRows = append(labelRows, ReleaseLabelInput{})
+		}
+
+		// Blank rows after the release's formats leave room to add more
+		formatRows := append([]Format{}, input.Formats...)
+		for i := 0; i < releaseFormBlankFormats; i++ {
+			formatRows = append(formatRows, Format{})
+		}
+
+		data := map[string]interface{}{
+			"Title":           title,
+			"ReleaseId":       releaseId,
+			"Input":           input,
+			"Errors":          fieldErrors,
+			"Artists":         artists,
+			"CreditRows":      creditRows,
+			"CreditRoles":     CreditRoles,
+			"JoinPhrases":     JoinPhrases,
+			"CreditedArtists": creditedArtists,
+			"Masters":         masters,
+			"FormatRows":      formatRows,
+			"FormatNames":     FormatNames,
+			"FormatLabels":    formatLabels,
+			"Descriptors":     FormatDescriptors,
+			"LabelRows":       labelRows,
+			"Genres":          genres,
+			"GenreNames":      genreNames,
+			"SelectedGenres":  selectedGenres,
+			"TagNames":        strings.Join(input.Tags, ", "),
+			"IncludeHTMX":     true,
+			"CurrentRoute":    c.Request().URL.Path,
+		}
+
+		if isHtmxRequest(c) {
+			return c.Render(status, "release_form_partial", data)
+		}
+		return c.Render(status, "release_form", data)
+	}
+
+	// handleReleaseError re-renders the form for validation errors and maps everything else to a status
+	handleReleaseError := func(c echo.Context, err error, releaseId int, input ReleaseInput) error {
+		var validationErr *ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			return renderReleaseForm(c, invalidFormStatus(c), releaseId, input, validationErr.Fields)
+		case errors.Is(err, errNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Release not found")
+		default:
+			e.Logger.Printf("Failed to save release: %v", err)
+			return c.String(http.StatusInternalServerError, "Failed to save release")
+		}
+	}
+
+	e.GET("/releases/:id", func(c echo.Context) error {
+		releaseId, err := paramId(c)
+		if err != nil {
+			return renderNotFound(c, "This release doesn't exist.")
+		}
+
+		release, err := getReleaseDetail(db, releaseId)
+		if errors.Is(err, errNotFound) {
+			return renderNotFound(c, "This release doesn't exist.")
+		}
+		if err != nil {
+			e.Logger.Printf("Failed to get release: %v", err)
+			return c.String(http.StatusInternalServerError, "Failed to load release")
+		}
+
+		data := map[string]interface{}{
+			"Title":        release.Name,
+			"Release":      release,
+			"Permalink":    c.Scheme() + "://" + c.Request().Host + "/releases/" + strconv.Itoa(release.Id),
+			"IncludeHTMX":  true,
+			"CurrentRoute": c.Request().URL.Path,
+		}
+
+		return c.Render(http.StatusOK, "release", data)
+	})
+
+	e.GET("/releases/new", func(c echo.Context) error {
+		return renderReleaseForm(c, http.StatusOK, 0, ReleaseInput{}, nil)
+	})
+
+	e.POST("/releases", func(c echo.Context) error {
+		var input ReleaseInput
+		if err := bindReleaseForm(c, &input); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid release")
+		}
+
+		release, err := createRelease(db, input)
+		if err != nil {
+			return handleReleaseError(c, err, 0, input)
+		}
+
+		return redirectAfterSubmit(c, "/releases/"+strconv.Itoa(release.Id))
+	})
+
+	e.GET("/releases/:id/edit", func(c echo.Context) error {
+		releaseId, err := paramId(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Release not found")
+		}
+
+		release, err := getRelease(db, releaseId)
+		if err != nil {
+			return handleReleaseError(c, err, releaseId, ReleaseInput{})
+		}
+
+		input := ReleaseInput{
+			Name:        release.Name,
+			Year:        release.Year,
+			ReleaseDate: release.ReleaseDate,
+			Barcode:     release.Barcode,
+			MasterId:    release.MasterId,
+			Country:     release.Country,
+			Formats:     release.Formats,
+		}
+		for _, credit := range release.Credits {
+			input.Credits = append(input.Credits, CreditInput{ArtistId: credit.Id, Role: credit.Role, JoinPhrase: credit.JoinPhrase})
+		}
+		for _, label := range release.Labels {
+			input.Labels = append(input.Labels, ReleaseLabelInput{Name: label.Name, Catno: label.Catno})
+		}
+		for _, genre := range release.Genres {
+			input.GenreIds = append(input.GenreIds, genre.Id)
+		}
+		for _, tag := range release.Tags {
+			input.Tags = append(input.Tags, tag.Name)
+		}
+
+		return renderReleaseForm(c, http.StatusOK, releaseId, input, nil)
+	})
+
+	e.PUT("/releases/:id", func(c echo.Context) error {
+		releaseId, err := paramId(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Release not found")
+		}
+
+		var input ReleaseInput
+		if err := bindReleaseForm(c, &input); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid release")
+		}
+
+		if _, err := updateRelease(db, releaseId, input); err != nil {
+			return handleReleaseError(c, err, releaseId, input)
+		}
+
+		return redirectAfterSubmit(c, "/releases/"+strconv.Itoa(releaseId))
+	})
+
+	e.DELETE("/releases/:id", func(c echo.Context) error {
+		releaseId, err := paramId(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Release not found")
+		}
+
+		if err := deleteRelease(db, releaseId); err != nil {
+			return handleReleaseError(c, err, releaseId, ReleaseInput{})
+		}
+
+		// Reload the current page of results so counts and pagination stay correct
+		c.Response().Header().Set("HX-Refresh", "true")
+		return c.NoContent(http.StatusOK)
+	})
+}
+
+// releaseFormBlankLabels, releaseFormBlankCredits and releaseFormBlankFormats
+// are how many empty label, credit and format rows the release form shows
+const (
+	releaseFormBlankLabels  = 2
+	releaseFormBlankCredits = 2
+	releaseFormBlankFormats = 1
+)
+
+// bindReleaseForm binds the release form. Labels are sent as rows of
+// label_name and catno fields, credits as rows of credit_artist_id,
+// credit_role and credit_join_phrase fields, and formats as rows of
+// format_name, format_quantity and format_descriptors fields, which are
+// paired up by position.
+func bindReleaseForm(c echo.Context, input *ReleaseInput) error {
+	if err := c.Bind(input); err != nil {
+		return err
+	}
+
+	form, err := c.FormParams()
+	if err != nil {
+		return err
+	}
+	names, catnos := form["label_name"], form["catno"]
+	for i, name := range names {
+		label := ReleaseLabelInput{Name: name}
+		if i < len(catnos) {
+			label.Catno = catnos[i]
+		}
+		input.Labels = append(input.Labels, label)
+	}
+
+	roles, joinPhrases := form["credit_role"], form["credit_join_phrase"]
+	for i, artistId := range form["credit_artist_id"] {
+		credit := CreditInput{}
+		credit.ArtistId, _ = strconv.Atoi(artistId)
+		if i < len(roles) {
+			credit.Role = roles[i]
+		}
+		if i < len(joinPhrases) {
+			credit.JoinPhrase = joinPhrases[i]
+		}
+		input.Credits = append(input.Credits, credit)
+	}
+
+	quantities, descriptors := form["format_quantity"], form["format_descriptors"]
+	for i, name := range form["format_name"] {
+		format := Format{Name: name}
+		if i < len(quantities) && strings.TrimSpace(quantities[i]) != "" {
+			quantity, err := strconv.Atoi(strings.TrimSpace(quantities[i]))
+			if err != nil {
+				// Fails validation rather than quietly becoming 1
+				quantity = -1
+			}
+			format.Quantity = quantity
+		}
+		if i < len(descriptors) {
+			format.Descriptors = splitDescriptors(descriptors[i])
+		}
+		input.Formats = append(input.Formats, format)
+	}
+	return nil
+}
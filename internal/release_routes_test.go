@@ -0,0 +1,254 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseFormRoutes(t *testing.T) {
+	e := echo.New()
+	e.Renderer = &Template{TemplateDir: "./templates"}
+
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'Bowie')")
+	mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Hot Space', 1982)")
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1)")
+	SetupRoutes(e, db, DefaultConfig())
+
+	submit := func(method string, target string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		req.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("GET /releases/new", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/releases/new", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "New Release")
+		assert.Contains(t, rec.Body.String(), `hx-post="/releases"`)
+		assert.Contains(t, rec.Body.String(), "Bowie")
+	})
+
+	t.Run("GET /releases/:id/edit", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/releases/1/edit", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `value="Hot Space"`)
+		assert.Contains(t, rec.Body.String(), `<option value="1" selected>Queen</option>`)
+	})
+
+	t.Run("GET /releases/:id/edit with unknown id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/releases/999/edit", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("POST /releases", func(t *testing.T) {
+		rec := submit(http.MethodPost, "/releases", url.Values{
+			"name":       {"Heroes"},
+			"year":       {"1977"},
+			"artist_ids": {"2"},
+		})
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Regexp(t, `^/releases/\d+$`, rec.Header().Get("HX-Redirect"))
+		assert.Equal(t, []string{"Bowie"}, searchCredits(t, db, "Heroes"))
+	})
+
+	t.Run("POST /releases with invalid release", func(t *testing.T) {
+		rec := submit(http.MethodPost, "/releases", url.Values{
+			"name": {""},
+			"year": {"1977"},
+		})
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("HX-Redirect"))
+		assert.Contains(t, rec.Body.String(), "Name is required")
+		assert.NotContains(t, rec.Body.String(), "<html")
+	})
+
+	t.Run("PUT /releases/:id", func(t *testing.T) {
+		rec := submit(http.MethodPut, "/releases/1", url.Values{
+			"name":       {"Hot Space"},
+			"year":       {"1982"},
+			"artist_ids": {"1", "2"},
+		})
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []string{"Queen, Bowie"}, searchCredits(t, db, "Hot Space"))
+	})
+
+	t.Run("PUT /releases/:id with labels", func(t *testing.T) {
+		rec := submit(http.MethodPut, "/releases/1", url.Values{
+			"name":       {"Hot Space"},
+			"year":       {"1982"},
+			"barcode":    {"0 77774-62082 6"},
+			"artist_ids": {"1"},
+			"label_name": {"EMI", "Elektra", ""},
+			"catno":      {"CDP 7 46208 2", "", ""},
+		})
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []string{"Queen"}, searchCredits(t, db, "catno:cdp7462082 label:elektra barcode:077774620826"))
+
+		req := httptest.NewRequest(http.MethodGet, "/releases/1/edit", nil)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Contains(t, rec.Body.String(), `value="077774620826"`)
+		assert.Contains(t, rec.Body.String(), `value="CDP 7 46208 2"`)
+		assert.Equal(t, 2+releaseFormBlankLabels, strings.Count(rec.Body.String(), `name="label_name"`))
+
+		rec = submit(http.MethodPut, "/releases/1", url.Values{
+			"name":       {"Hot Space"},
+			"year":       {"1982"},
+			"barcode":    {"123"},
+			"label_name": {""},
+			"catno":      {"CDP 7 46208 2"},
+		})
+		assert.Contains(t, rec.Body.String(), "Barcode must be 8, 12, 13 or 14 digits")
+		assert.Contains(t, rec.Body.String(), "Catalog number CDP 7 46208 2 needs a label")
+	})
+
+	t.Run("PUT /releases/:id with credits", func(t *testing.T) {
+		rec := submit(http.MethodPut, "/releases/1", url.Values{
+			"name":               {"Hot Space"},
+			"year":               {"1982"},
+			"credit_artist_id":   {"1", "2", ""},
+			"credit_role":        {"main", "featuring", "main"},
+			"credit_join_phrase": {"", "", ""},
+		})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "/releases/1", rec.Header().Get("HX-Redirect"))
+
+		req := httptest.NewRequest(http.MethodGet, "/releases/1", nil)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Contains(t, rec.Body.String(), `>Queen</a> feat. <a href="/artists/2"`)
+
+		req = httptest.NewRequest(http.MethodGet, "/releases/1/edit", nil)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Contains(t, rec.Body.String(), `<option value="featuring" selected>featuring</option>`)
+		assert.Equal(t, 2+releaseFormBlankCredits, strings.Count(rec.Body.String(), `name="credit_artist_id"`))
+
+		rec = submit(http.MethodPut, "/releases/1", url.Values{
+			"name":             {"Hot Space"},
+			"year":             {"1982"},
+			"credit_artist_id": {"1"},
+			"credit_role":      {"singer"},
+		})
+		assert.Contains(t, rec.Body.String(), "Unknown role &#34;singer&#34;, use main, featuring, producer or remixer")
+	})
+
+	t.Run("PUT /releases/:id with formats and release date", func(t *testing.T) {
+		rec := submit(http.MethodPut, "/releases/1", url.Values{
+			"name":               {"Hot Space"},
+			"year":               {""},
+			"release_date":       {"1982-05-21"},
+			"country":            {"UK"},
+			"artist_ids":         {"1"},
+			"format_name":        {"vinyl", "cd", ""},
+			"format_quantity":    {"", "2", ""},
+			"format_descriptors": {"LP, Album", "", ""},
+		})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "/releases/1", rec.Header().Get("HX-Redirect"))
+
+		req := httptest.NewRequest(http.MethodGet, "/releases/1", nil)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Contains(t, rec.Body.String(), "21 May 1982")
+		assert.Contains(t, rec.Body.String(), "<div>Vinyl, LP, Album</div><div>2 × CD</div>")
+
+		req = httptest.NewRequest(http.MethodGet, "/releases?q=format:vinyl+country:uk", nil)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Contains(t, rec.Body.String(), "Vinyl, LP, Album &#43; 2 × CD")
+		assert.Contains(t, rec.Body.String(), `<td class="px-3 py-4 text-sm text-gray-500">UK</td>`)
+
+		req = httptest.NewRequest(http.MethodGet, "/releases/1/edit", nil)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Contains(t, rec.Body.String(), `value="1982-05-21"`)
+		assert.Contains(t, rec.Body.String(), `value="LP, Album"`)
+		assert.Contains(t, rec.Body.String(), `<option value="cd" selected>CD</option>`)
+		assert.Equal(t, 2+releaseFormBlankFormats, strings.Count(rec.Body.String(), `name="format_name"`))
+
+		rec = submit(http.MethodPut, "/releases/1", url.Values{
+			"name":               {"Hot Space"},
+			"year":               {"1982"},
+			"release_date":       {"1983-01"},
+			"format_name":        {"cd"},
+			"format_quantity":    {"two"},
+			"format_descriptors": {""},
+		})
+		assert.Contains(t, rec.Body.String(), "Release date must be in 1982")
+		assert.Contains(t, rec.Body.String(), "Quantity must be between 1 and 100")
+	})
+
+	t.Run("DELETE /releases/:id", func(t *testing.T) {
+		rec := submit(http.MethodDelete, "/releases/1", nil)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "true", rec.Header().Get("HX-Refresh"))
+		assert.Empty(t, searchCredits(t, db, "Hot Space"))
+	})
+}
+
+func TestReleaseDetailRoutes(t *testing.T) {
+	e := echo.New()
+	e.Renderer = &Template{TemplateDir: "./templates"}
+
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'Bowie'), (3, 'Oasis')")
+	mustExec(t, db, `INSERT INTO releases (id, name, year) VALUES
+		(1, 'Under Pressure', 1981),
+		(2, 'Hot Space', 1982),
+		(3, 'Heroes', 1977),
+		(4, 'Definitely Maybe', 1994)`)
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1), (1, 2), (2, 1), (3, 2), (4, 3)")
+	SetupRoutes(e, db, DefaultConfig())
+
+	t.Run("GET /releases/:id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/releases/1", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		body := rec.Body.String()
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, body, "Under Pressure")
+		assert.Contains(t, body, `href="/artists/1" class="text-rose-800 hover:underline">Queen</a>`)
+		assert.Contains(t, body, `href="/artists/2" class="text-rose-800 hover:underline">Bowie</a>`)
+		assert.Contains(t, body, `href="/releases/2"`)
+		assert.Contains(t, body, `href="/releases/3"`)
+		assert.NotContains(t, body, "Definitely Maybe")
+		assert.Contains(t, body, "http://example.com/releases/1")
+	})
+
+	t.Run("GET /releases/:id with unknown id", func(t *testing.T) {
+		for _, target := range []string{"/releases/999", "/releases/abc"} {
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusNotFound, rec.Code, target)
+			assert.Contains(t, rec.Body.String(), "This release doesn&#39;t exist.", target)
+		}
+	})
+}
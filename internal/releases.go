@@ -0,0 +1,622 @@
+package internal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type Artist struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type Release struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+	Year int    `json:"year"`
+	// Barcode is digits only, empty when unknown
+	Barcode string `json:"barcode"`
+	// ReleaseDate is 1975, 1975-11 or 1975-11-21 depending on how precisely
+	// it's known, empty when only Year is
+	ReleaseDate string `json:"release_date"`
+	// MasterId is the master this release is a version of, 0 when none.
+	// Country and Formats tell versions apart, Country is empty when unknown.
+	MasterId int      `json:"master_id"`
+	Country  string   `json:"country"`
+	Formats  []Format `json:"formats"`
+	// Credit is the main and featuring credits as shown, like "Queen & David Bowie"
+	Credit  string   `json:"credit"`
+	Credits []Credit `json:"credits"`
+	// Artists are the credited artists in credit order, each listed once
+	Artists []Artist       `json:"artists"`
+	Labels  []ReleaseLabel `json:"labels"`
+	Genres  []Tag          `json:"genres"`
+	Tags    []Tag          `json:"tags"`
+	// VersionCount is how many matching versions a release stands for in
+	// search results collapsed to masters, and 0 everywhere else
+	VersionCount int `json:"version_count,omitempty"`
+}
+
+// Released is the release date as precisely as it's known, like "21 November
+// 1975", "November 1975" or "1975"
+func (r Release) Released() string {
+	if r.ReleaseDate == "" {
+		return strconv.Itoa(r.Year)
+	}
+	return formatReleaseDate(r.ReleaseDate)
+}
+
+// DatePrecision is how precisely the release date is known: year, month or day
+func (r Release) DatePrecision() string {
+	if r.ReleaseDate == "" {
+		return DatePrecisionYear
+	}
+	return releaseDatePrecision(r.ReleaseDate)
+}
+
+// FormatsString lists the formats on one line, like "2 × Vinyl, LP + CD"
+func (r Release) FormatsString() string {
+	return formatsString(r.Formats)
+}
+
+// CreditParts are the main and featuring credits with the text between them,
+// for linking each artist in the credit string
+func (r Release) CreditParts() []CreditPart {
+	return creditParts(r.Credits)
+}
+
+// CreditsWithRole returns the credits with one role, such as the producers
+func (r Release) CreditsWithRole(role string) []Credit {
+	credits := []Credit{}
+	for _, credit := range r.Credits {
+		if credit.Role == role {
+			credits = append(credits, credit)
+		}
+	}
+	return credits
+}
+
+// getReleasesByIds loads releases with their formats, credits, labels, genres
+// and tags from the base tables, returned in the order of releaseIds. Unknown
+// and repeated ids are skipped.
+func getReleasesByIds(db *sql.DB, releaseIds []int) ([]Release, error) {
+	releases := []Release{}
+	if len(releaseIds) == 0 {
+		return releases, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(releaseIds)), ",")
+	args := make([]interface{}, len(releaseIds))
+	for i, id := range releaseIds {
+		args[i] = id
+	}
+
+	rows, err := db.Query("SELECT id, name, year, release_date, COALESCE(barcode, ''), COALESCE(master_id, 0), country FROM releases WHERE id IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byId := map[int]*Release{}
+	for rows.Next() {
+		release := Release{Formats: []Format{}, Credits: []Credit{}, Artists: []Artist{}, Labels: []ReleaseLabel{}, Genres: []Tag{}, Tags: []Tag{}}
+		if err := rows.Scan(&release.Id, &release.Name, &release.Year, &release.ReleaseDate, &release.Barcode, &release.MasterId, &release.Country); err != nil {
+			return nil, err
+		}
+		byId[release.Id] = &release
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	credits, err := getReleasesCredits(db, placeholders, args)
+	if err != nil {
+		return nil, err
+	}
+	for releaseId, releaseCredits := range credits {
+		release, ok := byId[releaseId]
+		if !ok {
+			continue
+		}
+		release.Credits = releaseCredits
+		release.Credit = creditString(releaseCredits)
+
+		// Artists credited with several roles are listed once
+		listed := map[int]bool{}
+		for _, credit := range releaseCredits {
+			if !listed[credit.Id] {
+				listed[credit.Id] = true
+				release.Artists = append(release.Artists, credit.Artist)
+			}
+		}
+	}
+
+	formats, err := getReleasesFormats(db, placeholders, args)
+	if err != nil {
+		return nil, err
+	}
+	for releaseId, releaseFormats := range formats {
+		if release, ok := byId[releaseId]; ok {
+			release.Formats = releaseFormats
+		}
+	}
+
+	labelRows, err := db.Query(`
+		SELECT release_labels.release_id, labels.id, labels.name, release_labels.catno
+		FROM release_labels
+		JOIN labels ON labels.id = release_labels.label_id
+		WHERE release_labels.release_id IN (`+placeholders+`)
+		ORDER BY release_labels.release_id, release_labels.id;
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer labelRows.Close()
+
+	for labelRows.Next() {
+		var releaseId int
+		var label ReleaseLabel
+		if err := labelRows.Scan(&releaseId, &label.Id, &label.Name, &label.Catno); err != nil {
+			return nil, err
+		}
+		if release, ok := byId[releaseId]; ok {
+			release.Labels = append(release.Labels, label)
+		}
+	}
+	if err := labelRows.Err(); err != nil {
+		return nil, err
+	}
+
+	genres, err := getReleasesTerms(db, genreTaxonomy, placeholders, args)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := getReleasesTerms(db, tagTaxonomy, placeholders, args)
+	if err != nil {
+		return nil, err
+	}
+	for releaseId, release := range byId {
+		if releaseGenres, ok := genres[releaseId]; ok {
+			release.Genres = releaseGenres
+		}
+		if releaseTags, ok := tags[releaseId]; ok {
+			release.Tags = releaseTags
+		}
+	}
+
+	for _, id := range releaseIds {
+		if release, ok := byId[id]; ok {
+			releases = append(releases, *release)
+			delete(byId, id)
+		}
+	}
+
+	return releases, nil
+}
+
+// The phonograph was invented in 1877, so nothing can have been released before then
+const minReleaseYear = 1877
+
+var errNotFound = errors.New("not found")
+
+// ValidationError maps input fields to messages describing why they were rejected
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for field, message := range e.Fields {
+		messages = append(messages, field+": "+message)
+	}
+	sort.Strings(messages)
+	return "validation failed: " + strings.Join(messages, ", ")
+}
+
+type ReleaseInput struct {
+	Name string `json:"name" form:"name"`
+	// Year can be left out when ReleaseDate is given
+	Year        int    `json:"year" form:"year"`
+	ReleaseDate string `json:"release_date" form:"release_date"`
+	Barcode     string `json:"barcode" form:"barcode"`
+	// MasterId makes the release a version of an existing master. NewMaster
+	// names a master to create with the release as its first version instead.
+	MasterId  int    `json:"master_id" form:"master_id"`
+	NewMaster string `json:"new_master" form:"new_master"`
+	Country   string `json:"country" form:"country"`
+	// Formats are bound from JSON. Forms send them as format_name,
+	// format_quantity and format_descriptors rows.
+	Formats []Format `json:"formats"`
+	// ArtistIds are main credits, used when there are no Credits
+	ArtistIds []int `json:"artist_ids" form:"artist_ids"`
+	// Credits are bound from JSON. Forms send them as credit_artist_id,
+	// credit_role and credit_join_phrase rows.
+	Credits []CreditInput `json:"credits"`
+	// Labels are bound from JSON. Forms send them as label_name and catno rows.
+	Labels   []ReleaseLabelInput `json:"labels"`
+	GenreIds []int               `json:"genre_ids" form:"genre_ids"`
+	// Tags are names, split on commas. Tags are created when they're first used.
+	Tags []string `json:"tags" form:"tags"`
+}
+
+func (input *ReleaseInput) validate(tx *sql.Tx) error {
+	input.Name = strings.TrimSpace(input.Name)
+	fields := map[string]string{}
+
+	if input.Name == "" {
+		fields["name"] = "Name is required"
+	}
+
+	input.ReleaseDate = strings.TrimSpace(input.ReleaseDate)
+	if input.ReleaseDate != "" {
+		date, year, ok := parseReleaseDate(input.ReleaseDate)
+		switch {
+		case !ok:
+			fields["release_date"] = "Release date must be a year, year and month, or full date like 1975-11-21"
+		case input.Year == 0:
+			input.Year = year
+		case input.Year != year:
+			fields["release_date"] = fmt.Sprintf("Release date must be in %d", input.Year)
+		}
+		if ok {
+			input.ReleaseDate = date
+		}
+	}
+
+	maxYear := time.Now().Year() + 1
+	if input.Year < minReleaseYear || input.Year > maxYear {
+		fields["year"] = fmt.Sprintf("Year must be between %d and %d", minReleaseYear, maxYear)
+	}
+
+	input.Barcode = normalizeBarcode(input.Barcode)
+	if input.Barcode != "" && !validBarcode(input.Barcode) {
+		fields["barcode"] = "Barcode must be 8, 12, 13 or 14 digits"
+	}
+
+	input.NewMaster = strings.TrimSpace(input.NewMaster)
+	if input.MasterId != 0 && input.NewMaster != "" {
+		fields["master_id"] = "Pick a master or name a new one, not both"
+	} else if input.MasterId != 0 {
+		var exists bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM masters WHERE id = ?)", input.MasterId).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			fields["master_id"] = fmt.Sprintf("Master %d does not exist", input.MasterId)
+		}
+	}
+
+	input.Country = strings.TrimSpace(input.Country)
+	if utf8.RuneCountInString(input.Country) > maxVersionDetailLength {
+		fields["country"] = fmt.Sprintf("Country can be at most %d characters", maxVersionDetailLength)
+	}
+
+	formats, problem := normalizeFormats(input.Formats)
+	if problem != "" {
+		fields["formats"] = problem
+	} else {
+		input.Formats = formats
+	}
+
+	// Rows with neither a label nor a catalog number are blank form rows
+	labels := []ReleaseLabelInput{}
+	for _, label := range input.Labels {
+		label.Name = strings.TrimSpace(label.Name)
+		label.Catno = strings.TrimSpace(label.Catno)
+		if label.Name == "" && label.Catno == "" {
+			continue
+		}
+		if label.Name == "" {
+			fields["labels"] = fmt.Sprintf("Catalog number %s needs a label", label.Catno)
+		}
+		labels = append(labels, label)
+	}
+	input.Labels = labels
+
+	for _, artistId := range input.ArtistIds {
+		var exists bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM artists WHERE id = ?)", artistId).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			fields["artist_ids"] = fmt.Sprintf("Artist %d does not exist", artistId)
+			break
+		}
+	}
+
+	if len(input.Credits) > 0 {
+		credits, problem, err := normalizeCredits(tx, input.Credits)
+		if err != nil {
+			return err
+		}
+		if problem != "" {
+			fields["credits"] = problem
+		} else {
+			input.Credits = credits
+		}
+	}
+	if len(input.Credits) == 0 {
+		input.Credits = mainCredits(input.ArtistIds)
+	}
+
+	for _, genreId := range input.GenreIds {
+		var exists bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM genres WHERE id = ?)", genreId).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			fields["genre_ids"] = fmt.Sprintf("Genre %d does not exist", genreId)
+			break
+		}
+	}
+
+	var tagProblem string
+	input.Tags, tagProblem = normalizeTagNames(input.Tags)
+	if tagProblem != "" {
+		fields["tags"] = tagProblem
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// getRelease loads a single release and its artists
+func getRelease(db *sql.DB, releaseId int) (Release, error) {
+	releases, err := getReleasesByIds(db, []int{releaseId})
+	if err != nil {
+		return Release{}, err
+	}
+	if len(releases) == 0 {
+		return Release{}, errNotFound
+	}
+	return releases[0], nil
+}
+
+// setReleaseArtists credits artists on a release as its main artists,
+// replacing its credits
+func setReleaseArtists(tx *sql.Tx, releaseId int, artistIds []int) error {
+	return setReleaseCredits(tx, releaseId, mainCredits(artistIds))
+}
+
+// masterId returns the master the release is a version of, creating the
+// master named by NewMaster. It's 0 when the release has no master.
+func (input *ReleaseInput) masterId(tx *sql.Tx) (int, error) {
+	if input.NewMaster != "" {
+		return createMaster(tx, input.NewMaster)
+	}
+	return input.MasterId, nil
+}
+
+func createRelease(db *sql.DB, input ReleaseInput) (Release, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return Release{}, err
+	}
+	defer tx.Rollback()
+
+	if err := input.validate(tx); err != nil {
+		return Release{}, err
+	}
+
+	masterId, err := input.masterId(tx)
+	if err != nil {
+		return Release{}, err
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO releases (name, year, release_date, barcode, master_id, country) VALUES (?, ?, ?, NULLIF(?, ''), NULLIF(?, 0), ?)",
+		input.Name, input.Year, input.ReleaseDate, input.Barcode, masterId, input.Country,
+	)
+	if err != nil {
+		return Release{}, err
+	}
+
+	releaseId, err := result.LastInsertId()
+	if err != nil {
+		return Release{}, err
+	}
+
+	if err := setReleaseFormats(tx, int(releaseId), input.Formats); err != nil {
+		return Release{}, err
+	}
+	if err := setReleaseCredits(tx, int(releaseId), input.Credits); err != nil {
+		return Release{}, err
+	}
+	if err := setReleaseLabels(tx, int(releaseId), input.Labels); err != nil {
+		return Release{}, err
+	}
+	if err := setReleaseTerms(tx, genreTaxonomy, int(releaseId), input.GenreIds); err != nil {
+		return Release{}, err
+	}
+	if err := setReleaseTagNames(tx, int(releaseId), input.Tags); err != nil {
+		return Release{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Release{}, err
+	}
+
+	return getRelease(db, int(releaseId))
+}
+
+func updateRelease(db *sql.DB, releaseId int, input ReleaseInput) (Release, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return Release{}, err
+	}
+	defer tx.Rollback()
+
+	if err := input.validate(tx); err != nil {
+		return Release{}, err
+	}
+
+	masterId, err := input.masterId(tx)
+	if err != nil {
+		return Release{}, err
+	}
+
+	result, err := tx.Exec(
+		"UPDATE releases SET name = ?, year = ?, release_date = ?, barcode = NULLIF(?, ''), master_id = NULLIF(?, 0), country = ? WHERE id = ?",
+		input.Name, input.Year, input.ReleaseDate, input.Barcode, masterId, input.Country, releaseId,
+	)
+	if err != nil {
+		return Release{}, err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return Release{}, err
+	} else if affected == 0 {
+		return Release{}, errNotFound
+	}
+
+	if err := setReleaseFormats(tx, releaseId, input.Formats); err != nil {
+		return Release{}, err
+	}
+	if err := setReleaseCredits(tx, releaseId, input.Credits); err != nil {
+		return Release{}, err
+	}
+	if err := setReleaseLabels(tx, releaseId, input.Labels); err != nil {
+		return Release{}, err
+	}
+	if err := setReleaseTerms(tx, genreTaxonomy, releaseId, input.GenreIds); err != nil {
+		return Release{}, err
+	}
+	if err := setReleaseTagNames(tx, releaseId, input.Tags); err != nil {
+		return Release{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Release{}, err
+	}
+
+	return getRelease(db, releaseId)
+}
+
+func deleteRelease(db *sql.DB, releaseId int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM release_artists WHERE release_id = ?", releaseId); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("DELETE FROM releases WHERE id = ?", releaseId)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return errNotFound
+	}
+
+	return tx.Commit()
+}
+
+// Maximum number of related releases shown with a release
+const relatedReleasesLimit = 20
+
+type ReleaseDetail struct {
+	Release
+	Tracks []Track `json:"tracks"`
+	// Master is the master the release is a version of, nil when none, and
+	// Versions are its other versions
+	Master          *Master   `json:"master"`
+	Versions        []Release `json:"versions"`
+	RelatedReleases []Release `json:"related_releases"`
+}
+
+// Discs groups the tracklist by disc
+func (d ReleaseDetail) Discs() []Disc {
+	return groupDiscs(d.Tracks)
+}
+
+// getReleaseDetail loads a release with its artists, its tracklist, the other
+// versions of its master and other releases by the same artists
+func getReleaseDetail(db *sql.DB, releaseId int) (ReleaseDetail, error) {
+	release, err := getRelease(db, releaseId)
+	if err != nil {
+		return ReleaseDetail{}, err
+	}
+
+	tracks, err := getReleaseTracks(db, releaseId)
+	if err != nil {
+		return ReleaseDetail{}, err
+	}
+
+	detail := ReleaseDetail{Release: release, Tracks: tracks, Versions: []Release{}}
+	if release.MasterId != 0 {
+		master, err := getMaster(db, release.MasterId)
+		if err != nil {
+			return ReleaseDetail{}, err
+		}
+		versionIds, err := getMasterVersionIds(db, release.MasterId, releaseId)
+		if err != nil {
+			return ReleaseDetail{}, err
+		}
+		detail.Versions, err = getReleasesByIds(db, versionIds)
+		if err != nil {
+			return ReleaseDetail{}, err
+		}
+		detail.Master = &master
+	}
+
+	detail.RelatedReleases, err = getRelatedReleases(db, releaseId, relatedReleasesLimit)
+	if err != nil {
+		return ReleaseDetail{}, err
+	}
+
+	return detail, nil
+}
+
+// getRelatedReleases returns other releases that share at least one artist with
+// the given release, oldest first. Versions of the release's master are left
+// out, they're listed as versions.
+func getRelatedReleases(db *sql.DB, releaseId int, limit int) ([]Release, error) {
+	rows, err := db.Query(`
+		SELECT DISTINCT releases.id, releases.year, releases.name
+		FROM release_artists AS credited
+		JOIN release_artists AS other ON other.artist_id = credited.artist_id
+		JOIN releases ON releases.id = other.release_id
+		WHERE credited.release_id = ?
+		  AND other.release_id != credited.release_id
+		  AND (releases.master_id IS NULL OR releases.master_id != COALESCE(
+			(SELECT master_id FROM releases AS this WHERE this.id = credited.release_id), 0))
+		ORDER BY releases.year, releases.name, releases.id
+		LIMIT ?;
+	`, releaseId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var relatedIds []int
+	for rows.Next() {
+		var id, year int
+		var name string
+		if err := rows.Scan(&id, &year, &name); err != nil {
+			return nil, err
+		}
+		relatedIds = append(relatedIds, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return getReleasesByIds(db, relatedIds)
+}
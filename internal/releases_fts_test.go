@@ -0,0 +1,211 @@
+package internal
+
+import (
+	"database/sql"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+// openMigratedTestDB opens an in-memory database with every migration applied,
+// including the triggers that keep releases_fts in sync
+func openMigratedTestDB(t testing.TB) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory database: %v", err)
+	}
+
+	// Each connection to :memory: is a separate database
+	db.SetMaxOpenConns(1)
+
+	_, testFilePath, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatalf("Failed to get test file path")
+	}
+	if err := RunMigrations(db, filepath.Join(filepath.Dir(testFilePath), "../migrations")); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	t.Cleanup(func() { cleanupTestDB(db) })
+	return db
+}
+
+func mustExec(t testing.TB, db *sql.DB, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("Failed to execute %q: %v", query, err)
+	}
+}
+
+func mustParseSearchQuery(t testing.TB, searchQuery string) SearchQuery {
+	t.Helper()
+	query, err := ParseSearchQuery(searchQuery)
+	if err != nil {
+		t.Fatalf("Failed to parse %q: %v", searchQuery, err)
+	}
+	return query
+}
+
+// searchCredits returns the artist credit of every release matching searchQuery
+func searchCredits(t *testing.T, db *sql.DB, searchQuery string) []string {
+	t.Helper()
+	releases, err := getReleases(db, 100, 0, mustParseSearchQuery(t, searchQuery), "", nil)
+	if err != nil {
+		t.Fatalf("Failed to fetch releases: %v", err)
+	}
+
+	names := []string{}
+	for _, release := range releases {
+		names = append(names, release["artist_credit"].(string))
+	}
+	return names
+}
+
+func TestReleasesFtsSync(t *testing.T) {
+	t.Run("Release Without Artists Is Indexed", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Night Shift', 1990)")
+
+		assert.Equal(t, []string{""}, searchCredits(t, db, "Night Shift"))
+	})
+
+	t.Run("Release Insert Does Not Reindex Other Releases", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Night Shift', 1990)")
+		mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen')")
+		mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1)")
+		mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (2, 'Day Shift', 1991)")
+
+		assert.ElementsMatch(t, []string{"Queen", ""}, searchCredits(t, db, "Shift"))
+	})
+
+	t.Run("Linking Artists Indexes One Row Per Release", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Night Shift', 1990)")
+		mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'Bowie')")
+		mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1)")
+		mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 2)")
+		mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id, role) VALUES (1, 2, 'producer')")
+
+		assert.Equal(t, []string{"Queen, Bowie"}, searchCredits(t, db, "Night Shift"))
+		assert.Equal(t, 1, countRows(t, db, "releases_fts"))
+	})
+
+	t.Run("Release Update", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Night Shift', 1990)")
+		mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (2, 'Night Shift', 1990)")
+		mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'Bowie')")
+		mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1), (2, 2)")
+
+		mustExec(t, db, "UPDATE releases SET name = 'Day Shift', year = 1991 WHERE id = 1")
+
+		assert.Equal(t, []string{"Queen"}, searchCredits(t, db, "Day Shift"))
+		assert.Equal(t, []string{"Bowie"}, searchCredits(t, db, "Night Shift"))
+
+		releases, err := getReleases(db, 10, 0, mustParseSearchQuery(t, "Day Shift"), "", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "1991", releases[0]["release_year"])
+	})
+
+	t.Run("Release Delete", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Night Shift', 1990)")
+		mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen')")
+		mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1)")
+
+		mustExec(t, db, "DELETE FROM releases WHERE id = 1")
+
+		assert.Empty(t, searchCredits(t, db, "Queen"))
+	})
+
+	t.Run("Release Artist Update", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Night Shift', 1990), (2, 'Day Shift', 1991)")
+		mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'Bowie')")
+		mustExec(t, db, "INSERT INTO release_artists (id, release_id, artist_id) VALUES (1, 1, 1)")
+
+		mustExec(t, db, "UPDATE release_artists SET release_id = 2, artist_id = 2 WHERE id = 1")
+
+		// Night Shift is still listed, now without artists
+		assert.Equal(t, []string{""}, searchCredits(t, db, "Night Shift"))
+		assert.Empty(t, searchCredits(t, db, "Queen"))
+		assert.Equal(t, []string{"Bowie"}, searchCredits(t, db, "Day Shift"))
+	})
+
+	t.Run("Release Artist Delete", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Night Shift', 1990)")
+		mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'Bowie')")
+		mustExec(t, db, "INSERT INTO release_artists (id, release_id, artist_id) VALUES (1, 1, 1), (2, 1, 2)")
+
+		mustExec(t, db, "DELETE FROM release_artists WHERE id = 2")
+
+		assert.Equal(t, []string{"Queen"}, searchCredits(t, db, "Night Shift"))
+	})
+
+	t.Run("Artist Update", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Night Shift', 1990), (2, 'Day Shift', 1991)")
+		mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'Queen')")
+		mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1), (2, 2)")
+
+		mustExec(t, db, "UPDATE artists SET name = 'Bowie' WHERE id = 1")
+
+		assert.Equal(t, []string{"Bowie"}, searchCredits(t, db, "Night Shift"))
+		assert.Equal(t, []string{"Queen"}, searchCredits(t, db, "Day Shift"))
+	})
+
+	t.Run("Artist Delete", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Night Shift', 1990)")
+		mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'Bowie')")
+		mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1), (1, 2)")
+
+		mustExec(t, db, "DELETE FROM artists WHERE id = 1")
+
+		assert.Equal(t, []string{"Bowie"}, searchCredits(t, db, "Night Shift"))
+	})
+
+	t.Run("Rows Are Keyed By Release Id", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (7, 'Night Shift', 1990), (9, 'Day Shift', 1991)")
+		mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen')")
+		mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (7, 1), (9, 1)")
+		mustExec(t, db, "UPDATE releases SET name = 'Late Shift' WHERE id = 7")
+
+		var mismatched int
+		if err := db.QueryRow("SELECT COUNT(*) FROM releases_fts WHERE rowid != release_id").Scan(&mismatched); err != nil {
+			t.Fatalf("Failed to compare rowids: %v", err)
+		}
+		assert.Equal(t, 0, mismatched)
+		assert.Equal(t, 2, countRows(t, db, "releases_fts"))
+
+		if err := ReindexFts(db); err != nil {
+			t.Fatalf("Failed to reindex: %v", err)
+		}
+		if err := db.QueryRow("SELECT COUNT(*) FROM releases_fts WHERE rowid != release_id").Scan(&mismatched); err != nil {
+			t.Fatalf("Failed to compare rowids: %v", err)
+		}
+		assert.Equal(t, 0, mismatched)
+
+		// Excluded terms are matched by rowid too
+		assert.Equal(t, []string{"Queen"}, searchCredits(t, db, "shift -late"))
+	})
+
+	t.Run("Seed Does Not Duplicate Rows", func(t *testing.T) {
+		db := openMigratedTestDB(t)
+		if err := SeedDB(db); err != nil {
+			t.Fatalf("Failed to seed database: %v", err)
+		}
+
+		count, err := getReleasesCount(db, SearchQuery{})
+		assert.NoError(t, err)
+		assert.Equal(t, 30, count)
+	})
+}
@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetReleasesByIds(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	createTestTables(db)
+	seedTestReleases(db)
+	seedTestArtists(db)
+	seedTestReleaseArtists(db)
+
+	// Credit a second artist on release 2
+	_, err = db.Exec("INSERT INTO release_artists (release_id, artist_id) VALUES (2, 3)")
+	if err != nil {
+		t.Fatalf("Failed to link artist: %v", err)
+	}
+
+	t.Run("Keeps Requested Order", func(t *testing.T) {
+		releases, err := getReleasesByIds(db, []int{3, 1, 2})
+		assert.NoError(t, err)
+		assert.Len(t, releases, 3)
+		assert.Equal(t, 3, releases[0].Id)
+		assert.Equal(t, 1, releases[1].Id)
+		assert.Equal(t, 2, releases[2].Id)
+	})
+
+	t.Run("Loads All Artists", func(t *testing.T) {
+		releases, err := getReleasesByIds(db, []int{2})
+		assert.NoError(t, err)
+		assert.Equal(t, []Artist{{Id: 2, Name: "Artist 2"}, {Id: 3, Name: "Artist 3"}}, releases[0].Artists)
+	})
+
+	t.Run("Skips Unknown And Repeated Ids", func(t *testing.T) {
+		releases, err := getReleasesByIds(db, []int{2, 2, 999})
+		assert.NoError(t, err)
+		assert.Len(t, releases, 1)
+	})
+
+	t.Run("No Ids", func(t *testing.T) {
+		releases, err := getReleasesByIds(db, nil)
+		assert.NoError(t, err)
+		assert.Empty(t, releases)
+	})
+}
+
+func TestReleaseCrud(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'Bowie')")
+
+	release, err := createRelease(db, ReleaseInput{Name: "  Hot Space ", Year: 1982, ArtistIds: []int{1, 2, 2}})
+	assert.NoError(t, err)
+	assert.Equal(t, "Hot Space", release.Name)
+	assert.Equal(t, 1982, release.Year)
+	assert.Equal(t, []Artist{{Id: 1, Name: "Queen"}, {Id: 2, Name: "Bowie"}}, release.Artists)
+	assert.Equal(t, []string{"Queen, Bowie"}, searchCredits(t, db, "Hot Space"))
+
+	t.Run("Update", func(t *testing.T) {
+		updated, err := updateRelease(db, release.Id, ReleaseInput{Name: "Hot Space (Remaster)", Year: 2011, ArtistIds: []int{1}})
+		assert.NoError(t, err)
+		assert.Equal(t, "Hot Space (Remaster)", updated.Name)
+		assert.Equal(t, []Artist{{Id: 1, Name: "Queen"}}, updated.Artists)
+		assert.Equal(t, []string{"Queen"}, searchCredits(t, db, "Remaster"))
+	})
+
+	t.Run("Update Unknown Release", func(t *testing.T) {
+		_, err := updateRelease(db, 999, ReleaseInput{Name: "Nothing", Year: 2000})
+		assert.ErrorIs(t, err, errNotFound)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.NoError(t, deleteRelease(db, release.Id))
+		assert.Empty(t, searchCredits(t, db, "Hot Space"))
+
+		_, err := getRelease(db, release.Id)
+		assert.ErrorIs(t, err, errNotFound)
+		assert.ErrorIs(t, deleteRelease(db, release.Id), errNotFound)
+	})
+}
+
+func TestReleaseValidation(t *testing.T) {
+	db := openMigratedTestDB(t)
+
+	tests := []struct {
+		name  string
+		input ReleaseInput
+		field string
+	}{
+		{"Blank Name", ReleaseInput{Name: "   ", Year: 1990}, "name"},
+		{"Year Too Early", ReleaseInput{Name: "Wax Cylinder", Year: 1800}, "year"},
+		{"Year Too Late", ReleaseInput{Name: "From The Future", Year: 3000}, "year"},
+		{"Missing Year", ReleaseInput{Name: "Undated"}, "year"},
+		{"Unknown Artist", ReleaseInput{Name: "Ghosts", Year: 1990, ArtistIds: []int{42}}, "artist_ids"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := createRelease(db, test.input)
+
+			var validationErr *ValidationError
+			if assert.ErrorAs(t, err, &validationErr) {
+				assert.Contains(t, validationErr.Fields, test.field)
+			}
+		})
+	}
+}
+
+func TestGetReleaseDetail(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'Bowie')")
+	mustExec(t, db, `INSERT INTO releases (id, name, year) VALUES
+		(1, 'Under Pressure', 1981),
+		(2, 'Hot Space', 1982),
+		(3, 'Heroes', 1977),
+		(4, 'The Game', 1980)`)
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1), (1, 2), (2, 1), (3, 2), (4, 1)")
+
+	detail, err := getReleaseDetail(db, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Under Pressure", detail.Name)
+	assert.Len(t, detail.Artists, 2)
+
+	// Related releases are shared by either artist, listed once each, oldest first
+	var relatedIds []int
+	for _, release := range detail.RelatedReleases {
+		relatedIds = append(relatedIds, release.Id)
+	}
+	assert.Equal(t, []int{3, 4, 2}, relatedIds)
+
+	_, err = getReleaseDetail(db, 999)
+	assert.ErrorIs(t, err, errNotFound)
+}
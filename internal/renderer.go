@@ -25,11 +25,14 @@ func (t *Template) Render(w io.Writer, name string, data interface{}, c echo.Con
 	} else {
 		// Load base template and content template
 		tmpl, err = template.ParseFiles(
-			filepath.Join(t.TemplateDir, "base.html"),             // Base layout
-			filepath.Join(t.TemplateDir, "nav.html"),              // Nav template
-			filepath.Join(t.TemplateDir, "releases_partial.html"), // Include releases_partial
-			filepath.Join(t.TemplateDir, name+".html"),            // Content file
+			filepath.Join(t.TemplateDir, "base.html"),  // Base layout
+			filepath.Join(t.TemplateDir, "nav.html"),   // Nav template
+			filepath.Join(t.TemplateDir, name+".html"), // Content file
 		)
+		if err == nil {
+			// Include partials such as releases_partial so pages can embed them
+			tmpl, err = tmpl.ParseGlob(filepath.Join(t.TemplateDir, "*_partial.html"))
+		}
 	}
 
 	if err != nil {
@@ -2,13 +2,14 @@ package internal
 
 import (
 	"database/sql"
+	"errors"
 	"github.com/labstack/echo/v4"
 	"net/http"
 )
 
-func SetupRoutes(e *echo.Echo, db *sql.DB) {
+func SetupRoutes(e *echo.Echo, db *sql.DB, config Config) {
 	// Serve static files
-	e.Static("/static", "static")
+	e.Static("/static", config.StaticDir)
 
 	// Define routes
 	e.GET("/", func(c echo.Context) error {
@@ -39,33 +40,116 @@ func SetupRoutes(e *echo.Echo, db *sql.DB) {
 		// Read query parameters
 		pageStr := c.QueryParam("page")
 		limitStr := c.QueryParam("page_size")
-		searchQuery := c.QueryParam("q")
+		searchQuery, err := releaseSearchParams(c)
 
-		// Get releases with pagination and search
-		releases, pagination, err := getPaginatedReleases(db, pageStr, limitStr, searchQuery, e.Logger, c.Request())
+		// Invalid searches show the problem in place of the results
+		status := http.StatusOK
+		searchError := ""
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			status = invalidFormStatus(c)
+			searchError = validationErr.Fields["q"]
+		}
+		sort := releaseSort(c.QueryParam("sort"), searchQuery)
 
-		if err != nil {
-			e.Logger.Printf("Failed to get releases: %v", err)
-			return c.String(http.StatusInternalServerError, "Failed to load releases")
+		// Get releases with pagination, search and facet counts
+		var releases []map[string]interface{}
+		var pagination Pagination
+		facets := ReleaseFacets{}
+		if searchError == "" {
+			releases, pagination, err = getPaginatedReleases(db, pageStr, limitStr, config.DefaultPageSize, searchQuery, sort, e.Logger, c.Request())
+			if err == nil {
+				facets, err = getReleaseFacets(db, searchQuery)
+			}
+			if err != nil {
+				e.Logger.Printf("Failed to get releases: %v", err)
+				return c.String(http.StatusInternalServerError, "Failed to load releases")
+			}
+		} else {
+			pagination, _ = getPagination(pageStr, limitStr, config.DefaultPageSize, 0, c.Request())
 		}
 
-		// Render appropriate template (full page or HTMX partial)
-		if c.Request().Header.Get("HX-Request") == "true" {
-			return c.Render(http.StatusOK, "releases_partial", map[string]interface{}{
-				"Releases":   releases,
-				"Pagination": pagination,
-			})
+		// Column headers link to the list sorted by that column. Year toggles
+		// between oldest and newest first.
+		yearSort := SortYear
+		if sort == SortYear {
+			yearSort = SortYearDesc
+		}
+		sortUrls := map[string]string{
+			"relevance": sortUrl(c.Request(), SortRelevance),
+			"name":      sortUrl(c.Request(), SortName),
+			"year":      sortUrl(c.Request(), yearSort),
+			"artist":    sortUrl(c.Request(), SortArtist),
+		}
+		ranked := releaseSort(SortRelevance, searchQuery) == SortRelevance
+
+		// Only a sort picked by the user is kept when the search changes, so
+		// new searches are sorted by relevance by default
+		selectedSort := ""
+		if _, ok := releaseSortOrders[c.QueryParam("sort")]; ok {
+			selectedSort = c.QueryParam("sort")
 		}
 
 		data := map[string]interface{}{
-			"Title":        "Releases",
 			"Releases":     releases,
-			"Page":         pageStr,
 			"Pagination":   pagination,
-			"IncludeHTMX":  true,
-			"CurrentRoute": c.Request().URL.Path,
+			"SearchError":  searchError,
+			"Sort":         sort,
+			"SelectedSort": selectedSort,
+			"SortUrls":     sortUrls,
+			"Ranked":       ranked,
+			"Facets":       facets.withUrls(c.Request()),
+			"Decade":       searchQuery.Decade,
+			"Artist":       searchQuery.Artist,
+			"ClearDecade":  filterUrl(c.Request(), "decade", ""),
+			"ClearArtist":  filterUrl(c.Request(), "artist", ""),
+			"Collapsed":    searchQuery.CollapseMasters,
+			"CollapseUrl":  filterUrl(c.Request(), "collapse", "masters"),
+			"ExpandUrl":    filterUrl(c.Request(), "collapse", ""),
+			"CsvUrl":       exportUrl(c.Request(), "/releases.csv"),
+		}
+
+		// Render appropriate template (full page or HTMX partial)
+		if c.Request().Header.Get("HX-Request") == "true" {
+			return c.Render(status, "releases_partial", data)
+		}
+
+		data["Title"] = "Releases"
+		data["Page"] = pageStr
+		data["Query"] = c.QueryParam("q")
+		data["IncludeHTMX"] = true
+		data["CurrentRoute"] = c.Request().URL.Path
+
+		return c.Render(status, "releases", data)
+	})
+
+	// Every release in the list as CSV, streamed so large catalogs aren't held in memory
+	e.GET("/releases.csv", func(c echo.Context) error {
+		searchQuery, err := releaseSearchParams(c)
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			return c.String(http.StatusUnprocessableEntity, validationErr.Fields["q"])
 		}
+		sort := releaseSort(c.QueryParam("sort"), searchQuery)
+
+		c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+		c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="releases.csv"`)
+		c.Response().WriteHeader(http.StatusOK)
 
-		return c.Render(http.StatusOK, "releases", data)
+		// The status is already sent, so a failure part way can only be logged
+		if _, err := ExportCSV(db, c.Response(), searchQuery, sort); err != nil {
+			e.Logger.Printf("Failed to export releases: %v", err)
+		}
+		return nil
 	})
+
+	setupReleaseRoutes(e, db)
+	setupTrackRoutes(e, db)
+	setupArtistRoutes(e, db, config)
+	setupLabelRoutes(e, db, config)
+	setupMasterRoutes(e, db)
+	setupTagRoutes(e, db, config)
+	setupTagAdminRoutes(e, db)
+	setupSearchRoutes(e, db)
+	setupApiRoutes(e, db, config)
 }
@@ -26,7 +26,7 @@ func TestRoutes(t *testing.T) {
 	seedTestArtists(db)
 	seedTestReleaseArtists(db)
 	populateReleasesFtsTable(db)
-	SetupRoutes(e, db)
+	SetupRoutes(e, db, DefaultConfig())
 
 	t.Run("GET /", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -47,6 +47,139 @@ func TestRoutes(t *testing.T) {
 		assert.Contains(t, rec.Body.String(), "1991")
 	})
 
+	t.Run("GET /releases sorted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/releases?q=album&sort=year&page=2", nil)
+		req.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		body := rec.Body.String()
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, body, `<input type="hidden" name="sort" value="year">`)
+		assert.Contains(t, body, `aria-sort="ascending"`)
+		assert.Contains(t, body, `href="/releases?q=album&amp;sort=year_desc"`)
+		assert.Contains(t, body, `href="/releases?q=album&amp;sort=name"`)
+		assert.Contains(t, body, `data-hx-get="/releases?page=3&amp;q=album&amp;sort=year"`)
+		assert.Contains(t, body, "Sort by relevance")
+		assert.Contains(t, body, "Album 11")
+	})
+
+	t.Run("GET /releases search sorted by relevance", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/releases?q=album", nil)
+		req.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		body := rec.Body.String()
+		assert.Contains(t, body, "Sorted by relevance")
+		assert.Contains(t, body, `<a href="/releases/1" class="text-rose-800 hover:underline"><mark>Album</mark> 1</a>`)
+		assert.NotContains(t, body, `name="sort"`)
+		assert.NotContains(t, body, "aria-sort")
+	})
+
+	t.Run("GET /releases with a cursor", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/releases?sort=year&after=", nil)
+		req.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		body := rec.Body.String()
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, body, "Showing 10 results")
+		assert.Contains(t, body, `data-hx-get="/releases?after=`)
+		assert.NotContains(t, body, "Page 1 of")
+
+		// Changing the sort starts again from the first page
+		assert.Contains(t, body, `href="/releases?sort=year_desc"`)
+	})
+
+	t.Run("GET /releases with facets", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/releases?q=album", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		body := rec.Body.String()
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, body, `href="/releases?decade=1990&amp;q=album"`)
+		assert.Contains(t, body, "<span>1990s</span><span class=\"text-gray-500\">9</span>")
+		assert.Contains(t, body, `href="/releases?artist=Artist&#43;1&amp;q=album"`)
+		assert.Contains(t, body, `hx-include="#release-filters"`)
+	})
+
+	t.Run("GET /releases narrowed by facets", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/releases?q=album&decade=2000&artist=Artist+12", nil)
+		req.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		body := rec.Body.String()
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, body, "Album 12")
+		assert.NotContains(t, body, "Album 11")
+		assert.Contains(t, body, `<input type="hidden" name="decade" value="2000">`)
+		assert.Contains(t, body, `<input type="hidden" name="artist" value="Artist 12">`)
+		assert.Contains(t, body, `href="/releases?artist=Artist&#43;12&amp;q=album"`)
+		assert.Contains(t, body, `href="/releases?decade=2000&amp;q=album"`)
+	})
+
+	t.Run("GET /releases with invalid decade", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/releases?decade=1995", nil)
+		req.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Album 1<")
+		assert.NotContains(t, rec.Body.String(), `name="decade"`)
+	})
+
+	t.Run("GET /releases with invalid search", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/releases?q=%22Album", nil)
+		req.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Missing closing quote")
+		assert.NotContains(t, rec.Body.String(), "Album 1")
+
+		req = httptest.NewRequest(http.MethodGet, "/releases?q=mood:calm", nil)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Unknown filter &#34;mood:&#34;")
+		assert.Contains(t, rec.Body.String(), `value="mood:calm"`)
+	})
+
+	t.Run("GET /releases links to the CSV export", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/releases?q=album&sort=year&page=2", nil)
+		req.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `href="/releases.csv?q=album&amp;sort=year" download`)
+	})
+
+	t.Run("GET /releases.csv", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/releases.csv?q=year:1991..1992&sort=year_desc", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="releases.csv"`, rec.Header().Get("Content-Disposition"))
+		assert.Equal(t, "id,name,year,artists\n2,Album 2,1992,Artist 2\n1,Album 1,1991,Artist 1\n", rec.Body.String())
+
+		req = httptest.NewRequest(http.MethodGet, "/releases.csv?q=mood:calm", nil)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Contains(t, rec.Body.String(), `Unknown filter "mood:"`)
+	})
+
 	t.Run("Invalid Route", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/invalid", nil)
 		rec := httptest.NewRecorder()
@@ -87,18 +220,9 @@ func seedTestReleaseArtists(db *sql.DB) {
 
 func populateReleasesFtsTable(db *sql.DB) {
 	stmt, err := db.Prepare(`
-		INSERT INTO releases_fts (release_id, artist_name, release_name, release_year)
-		SELECT
-			releases.id AS release_id,
-			artists.name AS artist_name,
-			releases.name AS release_name,
-			releases.year AS release_year
-		FROM
-			release_artists
-				JOIN
-			artists ON release_artists.artist_id = artists.id
-				JOIN
-			releases ON release_artists.release_id = releases.id;
+		INSERT INTO releases_fts (rowid, release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+		SELECT release_id, release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit
+		FROM releases_fts_rows;
 	`)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to prepare statement: %v", err))
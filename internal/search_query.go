@@ -0,0 +1,365 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxSearchQueryLength keeps pathological queries from building huge SQL statements
+const maxSearchQueryLength = 500
+
+// searchFields maps the field names accepted in queries to releases_fts
+// columns. artist: matches the name of any credited artist and the credit as
+// shown, so artist:"queen & david bowie" finds that credit.
+var searchFields = map[string][]string{
+	"artist":  {"artist_names", "artist_credit"},
+	"release": {"release_name"},
+	"track":   {"track_titles"},
+	"label":   {"label_names"},
+}
+
+// lookupFields are matched exactly against indexed columns of the base tables
+// instead of the trigram index: barcode: against releases.barcode, catno:
+// against release_labels.catno_key, genre: and tag: against slugs, country:
+// against releases.country and format: against release_formats
+var lookupFields = map[string]bool{
+	"barcode": true,
+	"catno":   true,
+	"genre":   true,
+	"tag":     true,
+	"country": true,
+	"format":  true,
+}
+
+// SearchQuery is a parsed releases search such as
+// `artist:queen year:1990..1995 "exact phrase" -live`
+type SearchQuery struct {
+	Terms []SearchTerm
+
+	// YearFrom and YearTo bound the release year, 0 means unbounded
+	YearFrom int
+	YearTo   int
+
+	// Decade and Artist are facets picked on the releases page. Decade is
+	// the first year of a decade and Artist matches the name of a credited
+	// artist exactly.
+	Decade int
+	Artist string
+
+	// CollapseMasters lists one release for each master, the first of its
+	// matching versions in sort order
+	CollapseMasters bool
+}
+
+// SearchTerm is a word or quoted phrase, optionally limited to one field
+type SearchTerm struct {
+	Field   string
+	Text    string
+	Exclude bool
+}
+
+func searchQueryError(format string, args ...interface{}) error {
+	return &ValidationError{Fields: map[string]string{"q": fmt.Sprintf(format, args...)}}
+}
+
+// ParseSearchQuery parses the q parameter of the releases search. Words and
+// "quoted phrases" must all match, -term excludes releases matching term,
+// artist:, release:, track: and label: limit a term to one field, barcode:
+// and catno: look up an exact barcode or catalog number, genre: and tag:
+// match releases filed under a genre or tag, country: and format: match the
+// country and formats of releases, where format: takes a format like vinyl or
+// a descriptor like LP, and year: takes a year or a range like 1990..1995,
+// 1990.. or ..1995. Invalid queries return a *ValidationError for the q field.
+func ParseSearchQuery(input string) (SearchQuery, error) {
+	var query SearchQuery
+	if len(input) > maxSearchQueryLength {
+		return query, searchQueryError("Search must be at most %d characters", maxSearchQueryLength)
+	}
+	if !utf8.ValidString(input) {
+		return query, searchQueryError("Search contains invalid characters")
+	}
+
+	rest := replaceControlCharacters(input)
+	for {
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		if rest == "" {
+			break
+		}
+
+		exclude := false
+		if rest[0] == '-' {
+			exclude = true
+			rest = rest[1:]
+		}
+
+		// Split off field:value when the text before the colon is a plain word
+		field := ""
+		if colon := strings.IndexByte(rest, ':'); colon > 0 && isFieldName(rest[:colon]) {
+			field = strings.ToLower(rest[:colon])
+			rest = rest[colon+1:]
+			if _, ok := searchFields[field]; !ok && !lookupFields[field] && field != "year" {
+				return SearchQuery{}, searchQueryError("Unknown filter %q, use artist:, release:, track:, label:, genre:, tag:, catno:, barcode:, country:, format: or year:", field+":")
+			}
+		}
+
+		text, remaining, quoted, err := readSearchValue(rest)
+		if err != nil {
+			return SearchQuery{}, err
+		}
+		rest = remaining
+
+		if field == "year" {
+			if exclude {
+				return SearchQuery{}, searchQueryError("year: can't be excluded, use a range like year:1990..1995")
+			}
+			if err := query.addYearRange(text); err != nil {
+				return SearchQuery{}, err
+			}
+			continue
+		}
+
+		if text == "" {
+			if field != "" && !quoted {
+				return SearchQuery{}, searchQueryError("%s: needs a value", field)
+			}
+			continue
+		}
+		term := SearchTerm{Field: field, Text: text, Exclude: exclude}
+		if term.isLookup() {
+			if _, args := term.lookupCondition(); args[0] == "" {
+				return SearchQuery{}, searchQueryError("%s: needs letters or digits", field)
+			}
+		}
+		query.Terms = append(query.Terms, term)
+	}
+
+	if query.YearFrom != 0 && query.YearTo != 0 && query.YearFrom > query.YearTo {
+		return SearchQuery{}, searchQueryError("Year range must start before it ends")
+	}
+	return query, nil
+}
+
+// replaceControlCharacters replaces control characters with spaces. They can't
+// appear in FTS5 strings, so they separate terms like spaces.
+func replaceControlCharacters(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+func isFieldName(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII || !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// readSearchValue reads a word or a quoted phrase from the start of s and
+// returns it with the rest of s
+func readSearchValue(s string) (value string, rest string, quoted bool, err error) {
+	if strings.HasPrefix(s, `"`) {
+		end := strings.IndexByte(s[1:], '"')
+		if end < 0 {
+			return "", "", true, searchQueryError("Missing closing quote")
+		}
+		return strings.TrimSpace(s[1 : end+1]), s[end+2:], true, nil
+	}
+
+	end := strings.IndexFunc(s, unicode.IsSpace)
+	if end < 0 {
+		end = len(s)
+	}
+	return s[:end], s[end:], false, nil
+}
+
+// addYearRange narrows the query to a year or a range of years
+func (q *SearchQuery) addYearRange(value string) error {
+	fromStr, toStr, isRange := strings.Cut(value, "..")
+	if !isRange {
+		toStr = fromStr
+	}
+	if fromStr == "" && toStr == "" {
+		return searchQueryError("year: needs a year or a range like 1990..1995")
+	}
+
+	from, err := parseSearchYear(fromStr)
+	if err != nil {
+		return err
+	}
+	to, err := parseSearchYear(toStr)
+	if err != nil {
+		return err
+	}
+
+	if from != 0 && from > q.YearFrom {
+		q.YearFrom = from
+	}
+	if to != 0 && (q.YearTo == 0 || to < q.YearTo) {
+		q.YearTo = to
+	}
+	return nil
+}
+
+func parseSearchYear(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	year, err := strconv.Atoi(s)
+	if err != nil || year < 1 || year > 9999 || strings.HasPrefix(s, "+") {
+		return 0, searchQueryError("%q is not a year", s)
+	}
+	return year, nil
+}
+
+// filter compiles the query to a WHERE clause on releases_fts. Terms of 3 or
+// more characters use the trigram index. Shorter terms can't be matched by
+// the index, so they fall back to LIKE. Barcode, catalog number, genre and
+// tag lookups select release ids by indexed equality on the base tables.
+// Release ids are compared with the rowid of releases_fts, which is the
+// release id, so matching rows are looked up rather than scanned.
+func (q SearchQuery) filter() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	var matches []string
+
+	for _, term := range q.Terms {
+		if term.isLookup() {
+			condition, arg := term.lookupCondition()
+			conditions = append(conditions, condition)
+			args = append(args, arg...)
+			continue
+		}
+
+		condition, arg := term.condition()
+		switch {
+		case term.Exclude:
+			conditions = append(conditions, "rowid NOT IN (SELECT rowid FROM releases_fts WHERE "+condition+")")
+			args = append(args, arg...)
+		case term.usesIndex():
+			matches = append(matches, term.matchExpression())
+		default:
+			conditions = append(conditions, condition)
+			args = append(args, arg...)
+		}
+	}
+
+	if len(matches) > 0 {
+		conditions = append([]string{"releases_fts MATCH ?"}, conditions...)
+		args = append([]interface{}{strings.Join(matches, " AND ")}, args...)
+	}
+	if q.YearFrom != 0 {
+		conditions = append(conditions, "CAST(release_year AS INTEGER) >= ?")
+		args = append(args, q.YearFrom)
+	}
+	if q.YearTo != 0 {
+		conditions = append(conditions, "CAST(release_year AS INTEGER) <= ?")
+		args = append(args, q.YearTo)
+	}
+	if q.Decade != 0 {
+		conditions = append(conditions, "CAST(release_year AS INTEGER) BETWEEN ? AND ?")
+		args = append(args, q.Decade, q.Decade+9)
+	}
+	if q.Artist != "" {
+		conditions = append(conditions, `rowid IN (
+			SELECT release_artists.release_id
+			FROM release_artists
+			JOIN artists ON artists.id = release_artists.artist_id
+			WHERE artists.name = ?
+		)`)
+		args = append(args, q.Artist)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// masterGroupKey is the master id of a releases_fts row, or the negated
+// release id for releases without a master, so each master and each release
+// outside one is a group of its own when results are collapsed to masters
+const masterGroupKey = "(SELECT COALESCE(releases.master_id, -releases.id) FROM releases WHERE releases.id = releases_fts.release_id)"
+
+// ranked reports whether the query has a full text match that bm25 can rank
+func (q SearchQuery) ranked() bool {
+	for _, term := range q.Terms {
+		if !term.Exclude && term.usesIndex() {
+			return true
+		}
+	}
+	return false
+}
+
+func (t SearchTerm) usesIndex() bool {
+	return !t.isLookup() && utf8.RuneCountInString(t.Text) >= 3
+}
+
+// isLookup reports whether the term is an exact barcode, catalog number,
+// genre, tag, country or format
+func (t SearchTerm) isLookup() bool {
+	return lookupFields[t.Field]
+}
+
+// lookupCondition returns a condition matching the releases with the term's
+// barcode, catalog number, genre, tag, country or format, or without it for
+// excluded terms.
+// The value is normalized the way it's stored, so spacing and dashes don't
+// matter.
+func (t SearchTerm) lookupCondition() (string, []interface{}) {
+	operator := "IN"
+	if t.Exclude {
+		operator = "NOT IN"
+	}
+	switch t.Field {
+	case "barcode":
+		return "rowid " + operator + " (SELECT id FROM releases WHERE barcode = ?)", []interface{}{normalizeBarcode(t.Text)}
+	case "genre":
+		return "rowid " + operator + " (" + genreTaxonomy.releaseIds() + ")", []interface{}{slugify(t.Text)}
+	case "tag":
+		return "rowid " + operator + " (" + tagTaxonomy.releaseIds() + ")", []interface{}{slugify(t.Text)}
+	case "country":
+		return "rowid " + operator + " (SELECT id FROM releases WHERE country = ? COLLATE NOCASE)", []interface{}{strings.TrimSpace(t.Text)}
+	case "format":
+		query, args := formatReleaseIds(t.Text)
+		return "rowid " + operator + " (" + query + ")", args
+	}
+	return "rowid " + operator + " (SELECT release_id FROM release_labels WHERE catno_key = ?)", []interface{}{catnoKey(t.Text)}
+}
+
+// matchExpression quotes the term as an FTS5 string so syntax characters are matched literally
+func (t SearchTerm) matchExpression() string {
+	phrase := `"` + strings.ReplaceAll(t.Text, `"`, `""`) + `"`
+	if t.Field != "" {
+		return "{" + strings.Join(searchFields[t.Field], " ") + "} : " + phrase
+	}
+	return phrase
+}
+
+// condition returns a standalone SQL condition matching rows containing the term
+func (t SearchTerm) condition() (string, []interface{}) {
+	if t.usesIndex() {
+		return "releases_fts MATCH ?", []interface{}{t.matchExpression()}
+	}
+
+	columns := []string{"release_name", "release_year", "artist_names", "track_titles", "label_names", "artist_credit"}
+	if t.Field != "" {
+		columns = searchFields[t.Field]
+	}
+
+	pattern := "%" + escapeLike(t.Text) + "%"
+	likes := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	for i, column := range columns {
+		likes[i] = column + ` LIKE ? ESCAPE '\'`
+		args[i] = pattern
+	}
+	return "(" + strings.Join(likes, " OR ") + ")", args
+}
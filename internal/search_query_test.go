@@ -0,0 +1,191 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected SearchQuery
+	}{
+		{"", SearchQuery{}},
+		{"   ", SearchQuery{}},
+		{"night shift", SearchQuery{Terms: []SearchTerm{{Text: "night"}, {Text: "shift"}}}},
+		{`"night shift" -live`, SearchQuery{Terms: []SearchTerm{{Text: "night shift"}, {Text: "live", Exclude: true}}}},
+		{`artist:queen release:"a night"`, SearchQuery{Terms: []SearchTerm{{Field: "artist", Text: "queen"}, {Field: "release", Text: "a night"}}}},
+		{"Artist:Queen -artist:bowie", SearchQuery{Terms: []SearchTerm{{Field: "artist", Text: "Queen"}, {Field: "artist", Text: "bowie", Exclude: true}}}},
+		{"year:1990", SearchQuery{YearFrom: 1990, YearTo: 1990}},
+		{"year:1990..1995", SearchQuery{YearFrom: 1990, YearTo: 1995}},
+		{"year:1990..", SearchQuery{YearFrom: 1990}},
+		{"year:..1995", SearchQuery{YearTo: 1995}},
+		{"year:1980..1995 year:1990..2000", SearchQuery{YearFrom: 1990, YearTo: 1995}},
+		{`AC/DC 12:00 "" -`, SearchQuery{Terms: []SearchTerm{{Text: "AC/DC"}, {Text: "12:00"}}}},
+		{`a"b`, SearchQuery{Terms: []SearchTerm{{Text: `a"b`}}}},
+		{`label:emi catno:"CDP 7" -barcode:1234`, SearchQuery{Terms: []SearchTerm{{Field: "label", Text: "emi"}, {Field: "catno", Text: "CDP 7"}, {Field: "barcode", Text: "1234", Exclude: true}}}},
+		{`genre:rock -tag:"Hip Hop"`, SearchQuery{Terms: []SearchTerm{{Field: "genre", Text: "rock"}, {Field: "tag", Text: "Hip Hop", Exclude: true}}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			query, err := ParseSearchQuery(test.input)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, query)
+		})
+	}
+}
+
+func TestParseSearchQueryErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"night shift`, "Missing closing quote"},
+		{"mood:calm", `Unknown filter "mood:", use artist:, release:, track:, label:, genre:, tag:, catno:, barcode:, country:, format: or year:`},
+		{"artist:", "artist: needs a value"},
+		{"catno:--", "catno: needs letters or digits"},
+		{`tag:"&"`, "tag: needs letters or digits"},
+		{`barcode:" - "`, "barcode: needs letters or digits"},
+		{"year:", "year: needs a year or a range like 1990..1995"},
+		{"year:..", "year: needs a year or a range like 1990..1995"},
+		{"year:nineties", `"nineties" is not a year`},
+		{"year:1990..1995..2000", `"1995..2000" is not a year`},
+		{"year:1995..1990", "Year range must start before it ends"},
+		{"year:1980..1985 year:1990", "Year range must start before it ends"},
+		{"-year:1990", "year: can't be excluded, use a range like year:1990..1995"},
+		{strings.Repeat("a", maxSearchQueryLength+1), "Search must be at most 500 characters"},
+		{"\xff", "Search contains invalid characters"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			_, err := ParseSearchQuery(test.input)
+
+			var validationErr *ValidationError
+			if assert.True(t, errors.As(err, &validationErr)) {
+				assert.Equal(t, map[string]string{"q": test.expected}, validationErr.Fields)
+			}
+		})
+	}
+}
+
+func TestSearchReleases(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'Bowie'), (3, 'U2')")
+	mustExec(t, db, `INSERT INTO releases (id, name, year) VALUES
+		(1, 'Hot Space', 1982),
+		(2, 'Live Killers', 1979),
+		(3, 'Innuendo', 1991),
+		(4, 'Made in Heaven', 1995),
+		(5, 'Heroes', 1977),
+		(6, 'Zooropa', 1993),
+		(7, 'Under Pressure (Live)', 1981),
+		(8, 'OR "AND" NOT', 1990),
+		(9, '100% Pure', 1996)`)
+	mustExec(t, db, `INSERT INTO release_artists (release_id, artist_id) VALUES
+		(1, 1), (2, 1), (3, 1), (4, 1), (5, 2), (6, 3), (7, 1), (7, 2), (8, 2), (9, 3)`)
+
+	search := func(q string) []string {
+		t.Helper()
+		query := mustParseSearchQuery(t, q)
+		releases, err := getReleases(db, 100, 0, query, SortYear, nil)
+		if err != nil {
+			t.Fatalf("Failed to search %q: %v", q, err)
+		}
+
+		count, err := getReleasesCount(db, query)
+		if err != nil {
+			t.Fatalf("Failed to count %q: %v", q, err)
+		}
+		assert.Equal(t, len(releases), count, "count for %q", q)
+
+		names := []string{}
+		for _, release := range releases {
+			names = append(names, release["release_name"].(string)+"/"+release["artist_credit"].(string))
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"Live Killers/Queen", "Under Pressure (Live)/Queen, Bowie"}, search("live"))
+	assert.Equal(t, []string{"Hot Space/Queen", "Innuendo/Queen", "Made in Heaven/Queen"}, search("artist:queen -live"))
+	assert.Equal(t, []string{"Heroes/Bowie", "OR \"AND\" NOT/Bowie"}, search("artist:bowie -live"))
+	assert.Equal(t, []string{"Innuendo/Queen", "Zooropa/U2", "Made in Heaven/Queen"}, search("year:1991..1995"))
+	assert.Equal(t, []string{"Innuendo/Queen", "Made in Heaven/Queen"}, search("artist:queen year:1991.."))
+	assert.Equal(t, []string{"Heroes/Bowie", "Live Killers/Queen"}, search("year:..1979"))
+	assert.Equal(t, []string{"Made in Heaven/Queen"}, search(`"in heaven"`))
+	assert.Empty(t, search(`"heaven in"`))
+	assert.Equal(t, []string{"Under Pressure (Live)/Queen, Bowie"}, search("release:live artist:bowie"))
+	assert.Empty(t, search("release:queen"))
+
+	// Terms shorter than the trigram index can match
+	assert.Equal(t, []string{"Zooropa/U2", "100% Pure/U2"}, search("artist:u2"))
+	assert.Equal(t, []string{"Zooropa/U2"}, search("u2 -pure"))
+	assert.Equal(t, []string{"100% Pure/U2"}, search("0%"))
+	assert.Equal(t, []string{"Zooropa/U2", "100% Pure/U2"}, search("-queen -bowie"))
+
+	// FTS5 syntax is matched literally
+	assert.Equal(t, []string{"OR \"AND\" NOT/Bowie"}, search(`OR AND NOT`))
+	assert.Equal(t, []string{"OR \"AND\" NOT/Bowie"}, search(`"AND"`))
+	assert.Equal(t, []string{"Under Pressure (Live)/Queen, Bowie"}, search("(live)"))
+	assert.Empty(t, search("*"))
+	assert.Empty(t, search(`NEAR(a b)`))
+}
+
+func FuzzParseSearchQuery(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"night shift",
+		`artist:queen year:1990..1995 "exact phrase" -live`,
+		`release:"a b" -artist:u2 year:..1990`,
+		`"unterminated`,
+		`OR AND NOT NEAR(a b) * ^ {} : ""`,
+		"-year:1990",
+		"mood:calm",
+		`label:emi -catno:"CDP 7" barcode:077774620826`,
+		`genre:rock -tag:"hip hop"`,
+		"\xff\x00",
+	} {
+		f.Add(seed)
+	}
+
+	db := openMigratedTestDB(f)
+	mustExec(f, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen')")
+	mustExec(f, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Night Shift', 1990)")
+	mustExec(f, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1)")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		query, err := ParseSearchQuery(input)
+		if err != nil {
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || validationErr.Fields["q"] == "" {
+				t.Fatalf("ParseSearchQuery(%q) returned %v, want a validation error for q", input, err)
+			}
+			return
+		}
+
+		for _, term := range query.Terms {
+			if term.Text == "" {
+				t.Fatalf("ParseSearchQuery(%q) returned an empty term", input)
+			}
+			if _, ok := searchFields[term.Field]; term.Field != "" && !ok && !lookupFields[term.Field] {
+				t.Fatalf("ParseSearchQuery(%q) returned unknown field %q", input, term.Field)
+			}
+		}
+		if query.YearFrom != 0 && query.YearTo != 0 && query.YearFrom > query.YearTo {
+			t.Fatalf("ParseSearchQuery(%q) returned backwards years %d..%d", input, query.YearFrom, query.YearTo)
+		}
+
+		// Every valid query must compile to SQL that SQLite accepts
+		where, args := query.filter()
+		if placeholders := strings.Count(where, "?"); placeholders != len(args) {
+			t.Fatalf("filter for %q has %d placeholders and %d args", input, placeholders, len(args))
+		}
+		if _, err := getReleases(db, 10, 0, query, "", nil); err != nil {
+			t.Fatalf("getReleases(%q) failed: %v", input, err)
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package internal
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+func setupSearchRoutes(e *echo.Echo, db *sql.DB) {
+	// Typeahead suggestions for the search box, as a dropdown for HTMX requests and JSON otherwise
+	e.GET("/search/suggest", func(c echo.Context) error {
+		limit := defaultInt(c.QueryParam("limit"), defaultSuggestLimit)
+		if limit > maxSuggestLimit {
+			limit = maxSuggestLimit
+		}
+
+		suggestions, err := getSuggestions(db, c.QueryParam("q"), limit)
+		if err != nil {
+			e.Logger.Printf("Failed to get suggestions: %v", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load suggestions")
+		}
+
+		if isHtmxRequest(c) {
+			return c.Render(http.StatusOK, "suggestions_partial", map[string]interface{}{
+				"Suggestions": suggestions,
+			})
+		}
+		return c.JSON(http.StatusOK, suggestions)
+	})
+}
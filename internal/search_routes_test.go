@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchRoutes(t *testing.T) {
+	e := echo.New()
+	e.Renderer = &Template{TemplateDir: "./templates"}
+
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, '<b>Bold</b>')")
+	mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Queen II', 1974), (2, 'Jazz', 1978)")
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1), (2, 1)")
+	SetupRoutes(e, db, DefaultConfig())
+
+	t.Run("GET /search/suggest with HTMX", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/search/suggest?q=quee", nil)
+		req.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		body := rec.Body.String()
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, body, `<a href="/artists/1"`)
+		assert.Contains(t, body, `>Queen</a>`)
+		assert.Contains(t, body, `<a href="/releases/1"`)
+		assert.Contains(t, body, `Queen II <span class="text-gray-500">1974</span>`)
+		assert.NotContains(t, body, "Jazz")
+		assert.NotContains(t, body, "<html")
+	})
+
+	t.Run("GET /search/suggest with HTMX escapes names", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/search/suggest?q=bold", nil)
+		req.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Contains(t, rec.Body.String(), "&lt;b&gt;Bold&lt;/b&gt;")
+	})
+
+	t.Run("GET /search/suggest with no matches", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/search/suggest?q=nothing", nil)
+		req.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "listbox")
+	})
+
+	t.Run("GET /search/suggest as JSON", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/search/suggest?q=q&limit=1", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"artists": [{"id": 1, "name": "Queen"}],
+			"releases": [{"id": 1, "name": "Queen II", "year": 1974}]
+		}`, rec.Body.String())
+	})
+
+	t.Run("GET /search/suggest without a query", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/search/suggest", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"artists": [], "releases": []}`, rec.Body.String())
+	})
+}
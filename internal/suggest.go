@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"database/sql"
+	"strings"
+	"unicode/utf8"
+)
+
+// Number of artists and of releases suggested when no limit is given, and the most that can be asked for
+const (
+	defaultSuggestLimit = 5
+	maxSuggestLimit     = 20
+)
+
+type ReleaseSuggestion struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+	Year int    `json:"year"`
+}
+
+// Suggestions are the artists and releases whose names match what has been
+// typed into the search box so far
+type Suggestions struct {
+	Artists  []Artist            `json:"artists"`
+	Releases []ReleaseSuggestion `json:"releases"`
+}
+
+// getSuggestions returns up to limit artists and limit releases with names
+// matching searchQuery. Names starting with searchQuery come first, then
+// shorter names. Queries of 3 or more characters use the trigram indexes to
+// match anywhere in the name, shorter queries only match name prefixes.
+func getSuggestions(db *sql.DB, searchQuery string, limit int) (Suggestions, error) {
+	suggestions := Suggestions{Artists: []Artist{}, Releases: []ReleaseSuggestion{}}
+	searchQuery = strings.TrimSpace(replaceControlCharacters(strings.ToValidUTF8(searchQuery, "")))
+	if searchQuery == "" {
+		return suggestions, nil
+	}
+	prefix := escapeLike(searchQuery) + "%"
+
+	where, args := artistSearchFilter(searchQuery)
+	rows, err := db.Query(`
+		SELECT id, name
+		FROM artists
+		`+where+`
+		ORDER BY name LIKE ? ESCAPE '\' DESC, length(name) ASC, name COLLATE NOCASE ASC, id ASC
+		LIMIT ?
+	`, append(args, prefix, limit)...)
+	if err != nil {
+		return Suggestions{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var artist Artist
+		if err := rows.Scan(&artist.Id, &artist.Name); err != nil {
+			return Suggestions{}, err
+		}
+		suggestions.Artists = append(suggestions.Artists, artist)
+	}
+	if err := rows.Err(); err != nil {
+		return Suggestions{}, err
+	}
+
+	where, args = releaseNameFilter(searchQuery)
+	rows, err = db.Query(`
+		SELECT id, name, year
+		FROM releases
+		`+where+`
+		ORDER BY name LIKE ? ESCAPE '\' DESC, length(name) ASC, name COLLATE NOCASE ASC, year ASC, id ASC
+		LIMIT ?
+	`, append(args, prefix, limit)...)
+	if err != nil {
+		return Suggestions{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var release ReleaseSuggestion
+		if err := rows.Scan(&release.Id, &release.Name, &release.Year); err != nil {
+			return Suggestions{}, err
+		}
+		suggestions.Releases = append(suggestions.Releases, release)
+	}
+
+	return suggestions, rows.Err()
+}
+
+// releaseNameFilter returns a WHERE clause matching releases by name, like
+// artistSearchFilter does for artists. Queries too short for the trigram
+// index match name prefixes instead.
+func releaseNameFilter(searchQuery string) (string, []interface{}) {
+	if utf8.RuneCountInString(searchQuery) < 3 {
+		return `WHERE releases.name LIKE ? ESCAPE '\'`, []interface{}{escapeLike(searchQuery) + "%"}
+	}
+	term := SearchTerm{Field: "release", Text: searchQuery}
+	return "WHERE releases.id IN (SELECT rowid FROM releases_fts WHERE releases_fts MATCH ?)", []interface{}{term.matchExpression()}
+}
@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSuggestions(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'Queens of the Stone Age'), (3, 'Dairy Queen Band'), (4, 'Bowie'), (5, 'Q_Tip')")
+	mustExec(t, db, `INSERT INTO releases (id, name, year) VALUES
+		(1, 'Queen II', 1974),
+		(2, 'Songs for the Deaf', 2002),
+		(3, 'The Queen Is Dead', 1986),
+		(4, 'Queen', 1973),
+		(5, 'Quiet Storm', 2000)`)
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1), (2, 2), (3, 3), (4, 1)")
+
+	artistNames := func(suggestions Suggestions) []string {
+		names := []string{}
+		for _, artist := range suggestions.Artists {
+			names = append(names, artist.Name)
+		}
+		return names
+	}
+	releaseNames := func(suggestions Suggestions) []string {
+		names := []string{}
+		for _, release := range suggestions.Releases {
+			names = append(names, release.Name)
+		}
+		return names
+	}
+
+	t.Run("Prefix Matches First", func(t *testing.T) {
+		suggestions, err := getSuggestions(db, "queen", 5)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Queen", "Queens of the Stone Age", "Dairy Queen Band"}, artistNames(suggestions))
+		assert.Equal(t, []string{"Queen", "Queen II", "The Queen Is Dead"}, releaseNames(suggestions))
+		assert.Equal(t, ReleaseSuggestion{Id: 4, Name: "Queen", Year: 1973}, suggestions.Releases[0])
+	})
+
+	t.Run("Short Queries Match Prefixes", func(t *testing.T) {
+		suggestions, err := getSuggestions(db, "qu", 5)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Queen", "Queens of the Stone Age"}, artistNames(suggestions))
+		assert.Equal(t, []string{"Queen", "Queen II", "Quiet Storm"}, releaseNames(suggestions))
+
+		suggestions, err = getSuggestions(db, "q_", 5)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Q_Tip"}, artistNames(suggestions))
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		suggestions, err := getSuggestions(db, "queen", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Queen"}, artistNames(suggestions))
+		assert.Equal(t, []string{"Queen"}, releaseNames(suggestions))
+	})
+
+	t.Run("Empty Query", func(t *testing.T) {
+		suggestions, err := getSuggestions(db, " \x00 ", 5)
+		assert.NoError(t, err)
+		assert.Equal(t, Suggestions{Artists: []Artist{}, Releases: []ReleaseSuggestion{}}, suggestions)
+	})
+
+	t.Run("FTS Syntax", func(t *testing.T) {
+		suggestions, err := getSuggestions(db, `"queen" OR *`, 5)
+		assert.NoError(t, err)
+		assert.Empty(t, suggestions.Artists)
+		assert.Empty(t, suggestions.Releases)
+	})
+}
@@ -0,0 +1,182 @@
+package internal
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// setupTagAdminRoutes serves the pages for keeping up the genre vocabulary
+// and cleaning up tags: listing, creating, renaming, deleting and merging
+func setupTagAdminRoutes(e *echo.Echo, db *sql.DB) {
+	for _, t := range []taxonomy{genreTaxonomy, tagTaxonomy} {
+		adminPath := "/admin/" + t.Plural
+
+		// renderTagForm renders the create/edit form, or just the form partial for HTMX requests
+		renderTagForm := func(c echo.Context, status int, tagId int, input TagInput, fieldErrors map[string]string) error {
+			tags, err := getTags(db, t)
+			if err != nil {
+				e.Logger.Printf("Failed to get %s: %v", t.Plural, err)
+				return c.String(http.StatusInternalServerError, "Failed to load "+t.Plural)
+			}
+
+			// Only genres can be parents, and a term can't be merged into itself
+			parents := []TagSummary{}
+			mergeTargets := []TagSummary{}
+			for _, tag := range tags {
+				if tag.Id == tagId {
+					continue
+				}
+				if tag.ParentId == 0 {
+					parents = append(parents, tag)
+				}
+				mergeTargets = append(mergeTargets, tag)
+			}
+
+			title := "New " + t.SingularTitle()
+			if tagId != 0 {
+				title = "Edit " + t.SingularTitle()
+			}
+
+			data := map[string]interface{}{
+				"Title":        title,
+				"Taxonomy":     t,
+				"TagId":        tagId,
+				"Input":        input,
+				"Errors":       fieldErrors,
+				"Parents":      parents,
+				"MergeTargets": mergeTargets,
+				"IncludeHTMX":  true,
+				"CurrentRoute": c.Request().URL.Path,
+			}
+
+			if isHtmxRequest(c) {
+				return c.Render(status, "admin_tag_form_partial", data)
+			}
+			return c.Render(status, "admin_tag_form", data)
+		}
+
+		// handleTagError re-renders the form for validation errors and maps everything else to a status
+		handleTagError := func(c echo.Context, err error, tagId int, input TagInput) error {
+			var validationErr *ValidationError
+			switch {
+			case errors.As(err, &validationErr):
+				return renderTagForm(c, invalidFormStatus(c), tagId, input, validationErr.Fields)
+			case errors.Is(err, errNotFound):
+				return echo.NewHTTPError(http.StatusNotFound, t.SingularTitle()+" not found")
+			default:
+				e.Logger.Printf("Failed to save %s: %v", t.Name, err)
+				return c.String(http.StatusInternalServerError, "Failed to save "+t.Name)
+			}
+		}
+
+		e.GET(adminPath, func(c echo.Context) error {
+			tags, err := getTags(db, t)
+			if err != nil {
+				e.Logger.Printf("Failed to get %s: %v", t.Plural, err)
+				return c.String(http.StatusInternalServerError, "Failed to load "+t.Plural)
+			}
+
+			// Styles are listed with the genre they belong to
+			parentNames := map[int]string{}
+			for _, tag := range tags {
+				parentNames[tag.Id] = tag.Name
+			}
+
+			data := map[string]interface{}{
+				"Title":        "Manage " + t.Title(),
+				"Taxonomy":     t,
+				"Tags":         tags,
+				"ParentNames":  parentNames,
+				"IncludeHTMX":  true,
+				"CurrentRoute": c.Request().URL.Path,
+			}
+
+			return c.Render(http.StatusOK, "admin_tags", data)
+		})
+
+		e.GET(adminPath+"/new", func(c echo.Context) error {
+			return renderTagForm(c, http.StatusOK, 0, TagInput{}, nil)
+		})
+
+		e.POST(adminPath, func(c echo.Context) error {
+			var input TagInput
+			if err := c.Bind(&input); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "Invalid "+t.Name)
+			}
+
+			if _, err := createTag(db, t, input); err != nil {
+				return handleTagError(c, err, 0, input)
+			}
+
+			return redirectAfterSubmit(c, adminPath)
+		})
+
+		e.GET(adminPath+"/:id/edit", func(c echo.Context) error {
+			tagId, err := paramId(c)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusNotFound, t.SingularTitle()+" not found")
+			}
+
+			tag, err := getTag(db, t, tagId)
+			if err != nil {
+				return handleTagError(c, err, tagId, TagInput{})
+			}
+
+			return renderTagForm(c, http.StatusOK, tagId, TagInput{Name: tag.Name, ParentId: tag.ParentId}, nil)
+		})
+
+		e.PUT(adminPath+"/:id", func(c echo.Context) error {
+			tagId, err := paramId(c)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusNotFound, t.SingularTitle()+" not found")
+			}
+
+			var input TagInput
+			if err := c.Bind(&input); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "Invalid "+t.Name)
+			}
+
+			if _, err := updateTag(db, t, tagId, input); err != nil {
+				return handleTagError(c, err, tagId, input)
+			}
+
+			return redirectAfterSubmit(c, adminPath)
+		})
+
+		e.DELETE(adminPath+"/:id", func(c echo.Context) error {
+			tagId, err := paramId(c)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusNotFound, t.SingularTitle()+" not found")
+			}
+
+			if err := deleteTag(db, t, tagId); err != nil {
+				return handleTagError(c, err, tagId, TagInput{})
+			}
+
+			return redirectAfterSubmit(c, adminPath)
+		})
+
+		e.POST(adminPath+"/:id/merge", func(c echo.Context) error {
+			tagId, err := paramId(c)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusNotFound, t.SingularTitle()+" not found")
+			}
+
+			intoId, _ := strconv.Atoi(c.FormValue("into_id"))
+			if _, err := mergeTag(db, t, tagId, intoId); err != nil {
+				// The form shows the term's saved name next to the merge error
+				tag, loadErr := getTag(db, t, tagId)
+				if loadErr != nil {
+					return handleTagError(c, loadErr, tagId, TagInput{})
+				}
+				return handleTagError(c, err, tagId, TagInput{Name: tag.Name, ParentId: tag.ParentId})
+			}
+
+			return redirectAfterSubmit(c, adminPath)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+)
+
+// setupTagRoutes serves the tag cloud and browse pages of genres and tags,
+// such as /genres and /tags/:slug
+func setupTagRoutes(e *echo.Echo, db *sql.DB, config Config) {
+	for _, t := range []taxonomy{genreTaxonomy, tagTaxonomy} {
+		e.GET("/"+t.Plural, func(c echo.Context) error {
+			tags, err := getTags(db, t)
+			if err != nil {
+				e.Logger.Printf("Failed to get %s: %v", t.Plural, err)
+				return c.String(http.StatusInternalServerError, "Failed to load "+t.Plural)
+			}
+
+			data := map[string]interface{}{
+				"Title":        t.Title(),
+				"Taxonomy":     t,
+				"Cloud":        tagCloud(tags),
+				"CurrentRoute": c.Request().URL.Path,
+			}
+
+			return c.Render(http.StatusOK, "tags", data)
+		})
+
+		e.GET("/"+t.Plural+"/:slug", func(c echo.Context) error {
+			notFound := "This " + t.Name + " doesn't exist."
+			slug, err := url.PathUnescape(c.Param("slug"))
+			if err != nil {
+				return renderNotFound(c, notFound)
+			}
+
+			tag, err := getTagDetail(db, t, slug)
+			if errors.Is(err, errNotFound) {
+				return renderNotFound(c, notFound)
+			}
+			if err != nil {
+				e.Logger.Printf("Failed to get %s: %v", t.Name, err)
+				return c.String(http.StatusInternalServerError, "Failed to load "+t.Name)
+			}
+
+			releases, pagination, err := getPaginatedTagReleases(db, t, tag.Slug, c.QueryParam("page"), c.QueryParam("page_size"), config.DefaultPageSize, c.Request())
+			if err != nil {
+				e.Logger.Printf("Failed to get %s releases: %v", t.Name, err)
+				return c.String(http.StatusInternalServerError, "Failed to load releases")
+			}
+
+			// Render appropriate template (full page or HTMX partial)
+			if isHtmxRequest(c) {
+				return c.Render(http.StatusOK, "tag_releases_partial", map[string]interface{}{
+					"Releases":   releases,
+					"Pagination": pagination,
+				})
+			}
+
+			data := map[string]interface{}{
+				"Title":        tag.Name,
+				"Taxonomy":     t,
+				"Tag":          tag,
+				"Releases":     releases,
+				"Pagination":   pagination,
+				"IncludeHTMX":  true,
+				"CurrentRoute": c.Request().URL.Path,
+			}
+
+			return c.Render(http.StatusOK, "tag", data)
+		})
+	}
+}
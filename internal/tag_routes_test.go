@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagRoutes(t *testing.T) {
+	e := echo.New()
+	e.Renderer = &Template{TemplateDir: "./templates"}
+
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Yes')")
+	mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Close to the Edge', 1972), (2, 'Fragile', 1971)")
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1), (2, 1)")
+	mustExec(t, db, "INSERT INTO genres (id, name, slug, parent_id) VALUES (1, 'Rock', 'rock', NULL), (2, 'Prog Rock', 'prog-rock', 1)")
+	mustExec(t, db, "INSERT INTO tags (id, name, slug) VALUES (1, 'Gatefold', 'gatefold'), (2, 'Unused', 'unused')")
+	mustExec(t, db, "INSERT INTO release_genres (release_id, genre_id) VALUES (1, 2), (2, 1)")
+	mustExec(t, db, "INSERT INTO release_tags (release_id, tag_id) VALUES (1, 1)")
+	SetupRoutes(e, db, DefaultConfig())
+
+	get := func(target string, htmx bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if htmx {
+			req.Header.Set("HX-Request", "true")
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	submit := func(method string, target string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		req.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("GET /tags", func(t *testing.T) {
+		rec := get("/tags", false)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `href="/tags/gatefold"`)
+		assert.Contains(t, rec.Body.String(), `title="1 release"`)
+		assert.NotContains(t, rec.Body.String(), "Unused")
+	})
+
+	t.Run("GET /genres/:slug", func(t *testing.T) {
+		rec := get("/genres/rock", false)
+
+		body := rec.Body.String()
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, body, "Close to the Edge")
+		assert.Contains(t, body, "Fragile")
+		assert.Contains(t, body, `href="/genres/prog-rock"`)
+
+		rec = get("/genres/prog-rock?page_size=1", true)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Close to the Edge")
+		assert.NotContains(t, rec.Body.String(), "<html")
+
+		rec = get("/genres/gatefold", false)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Contains(t, rec.Body.String(), "This genre doesn&#39;t exist.")
+	})
+
+	t.Run("GET /api/v1/tags/:slug", func(t *testing.T) {
+		rec := get("/api/v1/genres/rock", false)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response TagReleasesResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, "Rock", response.Name)
+		assert.Equal(t, []Tag{{Id: 2, Name: "Prog Rock", Slug: "prog-rock", ParentId: 1}}, response.Styles)
+		assert.Len(t, response.Releases, 2)
+		assert.Equal(t, 2, response.Pagination.TotalCount)
+
+		rec = get("/api/v1/tags", false)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"tags": [
+			{"id": 1, "name": "Gatefold", "slug": "gatefold", "release_count": 1},
+			{"id": 2, "name": "Unused", "slug": "unused", "release_count": 0}
+		]}`, rec.Body.String())
+
+		rec = get("/api/v1/tags/nothing", false)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("GET /releases with tag:", func(t *testing.T) {
+		rec := get("/releases?q=tag:gatefold", true)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Close to the Edge")
+		assert.NotContains(t, rec.Body.String(), "Fragile")
+	})
+
+	t.Run("PUT /releases/:id with genres and tags", func(t *testing.T) {
+		rec := get("/releases/1/edit", false)
+		assert.Contains(t, rec.Body.String(), `<option value="2" selected>Rock &rsaquo; Prog Rock</option>`)
+		assert.Contains(t, rec.Body.String(), `value="Gatefold"`)
+
+		rec = submit(http.MethodPut, "/releases/1", url.Values{
+			"name":       {"Close to the Edge"},
+			"year":       {"1972"},
+			"artist_ids": {"1"},
+			"genre_ids":  {"1"},
+			"tags":       {"gatefold, Concept Album"},
+		})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "/releases/1", rec.Header().Get("HX-Redirect"))
+
+		rec = get("/releases/1", false)
+		assert.Contains(t, rec.Body.String(), `href="/genres/rock" class="text-rose-800 hover:underline">Rock</a>`)
+		assert.Contains(t, rec.Body.String(), `href="/tags/concept-album"`)
+	})
+
+	t.Run("Admin", func(t *testing.T) {
+		rec := get("/admin/genres", false)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `href="/admin/genres/2/edit"`)
+
+		rec = submit(http.MethodPost, "/admin/genres", url.Values{"name": {"Art Rock"}, "parent_id": {"1"}})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "/admin/genres", rec.Header().Get("HX-Redirect"))
+
+		rec = submit(http.MethodPost, "/admin/genres", url.Values{"name": {"art-rock"}})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "There&#39;s already a genre called Art Rock")
+
+		rec = get("/admin/tags/1/edit", false)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `hx-post="/admin/tags/1/merge"`)
+		assert.NotContains(t, rec.Body.String(), `name="parent_id"`)
+
+		rec = submit(http.MethodPut, "/admin/tags/2", url.Values{"name": {"Rare"}})
+		assert.Equal(t, "/admin/tags", rec.Header().Get("HX-Redirect"))
+
+		rec = submit(http.MethodPost, "/admin/tags/1/merge", url.Values{"into_id": {"0"}})
+		assert.Contains(t, rec.Body.String(), "Pick a tag to merge into")
+
+		rec = submit(http.MethodPost, "/admin/tags/1/merge", url.Values{"into_id": {"2"}})
+		assert.Equal(t, "/admin/tags", rec.Header().Get("HX-Redirect"))
+		assert.Equal(t, []string{"Yes"}, searchCredits(t, db, "tag:rare"))
+
+		rec = submit(http.MethodDelete, "/admin/tags/2", nil)
+		assert.Equal(t, "/admin/tags", rec.Header().Get("HX-Redirect"))
+		assert.Empty(t, searchCredits(t, db, "tag:rare"))
+
+		rec = submit(http.MethodDelete, "/admin/tags/2", nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
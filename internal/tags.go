@@ -0,0 +1,573 @@
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// Tag is a genre or a free-form tag. ParentId is set on genres that are a
+// style of another genre.
+type Tag struct {
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	ParentId int    `json:"parent_id,omitempty"`
+}
+
+// taxonomy is a way of classifying releases. Genres and tags are stored alike,
+// in a table of their own joined to releases through another.
+type taxonomy struct {
+	// Name is the singular, Plural names the table and the path of the pages
+	Name   string
+	Plural string
+	// Styles is set when terms can be a style of another term
+	Styles    bool
+	joinTable string
+	column    string
+}
+
+var genreTaxonomy = taxonomy{Name: "genre", Plural: "genres", Styles: true, joinTable: "release_genres", column: "genre_id"}
+
+var tagTaxonomy = taxonomy{Name: "tag", Plural: "tags", joinTable: "release_tags", column: "tag_id"}
+
+// taxonomies maps the path of each taxonomy's pages to it
+var taxonomies = map[string]taxonomy{
+	genreTaxonomy.Plural: genreTaxonomy,
+	tagTaxonomy.Plural:   tagTaxonomy,
+}
+
+// Title is the plural name for headings, like "Genres"
+func (t taxonomy) Title() string {
+	return strings.ToUpper(t.Plural[:1]) + t.Plural[1:]
+}
+
+// SingularTitle is the singular name starting a sentence, like "Genre"
+func (t taxonomy) SingularTitle() string {
+	return strings.ToUpper(t.Name[:1]) + t.Name[1:]
+}
+
+// columns is the select list that scans into a Tag
+func (t taxonomy) columns() string {
+	parent := "0"
+	if t.Styles {
+		parent = "COALESCE(" + t.Plural + ".parent_id, 0)"
+	}
+	return t.Plural + ".id, " + t.Plural + ".name, " + t.Plural + ".slug, " + parent
+}
+
+// releaseIds returns a query of the ids of releases filed under the term
+// with the slug given as its only argument. Releases filed under a style are
+// filed under its genre too.
+func (t taxonomy) releaseIds() string {
+	terms := "SELECT id FROM " + t.Plural + " WHERE slug = ?"
+	if t.Styles {
+		terms = "SELECT styles.id FROM genres JOIN genres AS styles ON styles.id = genres.id OR styles.parent_id = genres.id WHERE genres.slug = ?"
+	}
+	return "SELECT release_id FROM " + t.joinTable + " WHERE " + t.column + " IN (" + terms + ")"
+}
+
+// slugify makes the form of a name used in URLs and lookups: lowercase
+// letters and digits with dashes between words, so "Drum & Bass" is drum-bass
+func slugify(name string) string {
+	var slug strings.Builder
+	separated := false
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			separated = true
+			continue
+		}
+		if separated && slug.Len() > 0 {
+			slug.WriteByte('-')
+		}
+		separated = false
+		slug.WriteRune(unicode.ToLower(r))
+	}
+	return slug.String()
+}
+
+// maxTagLength keeps tags short enough to read in a tag cloud
+const maxTagLength = 50
+
+// normalizeTagNames splits names on commas, so forms can send tags in one
+// field, and drops blank and repeated names. It returns the names and the
+// first problem with one of them.
+func normalizeTagNames(values []string) ([]string, string) {
+	names := []string{}
+	seen := map[string]bool{}
+	problem := ""
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.Join(strings.Fields(name), " ")
+			slug := slugify(name)
+			switch {
+			case name == "":
+				continue
+			case slug == "":
+				if problem == "" {
+					problem = fmt.Sprintf("Tag %q needs letters or digits", name)
+				}
+				continue
+			case len(name) > maxTagLength:
+				if problem == "" {
+					problem = fmt.Sprintf("Tags can be at most %d characters", maxTagLength)
+				}
+				continue
+			case seen[slug]:
+				continue
+			}
+			seen[slug] = true
+			names = append(names, name)
+		}
+	}
+	return names, problem
+}
+
+// ensureTag returns the id of the tag with the slug of name, creating it
+// when there's none
+func ensureTag(tx *sql.Tx, name string) (int, error) {
+	slug := slugify(name)
+	var tagId int
+	err := tx.QueryRow("SELECT id FROM tags WHERE slug = ?", slug).Scan(&tagId)
+	if err == nil {
+		return tagId, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	result, err := tx.Exec("INSERT INTO tags (name, slug) VALUES (?, ?)", name, slug)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// setReleaseTerms replaces the genres or tags of a release
+func setReleaseTerms(tx *sql.Tx, t taxonomy, releaseId int, termIds []int) error {
+	if _, err := tx.Exec("DELETE FROM "+t.joinTable+" WHERE release_id = ?", releaseId); err != nil {
+		return err
+	}
+
+	for _, termId := range termIds {
+		_, err := tx.Exec("INSERT OR IGNORE INTO "+t.joinTable+" (release_id, "+t.column+") VALUES (?, ?)", releaseId, termId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setReleaseTagNames replaces the tags of a release with the named tags
+func setReleaseTagNames(tx *sql.Tx, releaseId int, names []string) error {
+	tagIds := make([]int, 0, len(names))
+	for _, name := range names {
+		tagId, err := ensureTag(tx, name)
+		if err != nil {
+			return err
+		}
+		tagIds = append(tagIds, tagId)
+	}
+	return setReleaseTerms(tx, tagTaxonomy, releaseId, tagIds)
+}
+
+// getReleasesTerms loads the genres or tags of the releases with the ids in
+// args, by release id and ordered by name
+func getReleasesTerms(db *sql.DB, t taxonomy, placeholders string, args []interface{}) (map[int][]Tag, error) {
+	rows, err := db.Query(`
+		SELECT `+t.joinTable+`.release_id, `+t.columns()+`
+		FROM `+t.joinTable+`
+		JOIN `+t.Plural+` ON `+t.Plural+`.id = `+t.joinTable+`.`+t.column+`
+		WHERE `+t.joinTable+`.release_id IN (`+placeholders+`)
+		ORDER BY `+t.Plural+`.name COLLATE NOCASE, `+t.Plural+`.id;
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	terms := map[int][]Tag{}
+	for rows.Next() {
+		var releaseId int
+		var term Tag
+		if err := rows.Scan(&releaseId, &term.Id, &term.Name, &term.Slug, &term.ParentId); err != nil {
+			return nil, err
+		}
+		terms[releaseId] = append(terms[releaseId], term)
+	}
+	return terms, rows.Err()
+}
+
+func getTag(db *sql.DB, t taxonomy, tagId int) (Tag, error) {
+	var tag Tag
+	err := db.QueryRow("SELECT "+t.columns()+" FROM "+t.Plural+" WHERE id = ?", tagId).
+		Scan(&tag.Id, &tag.Name, &tag.Slug, &tag.ParentId)
+	if err == sql.ErrNoRows {
+		return Tag{}, errNotFound
+	}
+	return tag, err
+}
+
+func getTagBySlug(db *sql.DB, t taxonomy, slug string) (Tag, error) {
+	var tag Tag
+	err := db.QueryRow("SELECT "+t.columns()+" FROM "+t.Plural+" WHERE slug = ?", slug).
+		Scan(&tag.Id, &tag.Name, &tag.Slug, &tag.ParentId)
+	if err == sql.ErrNoRows {
+		return Tag{}, errNotFound
+	}
+	return tag, err
+}
+
+type TagSummary struct {
+	Tag
+	ReleaseCount int `json:"release_count"`
+}
+
+// getTags lists every genre or tag by name with its number of releases.
+// Genres count the releases of their styles too.
+func getTags(db *sql.DB, t taxonomy) ([]TagSummary, error) {
+	filed, styles := t.Plural, ""
+	if t.Styles {
+		filed = "styles"
+		styles = "LEFT JOIN genres AS styles ON styles.id = genres.id OR styles.parent_id = genres.id"
+	}
+
+	rows, err := db.Query(`
+		SELECT
+			` + t.columns() + `,
+			COUNT(DISTINCT ` + t.joinTable + `.release_id) AS release_count
+		FROM ` + t.Plural + `
+		` + styles + `
+		LEFT JOIN ` + t.joinTable + ` ON ` + t.joinTable + `.` + t.column + ` = ` + filed + `.id
+		GROUP BY ` + t.Plural + `.id
+		ORDER BY ` + t.Plural + `.name COLLATE NOCASE, ` + t.Plural + `.id;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []TagSummary{}
+	for rows.Next() {
+		var tag TagSummary
+		if err := rows.Scan(&tag.Id, &tag.Name, &tag.Slug, &tag.ParentId, &tag.ReleaseCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// tagCloudSizes is the number of text sizes in a tag cloud
+const tagCloudSizes = 5
+
+// TagCloudItem is a tag in a tag cloud. Size runs from 1 to tagCloudSizes.
+type TagCloudItem struct {
+	TagSummary
+	Size int
+}
+
+// tagCloud sizes tags by their number of releases on a log scale, so a few
+// big tags don't shrink the rest to the same size. Tags without releases
+// are left out.
+func tagCloud(tags []TagSummary) []TagCloudItem {
+	maxCount := 0
+	for _, tag := range tags {
+		maxCount = max(maxCount, tag.ReleaseCount)
+	}
+
+	cloud := []TagCloudItem{}
+	for _, tag := range tags {
+		if tag.ReleaseCount == 0 {
+			continue
+		}
+		size := 1
+		if maxCount > 1 {
+			scale := math.Log(float64(tag.ReleaseCount)) / math.Log(float64(maxCount))
+			size += int(math.Round(scale * (tagCloudSizes - 1)))
+		}
+		cloud = append(cloud, TagCloudItem{TagSummary: tag, Size: size})
+	}
+	return cloud
+}
+
+// TagDetail is a genre or tag with the genre a style belongs to and the
+// styles of a genre
+type TagDetail struct {
+	Tag
+	Parent *Tag  `json:"parent,omitempty"`
+	Styles []Tag `json:"styles,omitempty"`
+}
+
+func getTagDetail(db *sql.DB, t taxonomy, slug string) (TagDetail, error) {
+	tag, err := getTagBySlug(db, t, slug)
+	if err != nil {
+		return TagDetail{}, err
+	}
+	detail := TagDetail{Tag: tag}
+	if !t.Styles {
+		return detail, nil
+	}
+
+	if tag.ParentId != 0 {
+		parent, err := getTag(db, t, tag.ParentId)
+		if err != nil {
+			return TagDetail{}, err
+		}
+		detail.Parent = &parent
+	}
+
+	rows, err := db.Query("SELECT "+t.columns()+" FROM genres WHERE parent_id = ? ORDER BY name COLLATE NOCASE, id", tag.Id)
+	if err != nil {
+		return TagDetail{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var style Tag
+		if err := rows.Scan(&style.Id, &style.Name, &style.Slug, &style.ParentId); err != nil {
+			return TagDetail{}, err
+		}
+		detail.Styles = append(detail.Styles, style)
+	}
+	return detail, rows.Err()
+}
+
+// getPaginatedTagReleases pages through the releases filed under a genre or
+// tag, oldest first
+func getPaginatedTagReleases(
+	db *sql.DB,
+	t taxonomy,
+	slug string,
+	pageStr string,
+	limitStr string,
+	defaultPageSize int,
+	request *http.Request,
+) ([]Release, Pagination, error) {
+	var totalCount int
+	err := db.QueryRow("SELECT COUNT(*) FROM releases WHERE id IN ("+t.releaseIds()+")", slug).Scan(&totalCount)
+	if err != nil {
+		return nil, Pagination{}, fmt.Errorf("failed to get %s releases count: %w", t.Name, err)
+	}
+
+	pagination, err := getPagination(pageStr, limitStr, defaultPageSize, totalCount, request)
+	if err != nil {
+		return nil, Pagination{}, err
+	}
+
+	rows, err := db.Query(`
+		SELECT id FROM releases
+		WHERE id IN (`+t.releaseIds()+`)
+		ORDER BY year, name, id
+		LIMIT ?
+		OFFSET ?;
+	`, slug, pagination.Limit, pagination.Offset)
+	if err != nil {
+		return nil, Pagination{}, err
+	}
+	defer rows.Close()
+
+	var releaseIds []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, Pagination{}, err
+		}
+		releaseIds = append(releaseIds, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Pagination{}, err
+	}
+
+	releases, err := getReleasesByIds(db, releaseIds)
+	return releases, pagination, err
+}
+
+type TagInput struct {
+	Name string `json:"name" form:"name"`
+	// ParentId makes a genre a style of another genre, 0 for none
+	ParentId int `json:"parent_id" form:"parent_id"`
+}
+
+func (input *TagInput) validate(db *sql.DB, t taxonomy, tagId int) error {
+	input.Name = strings.Join(strings.Fields(input.Name), " ")
+	if !t.Styles {
+		input.ParentId = 0
+	}
+	fields := map[string]string{}
+
+	slug := slugify(input.Name)
+	switch {
+	case input.Name == "":
+		fields["name"] = "Name is required"
+	case slug == "":
+		fields["name"] = "Name needs letters or digits"
+	case len(input.Name) > maxTagLength:
+		fields["name"] = fmt.Sprintf("Name can be at most %d characters", maxTagLength)
+	default:
+		var existing string
+		err := db.QueryRow("SELECT name FROM "+t.Plural+" WHERE slug = ? AND id != ?", slug, tagId).Scan(&existing)
+		if err == nil {
+			fields["name"] = fmt.Sprintf("There's already a %s called %s", t.Name, existing)
+		} else if err != sql.ErrNoRows {
+			return err
+		}
+	}
+
+	if input.ParentId != 0 {
+		parent, err := getTag(db, t, input.ParentId)
+		switch {
+		case err == errNotFound:
+			fields["parent_id"] = fmt.Sprintf("Genre %d does not exist", input.ParentId)
+		case err != nil:
+			return err
+		case parent.Id == tagId:
+			fields["parent_id"] = "A genre can't be a style of itself"
+		case parent.ParentId != 0:
+			fields["parent_id"] = fmt.Sprintf("%s is a style, so it can't have styles", parent.Name)
+		}
+
+		var hasStyles bool
+		err = db.QueryRow("SELECT EXISTS (SELECT 1 FROM genres WHERE parent_id = ?)", tagId).Scan(&hasStyles)
+		if err != nil {
+			return err
+		}
+		if hasStyles && fields["parent_id"] == "" {
+			fields["parent_id"] = "A genre with styles can't be a style"
+		}
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+func createTag(db *sql.DB, t taxonomy, input TagInput) (Tag, error) {
+	if err := input.validate(db, t, 0); err != nil {
+		return Tag{}, err
+	}
+
+	var result sql.Result
+	var err error
+	if t.Styles {
+		result, err = db.Exec("INSERT INTO genres (name, slug, parent_id) VALUES (?, ?, NULLIF(?, 0))", input.Name, slugify(input.Name), input.ParentId)
+	} else {
+		result, err = db.Exec("INSERT INTO "+t.Plural+" (name, slug) VALUES (?, ?)", input.Name, slugify(input.Name))
+	}
+	if err != nil {
+		return Tag{}, err
+	}
+
+	tagId, err := result.LastInsertId()
+	if err != nil {
+		return Tag{}, err
+	}
+
+	return getTag(db, t, int(tagId))
+}
+
+// updateTag renames a genre or tag, which changes its slug, and sets the
+// genre a style belongs to
+func updateTag(db *sql.DB, t taxonomy, tagId int, input TagInput) (Tag, error) {
+	if err := input.validate(db, t, tagId); err != nil {
+		return Tag{}, err
+	}
+
+	var result sql.Result
+	var err error
+	if t.Styles {
+		result, err = db.Exec("UPDATE genres SET name = ?, slug = ?, parent_id = NULLIF(?, 0) WHERE id = ?", input.Name, slugify(input.Name), input.ParentId, tagId)
+	} else {
+		result, err = db.Exec("UPDATE "+t.Plural+" SET name = ?, slug = ? WHERE id = ?", input.Name, slugify(input.Name), tagId)
+	}
+	if err != nil {
+		return Tag{}, err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return Tag{}, err
+	} else if affected == 0 {
+		return Tag{}, errNotFound
+	}
+
+	return getTag(db, t, tagId)
+}
+
+// deleteTag removes a genre or tag from every release. The styles of a
+// deleted genre become genres.
+func deleteTag(db *sql.DB, t taxonomy, tagId int) error {
+	result, err := db.Exec("DELETE FROM "+t.Plural+" WHERE id = ?", tagId)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+// mergeTag files the releases of a genre or tag under another and deletes
+// it, for cleaning up duplicates like "Hip Hop" and "Hip-Hop Music". The
+// styles of a merged genre move to the genre it's merged into, or to that
+// genre's own genre when it's a style.
+func mergeTag(db *sql.DB, t taxonomy, tagId int, intoId int) (Tag, error) {
+	if _, err := getTag(db, t, tagId); err != nil {
+		return Tag{}, err
+	}
+	switch intoId {
+	case 0:
+		return Tag{}, &ValidationError{Fields: map[string]string{"into_id": fmt.Sprintf("Pick a %s to merge into", t.Name)}}
+	case tagId:
+		return Tag{}, &ValidationError{Fields: map[string]string{"into_id": fmt.Sprintf("Pick another %s to merge into", t.Name)}}
+	}
+	into, err := getTag(db, t, intoId)
+	if err == errNotFound {
+		return Tag{}, &ValidationError{Fields: map[string]string{"into_id": fmt.Sprintf("%s %d does not exist", t.SingularTitle(), intoId)}}
+	}
+	if err != nil {
+		return Tag{}, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return Tag{}, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"INSERT OR IGNORE INTO "+t.joinTable+" (release_id, "+t.column+") SELECT release_id, ? FROM "+t.joinTable+" WHERE "+t.column+" = ?",
+		intoId, tagId,
+	)
+	if err != nil {
+		return Tag{}, err
+	}
+
+	if t.Styles {
+		genreId := into.Id
+		if into.ParentId != 0 && into.ParentId != tagId {
+			genreId = into.ParentId
+		}
+		if _, err := tx.Exec("UPDATE genres SET parent_id = ? WHERE parent_id = ? AND id != ?", genreId, tagId, genreId); err != nil {
+			return Tag{}, err
+		}
+	}
+
+	// The delete triggers remove the merged term's links and make a style it
+	// was merged into a genre
+	if _, err := tx.Exec("DELETE FROM "+t.Plural+" WHERE id = ?", tagId); err != nil {
+		return Tag{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Tag{}, err
+	}
+	return getTag(db, t, intoId)
+}
@@ -0,0 +1,257 @@
+package internal
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlugify(t *testing.T) {
+	assert.Equal(t, "drum-bass", slugify("Drum & Bass"))
+	assert.Equal(t, "hip-hop", slugify(" Hip-Hop "))
+	assert.Equal(t, "hip-hop", slugify("hip hop"))
+	assert.Equal(t, "musique-concrète", slugify("Musique Concrète"))
+	assert.Equal(t, "80s", slugify("'80s"))
+	assert.Equal(t, "", slugify("&!"))
+}
+
+func TestNormalizeTagNames(t *testing.T) {
+	names, problem := normalizeTagNames([]string{"live,  bootleg ,", "Live", "first   pressing"})
+	assert.Equal(t, []string{"live", "bootleg", "first pressing"}, names)
+	assert.Empty(t, problem)
+
+	names, problem = normalizeTagNames([]string{"live, ?!"})
+	assert.Equal(t, []string{"live"}, names)
+	assert.Equal(t, `Tag "?!" needs letters or digits`, problem)
+}
+
+func TestTagCloud(t *testing.T) {
+	cloud := tagCloud([]TagSummary{
+		{Tag: Tag{Name: "live"}, ReleaseCount: 100},
+		{Tag: Tag{Name: "bootleg"}, ReleaseCount: 10},
+		{Tag: Tag{Name: "unused"}, ReleaseCount: 0},
+		{Tag: Tag{Name: "rare"}, ReleaseCount: 1},
+	})
+	if assert.Len(t, cloud, 3) {
+		assert.Equal(t, tagCloudSizes, cloud[0].Size)
+		assert.Equal(t, 3, cloud[1].Size)
+		assert.Equal(t, 1, cloud[2].Size)
+	}
+
+	// A single release doesn't divide by log(1)
+	cloud = tagCloud([]TagSummary{{Tag: Tag{Name: "live"}, ReleaseCount: 1}})
+	assert.Equal(t, 1, cloud[0].Size)
+}
+
+func TestReleaseGenresAndTags(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Yes'), (2, 'Miles Davis')")
+	mustExec(t, db, "INSERT INTO genres (id, name, slug, parent_id) VALUES (1, 'Rock', 'rock', NULL), (2, 'Prog Rock', 'prog-rock', 1), (3, 'Jazz', 'jazz', NULL)")
+
+	var closeToTheEdge Release
+
+	t.Run("Create With Genres And Tags", func(t *testing.T) {
+		var err error
+		closeToTheEdge, err = createRelease(db, ReleaseInput{
+			Name:      "Close to the Edge",
+			Year:      1972,
+			ArtistIds: []int{1},
+			GenreIds:  []int{2, 2},
+			Tags:      []string{"Concept Album, gatefold", "concept album"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []Tag{{Id: 2, Name: "Prog Rock", Slug: "prog-rock", ParentId: 1}}, closeToTheEdge.Genres)
+		assert.Equal(t, []Tag{{Id: 1, Name: "Concept Album", Slug: "concept-album"}, {Id: 2, Name: "gatefold", Slug: "gatefold"}}, closeToTheEdge.Tags)
+
+		// Tags are matched by slug
+		_, err = createRelease(db, ReleaseInput{
+			Name:      "Bitches Brew",
+			Year:      1970,
+			ArtistIds: []int{2},
+			GenreIds:  []int{3},
+			Tags:      []string{"Gatefold"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, countRows(t, db, "tags"))
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		_, err := createRelease(db, ReleaseInput{Name: "Fragile", Year: 1971, GenreIds: []int{99}, Tags: []string{"--"}})
+		var validationErr *ValidationError
+		if assert.True(t, errors.As(err, &validationErr)) {
+			assert.Equal(t, map[string]string{
+				"genre_ids": "Genre 99 does not exist",
+				"tags":      `Tag "--" needs letters or digits`,
+			}, validationErr.Fields)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		// Releases filed under a style are filed under its genre too
+		assert.Equal(t, []string{"Yes"}, searchCredits(t, db, "genre:rock"))
+		assert.Equal(t, []string{"Yes"}, searchCredits(t, db, `genre:"Prog Rock"`))
+		assert.Equal(t, []string{"Miles Davis"}, searchCredits(t, db, "-genre:rock"))
+		assert.Equal(t, []string{"Miles Davis", "Yes"}, searchCredits(t, db, "tag:gatefold"))
+		assert.Equal(t, []string{"Yes"}, searchCredits(t, db, "tag:concept-album"))
+		assert.Equal(t, []string{"Miles Davis"}, searchCredits(t, db, "tag:gatefold -tag:concept_album"))
+		assert.Empty(t, searchCredits(t, db, "tag:unknown"))
+	})
+
+	t.Run("Counts And Browsing", func(t *testing.T) {
+		genres, err := getTags(db, genreTaxonomy)
+		assert.NoError(t, err)
+		assert.Equal(t, []TagSummary{
+			{Tag: Tag{Id: 3, Name: "Jazz", Slug: "jazz"}, ReleaseCount: 1},
+			{Tag: Tag{Id: 2, Name: "Prog Rock", Slug: "prog-rock", ParentId: 1}, ReleaseCount: 1},
+			{Tag: Tag{Id: 1, Name: "Rock", Slug: "rock"}, ReleaseCount: 1},
+		}, genres)
+
+		detail, err := getTagDetail(db, genreTaxonomy, "rock")
+		assert.NoError(t, err)
+		assert.Equal(t, []Tag{{Id: 2, Name: "Prog Rock", Slug: "prog-rock", ParentId: 1}}, detail.Styles)
+		detail, err = getTagDetail(db, genreTaxonomy, "prog-rock")
+		assert.NoError(t, err)
+		assert.Equal(t, "Rock", detail.Parent.Name)
+		_, err = getTagDetail(db, tagTaxonomy, "rock")
+		assert.ErrorIs(t, err, errNotFound)
+
+		request := &http.Request{URL: &url.URL{Path: "/tags/gatefold", RawQuery: "page_size=1"}}
+		releases, pagination, err := getPaginatedTagReleases(db, tagTaxonomy, "gatefold", "1", "1", 10, request)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, pagination.TotalCount)
+		if assert.Len(t, releases, 1) {
+			assert.Equal(t, "Bitches Brew", releases[0].Name)
+		}
+		if assert.NotNil(t, pagination.NextUrl) {
+			assert.Equal(t, "/tags/gatefold?page=2&page_size=1", *pagination.NextUrl)
+		}
+	})
+
+	t.Run("Update Replaces Genres And Tags", func(t *testing.T) {
+		release, err := updateRelease(db, closeToTheEdge.Id, ReleaseInput{
+			Name:      "Close to the Edge",
+			Year:      1972,
+			ArtistIds: []int{1},
+			GenreIds:  []int{1},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "rock", release.Genres[0].Slug)
+		assert.Empty(t, release.Tags)
+		assert.Empty(t, searchCredits(t, db, "genre:prog-rock"))
+	})
+
+	t.Run("Deletes Remove Links", func(t *testing.T) {
+		assert.NoError(t, deleteRelease(db, closeToTheEdge.Id))
+		assert.Equal(t, 1, countRows(t, db, "release_genres"))
+
+		assert.NoError(t, deleteTag(db, genreTaxonomy, 3))
+		assert.Equal(t, 0, countRows(t, db, "release_genres"))
+		assert.ErrorIs(t, deleteTag(db, genreTaxonomy, 3), errNotFound)
+
+		// Styles of a deleted genre become genres
+		assert.NoError(t, deleteTag(db, genreTaxonomy, 1))
+		style, err := getTag(db, genreTaxonomy, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, style.ParentId)
+	})
+}
+
+func TestTagAdmin(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Paid in Full', 1987), (2, 'Illmatic', 1994)")
+
+	var hipHop, rap, boomBap Tag
+
+	t.Run("Create", func(t *testing.T) {
+		var err error
+		hipHop, err = createTag(db, genreTaxonomy, TagInput{Name: " Hip  Hop "})
+		assert.NoError(t, err)
+		assert.Equal(t, Tag{Id: 1, Name: "Hip Hop", Slug: "hip-hop"}, hipHop)
+
+		rap, err = createTag(db, genreTaxonomy, TagInput{Name: "Rap"})
+		assert.NoError(t, err)
+		boomBap, err = createTag(db, genreTaxonomy, TagInput{Name: "Boom Bap", ParentId: rap.Id})
+		assert.NoError(t, err)
+		assert.Equal(t, rap.Id, boomBap.ParentId)
+
+		// Tags can't have a parent
+		tag, err := createTag(db, tagTaxonomy, TagInput{Name: "Boom Bap", ParentId: rap.Id})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, tag.ParentId)
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		for _, test := range []struct {
+			tagId    int
+			input    TagInput
+			expected map[string]string
+		}{
+			{0, TagInput{Name: "  "}, map[string]string{"name": "Name is required"}},
+			{0, TagInput{Name: "&"}, map[string]string{"name": "Name needs letters or digits"}},
+			{0, TagInput{Name: "hip-hop"}, map[string]string{"name": "There's already a genre called Hip Hop"}},
+			{0, TagInput{Name: "Trap", ParentId: 99}, map[string]string{"parent_id": "Genre 99 does not exist"}},
+			{0, TagInput{Name: "Trap", ParentId: boomBap.Id}, map[string]string{"parent_id": "Boom Bap is a style, so it can't have styles"}},
+			{rap.Id, TagInput{Name: "Rap", ParentId: rap.Id}, map[string]string{"parent_id": "A genre can't be a style of itself"}},
+			{rap.Id, TagInput{Name: "Rap", ParentId: hipHop.Id}, map[string]string{"parent_id": "A genre with styles can't be a style"}},
+		} {
+			var err error
+			if test.tagId == 0 {
+				_, err = createTag(db, genreTaxonomy, test.input)
+			} else {
+				_, err = updateTag(db, genreTaxonomy, test.tagId, test.input)
+			}
+			var validationErr *ValidationError
+			if assert.True(t, errors.As(err, &validationErr), test.input.Name) {
+				assert.Equal(t, test.expected, validationErr.Fields)
+			}
+		}
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		tag, err := updateTag(db, genreTaxonomy, hipHop.Id, TagInput{Name: "Hip-Hop"})
+		assert.NoError(t, err)
+		assert.Equal(t, "hip-hop", tag.Slug)
+
+		_, err = updateTag(db, genreTaxonomy, 99, TagInput{Name: "Trap"})
+		assert.ErrorIs(t, err, errNotFound)
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		mustExec(t, db, "INSERT INTO release_genres (release_id, genre_id) VALUES (1, ?), (1, ?), (2, ?)", rap.Id, hipHop.Id, rap.Id)
+
+		_, err := mergeTag(db, genreTaxonomy, rap.Id, rap.Id)
+		var validationErr *ValidationError
+		if assert.True(t, errors.As(err, &validationErr)) {
+			assert.Equal(t, "Pick another genre to merge into", validationErr.Fields["into_id"])
+		}
+		_, err = mergeTag(db, genreTaxonomy, 99, rap.Id)
+		assert.ErrorIs(t, err, errNotFound)
+
+		// The releases and styles of Rap move to Hip-Hop
+		into, err := mergeTag(db, genreTaxonomy, rap.Id, hipHop.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, hipHop.Id, into.Id)
+
+		genres, err := getTags(db, genreTaxonomy)
+		assert.NoError(t, err)
+		assert.Equal(t, []TagSummary{
+			{Tag: Tag{Id: boomBap.Id, Name: "Boom Bap", Slug: "boom-bap", ParentId: hipHop.Id}, ReleaseCount: 0},
+			{Tag: Tag{Id: hipHop.Id, Name: "Hip-Hop", Slug: "hip-hop"}, ReleaseCount: 2},
+		}, genres)
+		assert.Equal(t, 2, countRows(t, db, "release_genres"))
+	})
+
+	t.Run("Merge Into A Style", func(t *testing.T) {
+		// Merging a genre into its own style makes the style a genre
+		into, err := mergeTag(db, genreTaxonomy, hipHop.Id, boomBap.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, into.ParentId)
+		request := &http.Request{URL: &url.URL{Path: "/genres/boom-bap"}}
+		releases, _, err := getPaginatedTagReleases(db, genreTaxonomy, "boom-bap", "", "", 10, request)
+		assert.NoError(t, err)
+		assert.Len(t, releases, 2)
+	})
+}
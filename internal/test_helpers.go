@@ -14,10 +14,28 @@ func createTestTables(db *sql.DB) error {
 	}
 
 	_, err = tx.Exec(`
+	CREATE TABLE masters (
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL
+	);
+
 	CREATE TABLE releases (
 		id INTEGER PRIMARY KEY,
 		name TEXT NOT NULL,
-		year INTEGER NOT NULL
+		year INTEGER NOT NULL,
+		barcode TEXT,
+		master_id INTEGER REFERENCES masters(id),
+		country TEXT NOT NULL DEFAULT '',
+		release_date TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE release_formats (
+		id INTEGER PRIMARY KEY,
+		release_id INTEGER NOT NULL REFERENCES releases(id),
+		name TEXT NOT NULL,
+		quantity INTEGER NOT NULL DEFAULT 1,
+		descriptors TEXT NOT NULL DEFAULT '',
+		position INTEGER NOT NULL DEFAULT 0
 	);
 
 	CREATE TABLE artists (
@@ -28,7 +46,11 @@ func createTestTables(db *sql.DB) error {
 	CREATE TABLE release_artists (
 		id INTEGER PRIMARY KEY,
 		release_id INTEGER NOT NULL REFERENCES releases(id),
-		artist_id INTEGER NOT NULL REFERENCES artists(id)
+		artist_id INTEGER NOT NULL REFERENCES artists(id),
+		role TEXT NOT NULL DEFAULT 'main' CHECK (role IN ('main', 'featuring', 'producer', 'remixer')),
+		position INTEGER NOT NULL DEFAULT 0,
+		join_phrase TEXT NOT NULL DEFAULT '',
+		UNIQUE (release_id, artist_id, role)
 	);
 
 	CREATE VIRTUAL TABLE releases_fts USING fts5
@@ -36,9 +58,114 @@ func createTestTables(db *sql.DB) error {
 		release_id UNINDEXED,
 		release_name,
 		release_year,
-		artist_name,
+		artist_names,
+		track_titles,
+		label_names,
+		artist_credit,
 		tokenize="trigram"
 	);
+
+	CREATE TABLE tracks (
+		id INTEGER PRIMARY KEY,
+		release_id INTEGER NOT NULL REFERENCES releases(id),
+		disc INTEGER NOT NULL DEFAULT 1,
+		position INTEGER NOT NULL,
+		title TEXT NOT NULL,
+		duration INTEGER,
+		UNIQUE (release_id, disc, position)
+	);
+
+	CREATE TABLE track_artists (
+		id INTEGER PRIMARY KEY,
+		track_id INTEGER NOT NULL REFERENCES tracks(id),
+		artist_id INTEGER NOT NULL REFERENCES artists(id)
+	);
+
+	CREATE TABLE labels (
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL
+	);
+
+	CREATE TABLE release_labels (
+		id INTEGER PRIMARY KEY,
+		release_id INTEGER NOT NULL REFERENCES releases(id),
+		label_id INTEGER NOT NULL REFERENCES labels(id),
+		catno TEXT NOT NULL DEFAULT '',
+		catno_key TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE genres (
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		slug TEXT NOT NULL UNIQUE,
+		parent_id INTEGER REFERENCES genres(id)
+	);
+
+	CREATE TABLE tags (
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		slug TEXT NOT NULL UNIQUE
+	);
+
+	CREATE TABLE release_genres (
+		id INTEGER PRIMARY KEY,
+		release_id INTEGER NOT NULL REFERENCES releases(id),
+		genre_id INTEGER NOT NULL REFERENCES genres(id),
+		UNIQUE (release_id, genre_id)
+	);
+
+	CREATE TABLE release_tags (
+		id INTEGER PRIMARY KEY,
+		release_id INTEGER NOT NULL REFERENCES releases(id),
+		tag_id INTEGER NOT NULL REFERENCES tags(id),
+		UNIQUE (release_id, tag_id)
+	);
+
+	CREATE VIEW releases_fts_rows AS
+	SELECT
+		releases.id AS release_id,
+		releases.name AS release_name,
+		releases.year AS release_year,
+		COALESCE((SELECT group_concat(name, char(10) ORDER BY position, id) FROM (
+			SELECT artists.name, MIN(release_artists.position) AS position, MIN(release_artists.id) AS id
+			FROM release_artists
+			JOIN artists ON artists.id = release_artists.artist_id
+			WHERE release_artists.release_id = releases.id
+			GROUP BY artists.id
+		)), '') AS artist_names,
+		(SELECT group_concat(tracks.title, char(10)) FROM tracks WHERE tracks.release_id = releases.id) AS track_titles,
+		(SELECT group_concat(labels.name, char(10)) FROM labels WHERE labels.id IN (
+			SELECT release_labels.label_id FROM release_labels WHERE release_labels.release_id = releases.id
+		)) AS label_names,
+		COALESCE((SELECT group_concat(credited, '' ORDER BY position, id) FROM (
+			SELECT
+				credits.id,
+				credits.position,
+				credited_artists.name || CASE
+					WHEN lead(credits.id) OVER credit_order IS NULL THEN ''
+					WHEN credits.join_phrase = '' AND lead(credits.role) OVER credit_order = 'featuring' THEN ' feat. '
+					WHEN credits.join_phrase IN ('', ',') THEN ', '
+					ELSE ' ' || credits.join_phrase || ' '
+				END AS credited
+			FROM release_artists AS credits
+			JOIN artists AS credited_artists ON credited_artists.id = credits.artist_id
+			WHERE credits.release_id = releases.id
+			  AND credits.role IN ('main', 'featuring')
+			WINDOW credit_order AS (ORDER BY credits.position, credits.id)
+		)), '') AS artist_credit
+	FROM releases;
+
+	CREATE VIRTUAL TABLE artists_fts USING fts5
+	(
+		name,
+		tokenize="trigram"
+	);
+
+	CREATE TABLE fts_sync (
+		paused INTEGER NOT NULL DEFAULT 0
+	);
+
+	INSERT INTO fts_sync (paused) VALUES (0);
 	`)
 	if err != nil {
 		tx.Rollback()
@@ -55,6 +182,30 @@ func createTestTables(db *sql.DB) error {
 	return nil
 }
 
+// seedMultiArtistReleases adds two releases credited to several of the
+// seeded artists, on top of seedTestReleases, seedTestArtists and
+// seedTestReleaseArtists. Release 31 is credited to artists 1 and 2, and
+// release 32 to artists 1, 2 and 3 with artist 1 also credited as producer.
+func seedMultiArtistReleases(db *sql.DB) error {
+	_, err := db.Exec(`
+	INSERT INTO releases (id, name, year) VALUES
+		(31, 'Under Pressure', 2021),
+		(32, 'Three Way Split', 2022);
+
+	INSERT INTO release_artists (id, release_id, artist_id, role, position, join_phrase) VALUES
+		(31, 31, 1, 'main', 1, '&'),
+		(32, 31, 2, 'main', 2, ''),
+		(33, 32, 1, 'main', 1, ''),
+		(34, 32, 2, 'main', 2, ''),
+		(35, 32, 3, 'featuring', 3, ''),
+		(36, 32, 1, 'producer', 4, '');
+	`)
+	if err != nil {
+		return fmt.Errorf("Failed to seed multi-artist releases: %v", err)
+	}
+	return nil
+}
+
 func cleanupTestDB(db *sql.DB) {
 	err := db.Close()
 	if err != nil {
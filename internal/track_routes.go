@@ -0,0 +1,160 @@
+package internal
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+func setupTrackRoutes(e *echo.Echo, db *sql.DB) {
+	// renderTrackForm renders the create/edit form, or just the form partial for HTMX requests
+	renderTrackForm := func(c echo.Context, status int, release Release, trackId int, input TrackInput, fieldErrors map[string]string) error {
+		artists, err := getAllArtists(db)
+		if err != nil {
+			e.Logger.Printf("Failed to get artists: %v", err)
+			return c.String(http.StatusInternalServerError, "Failed to load artists")
+		}
+
+		selectedArtists := map[int]bool{}
+		for _, artistId := range input.ArtistIds {
+			selectedArtists[artistId] = true
+		}
+
+		title := "New Track"
+		if trackId != 0 {
+			title = "Edit Track"
+		}
+
+		data := map[string]interface{}{
+			"Title":           title,
+			"Release":         release,
+			"TrackId":         trackId,
+			"Input":           input,
+			"Errors":          fieldErrors,
+			"Artists":         artists,
+			"SelectedArtists": selectedArtists,
+			"IncludeHTMX":     true,
+			"CurrentRoute":    c.Request().URL.Path,
+		}
+
+		if isHtmxRequest(c) {
+			return c.Render(status, "track_form_partial", data)
+		}
+		return c.Render(status, "track_form", data)
+	}
+
+	// handleTrackError re-renders the form for validation errors and maps everything else to a status
+	handleTrackError := func(c echo.Context, err error, release Release, trackId int, input TrackInput) error {
+		var validationErr *ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			return renderTrackForm(c, invalidFormStatus(c), release, trackId, input, validationErr.Fields)
+		case errors.Is(err, errNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Track not found")
+		default:
+			e.Logger.Printf("Failed to save track: %v", err)
+			return c.String(http.StatusInternalServerError, "Failed to save track")
+		}
+	}
+
+	// trackRelease loads the release in the path, or fails with a 404
+	trackRelease := func(c echo.Context) (Release, error) {
+		releaseId, err := paramId(c)
+		if err != nil {
+			return Release{}, echo.NewHTTPError(http.StatusNotFound, "Release not found")
+		}
+		release, err := getRelease(db, releaseId)
+		if errors.Is(err, errNotFound) {
+			return Release{}, echo.NewHTTPError(http.StatusNotFound, "Release not found")
+		}
+		return release, err
+	}
+
+	e.GET("/releases/:id/tracks/new", func(c echo.Context) error {
+		release, err := trackRelease(c)
+		if err != nil {
+			return err
+		}
+		return renderTrackForm(c, http.StatusOK, release, 0, TrackInput{}, nil)
+	})
+
+	e.POST("/releases/:id/tracks", func(c echo.Context) error {
+		release, err := trackRelease(c)
+		if err != nil {
+			return err
+		}
+
+		var input TrackInput
+		if err := c.Bind(&input); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid track")
+		}
+
+		if _, err := createTrack(db, release.Id, input); err != nil {
+			return handleTrackError(c, err, release, 0, input)
+		}
+
+		return redirectAfterSubmit(c, "/releases/"+strconv.Itoa(release.Id))
+	})
+
+	e.GET("/releases/:id/tracks/:track_id/edit", func(c echo.Context) error {
+		release, err := trackRelease(c)
+		if err != nil {
+			return err
+		}
+		trackId, err := paramNamedId(c, "track_id")
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Track not found")
+		}
+
+		track, err := getTrack(db, release.Id, trackId)
+		if err != nil {
+			return handleTrackError(c, err, release, trackId, TrackInput{})
+		}
+
+		return renderTrackForm(c, http.StatusOK, release, trackId, track.input(), nil)
+	})
+
+	e.PUT("/releases/:id/tracks/:track_id", func(c echo.Context) error {
+		release, err := trackRelease(c)
+		if err != nil {
+			return err
+		}
+		trackId, err := paramNamedId(c, "track_id")
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Track not found")
+		}
+
+		var input TrackInput
+		if err := c.Bind(&input); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid track")
+		}
+
+		if _, err := updateTrack(db, release.Id, trackId, input); err != nil {
+			return handleTrackError(c, err, release, trackId, input)
+		}
+
+		return redirectAfterSubmit(c, "/releases/"+strconv.Itoa(release.Id))
+	})
+
+	e.DELETE("/releases/:id/tracks/:track_id", func(c echo.Context) error {
+		releaseId, err := paramId(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Track not found")
+		}
+		trackId, err := paramNamedId(c, "track_id")
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Track not found")
+		}
+
+		if err := deleteTrack(db, releaseId, trackId); err != nil {
+			return handleTrackError(c, err, Release{}, trackId, TrackInput{})
+		}
+
+		// Reload the release page so the tracklist and search stay correct
+		c.Response().Header().Set("HX-Refresh", "true")
+		return c.NoContent(http.StatusOK)
+	})
+}
@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrackFormRoutes(t *testing.T) {
+	e := echo.New()
+	e.Renderer = &Template{TemplateDir: "./templates"}
+
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'Bowie')")
+	mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Hot Space', 1982)")
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1)")
+	SetupRoutes(e, db, DefaultConfig())
+
+	submit := func(method string, target string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		req.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("GET /releases/:id/tracks/new", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/releases/1/tracks/new", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "New Track")
+		assert.Contains(t, rec.Body.String(), `hx-post="/releases/1/tracks"`)
+
+		req = httptest.NewRequest(http.MethodGet, "/releases/999/tracks/new", nil)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("POST /releases/:id/tracks", func(t *testing.T) {
+		rec := submit(http.MethodPost, "/releases/1/tracks", url.Values{
+			"title":      {"Under Pressure"},
+			"duration":   {"4:08"},
+			"artist_ids": {"1", "2"},
+		})
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "/releases/1", rec.Header().Get("HX-Redirect"))
+		assert.Equal(t, []string{"Queen"}, searchCredits(t, db, "track:pressure"))
+	})
+
+	t.Run("POST /releases/:id/tracks with invalid track", func(t *testing.T) {
+		rec := submit(http.MethodPost, "/releases/1/tracks", url.Values{
+			"title":    {""},
+			"position": {"1"},
+		})
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("HX-Redirect"))
+		assert.Contains(t, rec.Body.String(), "Title is required")
+		assert.Contains(t, rec.Body.String(), "Disc 1 already has a track 1")
+		assert.NotContains(t, rec.Body.String(), "<html")
+
+		rec = submit(http.MethodPost, "/releases/1/tracks", url.Values{
+			"title":    {"Cool Cat"},
+			"duration": {"4 minutes"},
+		})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("GET /releases/:id shows the tracklist", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/releases/1", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		body := rec.Body.String()
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, body, "Under Pressure")
+		assert.Contains(t, body, "4:08")
+		assert.Contains(t, body, `href="/artists/2" class="hover:underline">Bowie</a>`)
+		assert.Contains(t, body, `href="/releases/1/tracks/1/edit"`)
+		assert.NotContains(t, body, "Disc 1")
+	})
+
+	t.Run("GET /releases/:id/tracks/:track_id/edit", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/releases/1/tracks/1/edit", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `value="Under Pressure"`)
+		assert.Contains(t, rec.Body.String(), `value="4:08"`)
+		assert.Contains(t, rec.Body.String(), `<option value="2" selected>Bowie</option>`)
+
+		for _, target := range []string{"/releases/1/tracks/999/edit", "/releases/999/tracks/1/edit", "/releases/1/tracks/abc/edit"} {
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusNotFound, rec.Code, target)
+		}
+	})
+
+	t.Run("PUT /releases/:id/tracks/:track_id", func(t *testing.T) {
+		rec := submit(http.MethodPut, "/releases/1/tracks/1", url.Values{
+			"disc":     {"2"},
+			"position": {"1"},
+			"title":    {"Under Pressure"},
+		})
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "/releases/1", rec.Header().Get("HX-Redirect"))
+
+		track, err := getTrack(db, 1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, track.Disc)
+		assert.Equal(t, Duration(0), track.Duration)
+		assert.Empty(t, track.Artists)
+	})
+
+	t.Run("DELETE /releases/:id/tracks/:track_id", func(t *testing.T) {
+		rec := submit(http.MethodDelete, "/releases/1/tracks/1", nil)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "true", rec.Header().Get("HX-Refresh"))
+		assert.Empty(t, searchCredits(t, db, "track:pressure"))
+
+		rec = submit(http.MethodDelete, "/releases/1/tracks/1", nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -0,0 +1,372 @@
+package internal
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Duration is a track length in seconds, 0 when unknown. Forms and JSON
+// accept seconds or minutes and seconds like 4:03 or 1:02:03.
+type Duration int
+
+// maxDuration is 24 hours, longer than any track on a physical release
+const maxDuration = 24 * 60 * 60
+
+func (d Duration) String() string {
+	if d <= 0 {
+		return ""
+	}
+	hours, minutes, seconds := int(d)/3600, int(d)/60%60, int(d)%60
+	if hours > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", hours, minutes, seconds)
+	}
+	return fmt.Sprintf("%d:%02d", minutes, seconds)
+}
+
+// parseDuration parses seconds, m:ss or h:mm:ss. An empty string is an unknown duration.
+func parseDuration(s string) (Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	total := 0
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		// Minutes and seconds after the first part must have two digits
+		if err != nil || n < 0 || strings.HasPrefix(part, "+") || i > 0 && (len(part) != 2 || n > 59) {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		total = total*60 + n
+	}
+	return Duration(total), nil
+}
+
+// UnmarshalParam binds form and query values
+func (d *Duration) UnmarshalParam(param string) error {
+	duration, err := parseDuration(param)
+	if err != nil {
+		return err
+	}
+	*d = duration
+	return nil
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var seconds int
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*d = Duration(seconds)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be seconds or a string like 4:03")
+	}
+	return d.UnmarshalParam(s)
+}
+
+// MarshalJSON writes unknown durations as null
+func (d Duration) MarshalJSON() ([]byte, error) {
+	if d <= 0 {
+		return []byte("null"), nil
+	}
+	return json.Marshal(int(d))
+}
+
+type Track struct {
+	Id        int      `json:"id"`
+	ReleaseId int      `json:"release_id"`
+	Disc      int      `json:"disc"`
+	Position  int      `json:"position"`
+	Title     string   `json:"title"`
+	Duration  Duration `json:"duration"`
+	// Artists are credited on this track only, when they differ from the release's
+	Artists []Artist `json:"artists"`
+}
+
+// Disc is the tracks of one disc of a release, in order
+type Disc struct {
+	Number int
+	Tracks []Track
+}
+
+type TrackInput struct {
+	Disc      int      `json:"disc" form:"disc"`
+	Position  int      `json:"position" form:"position"`
+	Title     string   `json:"title" form:"title"`
+	Duration  Duration `json:"duration" form:"duration"`
+	ArtistIds []int    `json:"artist_ids" form:"artist_ids"`
+}
+
+// validate checks a track of releaseId, trackId 0 for a new track. Disc
+// defaults to 1 and position to the end of the disc.
+func (input *TrackInput) validate(tx *sql.Tx, releaseId int, trackId int) error {
+	input.Title = strings.TrimSpace(input.Title)
+	fields := map[string]string{}
+
+	if input.Title == "" {
+		fields["title"] = "Title is required"
+	}
+	if input.Disc == 0 {
+		input.Disc = 1
+	}
+	if input.Disc < 1 {
+		fields["disc"] = "Disc must be a positive number"
+	}
+	if input.Duration < 0 || input.Duration > maxDuration {
+		fields["duration"] = "Duration can't be negative or longer than 24 hours"
+	}
+
+	switch {
+	case input.Position < 0:
+		fields["position"] = "Position must be a positive number"
+	case input.Position == 0:
+		err := tx.QueryRow(
+			"SELECT COALESCE(MAX(position), 0) + 1 FROM tracks WHERE release_id = ? AND disc = ?",
+			releaseId, input.Disc,
+		).Scan(&input.Position)
+		if err != nil {
+			return err
+		}
+	default:
+		var taken bool
+		err := tx.QueryRow(
+			"SELECT EXISTS (SELECT 1 FROM tracks WHERE release_id = ? AND disc = ? AND position = ? AND id != ?)",
+			releaseId, input.Disc, input.Position, trackId,
+		).Scan(&taken)
+		if err != nil {
+			return err
+		}
+		if taken {
+			fields["position"] = fmt.Sprintf("Disc %d already has a track %d", input.Disc, input.Position)
+		}
+	}
+
+	for _, artistId := range input.ArtistIds {
+		var exists bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM artists WHERE id = ?)", artistId).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			fields["artist_ids"] = fmt.Sprintf("Artist %d does not exist", artistId)
+			break
+		}
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// getReleaseTracks loads the tracklist of a release with each track's artists
+func getReleaseTracks(db *sql.DB, releaseId int) ([]Track, error) {
+	return queryTracks(db, "tracks.release_id = ?", releaseId)
+}
+
+// getTrack loads a track of a release
+func getTrack(db *sql.DB, releaseId int, trackId int) (Track, error) {
+	tracks, err := queryTracks(db, "tracks.release_id = ? AND tracks.id = ?", releaseId, trackId)
+	if err != nil {
+		return Track{}, err
+	}
+	if len(tracks) == 0 {
+		return Track{}, errNotFound
+	}
+	return tracks[0], nil
+}
+
+// queryTracks loads the tracks matching where, in tracklist order
+func queryTracks(db *sql.DB, where string, args ...interface{}) ([]Track, error) {
+	rows, err := db.Query(`
+		SELECT id, release_id, disc, position, title, COALESCE(duration, 0)
+		FROM tracks
+		WHERE `+where+`
+		ORDER BY disc, position, id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tracks := []Track{}
+	byId := map[int]int{}
+	for rows.Next() {
+		track := Track{Artists: []Artist{}}
+		if err := rows.Scan(&track.Id, &track.ReleaseId, &track.Disc, &track.Position, &track.Title, &track.Duration); err != nil {
+			return nil, err
+		}
+		byId[track.Id] = len(tracks)
+		tracks = append(tracks, track)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	artistRows, err := db.Query(`
+		SELECT track_artists.track_id, artists.id, artists.name
+		FROM track_artists
+		JOIN tracks ON tracks.id = track_artists.track_id
+		JOIN artists ON artists.id = track_artists.artist_id
+		WHERE `+where+`
+		ORDER BY track_artists.id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer artistRows.Close()
+
+	for artistRows.Next() {
+		var trackId int
+		var artist Artist
+		if err := artistRows.Scan(&trackId, &artist.Id, &artist.Name); err != nil {
+			return nil, err
+		}
+		if i, ok := byId[trackId]; ok {
+			tracks[i].Artists = append(tracks[i].Artists, artist)
+		}
+	}
+	return tracks, artistRows.Err()
+}
+
+// groupDiscs splits a tracklist into its discs
+func groupDiscs(tracks []Track) []Disc {
+	var discs []Disc
+	for _, track := range tracks {
+		if len(discs) == 0 || discs[len(discs)-1].Number != track.Disc {
+			discs = append(discs, Disc{Number: track.Disc})
+		}
+		discs[len(discs)-1].Tracks = append(discs[len(discs)-1].Tracks, track)
+	}
+	return discs
+}
+
+// setTrackArtists replaces the artists credited on a track
+func setTrackArtists(tx *sql.Tx, trackId int, artistIds []int) error {
+	if _, err := tx.Exec("DELETE FROM track_artists WHERE track_id = ?", trackId); err != nil {
+		return err
+	}
+
+	seen := map[int]bool{}
+	for _, artistId := range artistIds {
+		if seen[artistId] {
+			continue
+		}
+		seen[artistId] = true
+
+		if _, err := tx.Exec("INSERT INTO track_artists (track_id, artist_id) VALUES (?, ?)", trackId, artistId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createTrack adds a track to a release. The tracks triggers reindex the
+// release so its track titles are searchable.
+func createTrack(db *sql.DB, releaseId int, input TrackInput) (Track, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return Track{}, err
+	}
+	defer tx.Rollback()
+
+	if exists, err := releaseExists(tx, releaseId); err != nil {
+		return Track{}, err
+	} else if !exists {
+		return Track{}, errNotFound
+	}
+	if err := input.validate(tx, releaseId, 0); err != nil {
+		return Track{}, err
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO tracks (release_id, disc, position, title, duration) VALUES (?, ?, ?, ?, NULLIF(?, 0))",
+		releaseId, input.Disc, input.Position, input.Title, input.Duration,
+	)
+	if err != nil {
+		return Track{}, err
+	}
+	trackId, err := result.LastInsertId()
+	if err != nil {
+		return Track{}, err
+	}
+
+	if err := setTrackArtists(tx, int(trackId), input.ArtistIds); err != nil {
+		return Track{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Track{}, err
+	}
+
+	return getTrack(db, releaseId, int(trackId))
+}
+
+func updateTrack(db *sql.DB, releaseId int, trackId int, input TrackInput) (Track, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return Track{}, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM tracks WHERE id = ? AND release_id = ?)", trackId, releaseId).Scan(&exists)
+	if err != nil {
+		return Track{}, err
+	}
+	if !exists {
+		return Track{}, errNotFound
+	}
+	if err := input.validate(tx, releaseId, trackId); err != nil {
+		return Track{}, err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE tracks SET disc = ?, position = ?, title = ?, duration = NULLIF(?, 0) WHERE id = ?",
+		input.Disc, input.Position, input.Title, input.Duration, trackId,
+	)
+	if err != nil {
+		return Track{}, err
+	}
+
+	if err := setTrackArtists(tx, trackId, input.ArtistIds); err != nil {
+		return Track{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Track{}, err
+	}
+
+	return getTrack(db, releaseId, trackId)
+}
+
+// deleteTrack removes a track of a release. Its artist credits are removed by a trigger.
+func deleteTrack(db *sql.DB, releaseId int, trackId int) error {
+	result, err := db.Exec("DELETE FROM tracks WHERE id = ? AND release_id = ?", trackId, releaseId)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+// input returns the input that saves a track unchanged, for edit forms
+func (t Track) input() TrackInput {
+	input := TrackInput{Disc: t.Disc, Position: t.Position, Title: t.Title, Duration: t.Duration}
+	for _, artist := range t.Artists {
+		input.ArtistIds = append(input.ArtistIds, artist.Id)
+	}
+	return input
+}
@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuration(t *testing.T) {
+	for input, expected := range map[string]Duration{
+		"":        0,
+		"245":     245,
+		"4:05":    245,
+		" 0:59 ":  59,
+		"1:02:03": 3723,
+	} {
+		duration, err := parseDuration(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, duration, input)
+	}
+
+	for _, input := range []string{"4:5", "4:60", "-3", "+3", "1:2:3:4", "four", "4:"} {
+		_, err := parseDuration(input)
+		assert.Error(t, err, input)
+	}
+
+	assert.Equal(t, "", Duration(0).String())
+	assert.Equal(t, "0:07", Duration(7).String())
+	assert.Equal(t, "4:05", Duration(245).String())
+	assert.Equal(t, "1:02:03", Duration(3723).String())
+
+	var input TrackInput
+	assert.NoError(t, json.Unmarshal([]byte(`{"duration": "4:05"}`), &input))
+	assert.Equal(t, Duration(245), input.Duration)
+	assert.NoError(t, json.Unmarshal([]byte(`{"duration": 300}`), &input))
+	assert.Equal(t, Duration(300), input.Duration)
+	assert.Error(t, json.Unmarshal([]byte(`{"duration": true}`), &input))
+
+	data, err := json.Marshal(Track{Duration: 0})
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"duration":null`)
+}
+
+func TestTrackCrud(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'Bowie')")
+	mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Hot Space', 1982)")
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1)")
+
+	t.Run("Create Appends To The Disc", func(t *testing.T) {
+		first, err := createTrack(db, 1, TrackInput{Title: "Staying Power", Duration: 250})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, first.Disc)
+		assert.Equal(t, 1, first.Position)
+
+		second, err := createTrack(db, 1, TrackInput{Title: " Under Pressure ", ArtistIds: []int{1, 2, 2}})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, second.Position)
+		assert.Equal(t, "Under Pressure", second.Title)
+		assert.Equal(t, []Artist{{Id: 1, Name: "Queen"}, {Id: 2, Name: "Bowie"}}, second.Artists)
+
+		bonus, err := createTrack(db, 1, TrackInput{Disc: 2, Title: "Soul Brother"})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, bonus.Position)
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		_, err := createTrack(db, 1, TrackInput{Disc: -1, Position: 2, Duration: -5, ArtistIds: []int{99}})
+		var validationErr *ValidationError
+		if assert.True(t, errors.As(err, &validationErr)) {
+			assert.Equal(t, map[string]string{
+				"title":      "Title is required",
+				"disc":       "Disc must be a positive number",
+				"duration":   "Duration can't be negative or longer than 24 hours",
+				"artist_ids": "Artist 99 does not exist",
+			}, validationErr.Fields)
+		}
+
+		_, err = createTrack(db, 1, TrackInput{Position: 2, Title: "Cool Cat"})
+		if assert.True(t, errors.As(err, &validationErr)) {
+			assert.Equal(t, "Disc 1 already has a track 2", validationErr.Fields["position"])
+		}
+
+		_, err = createTrack(db, 999, TrackInput{Title: "Cool Cat"})
+		assert.ErrorIs(t, err, errNotFound)
+	})
+
+	t.Run("Release Detail Groups Discs", func(t *testing.T) {
+		release, err := getReleaseDetail(db, 1)
+		assert.NoError(t, err)
+		assert.Len(t, release.Tracks, 3)
+
+		discs := release.Discs()
+		if assert.Len(t, discs, 2) {
+			assert.Equal(t, 1, discs[0].Number)
+			assert.Equal(t, "Staying Power", discs[0].Tracks[0].Title)
+			assert.Equal(t, "Under Pressure", discs[0].Tracks[1].Title)
+			assert.Equal(t, 2, discs[1].Number)
+		}
+	})
+
+	t.Run("Update Keeps Its Own Position", func(t *testing.T) {
+		tracks, err := getReleaseTracks(db, 1)
+		assert.NoError(t, err)
+
+		input := tracks[1].input()
+		input.Duration = 248
+		input.ArtistIds = []int{2}
+		track, err := updateTrack(db, 1, tracks[1].Id, input)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, track.Position)
+		assert.Equal(t, Duration(248), track.Duration)
+		assert.Equal(t, []Artist{{Id: 2, Name: "Bowie"}}, track.Artists)
+
+		// Tracks only belong to their own release
+		mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (2, 'Heroes', 1977)")
+		_, err = updateTrack(db, 2, tracks[1].Id, input)
+		assert.ErrorIs(t, err, errNotFound)
+		assert.ErrorIs(t, deleteTrack(db, 2, tracks[1].Id), errNotFound)
+	})
+
+	t.Run("Deleting An Artist Removes Their Track Credits", func(t *testing.T) {
+		assert.NoError(t, deleteArtist(db, 2))
+		assert.Equal(t, 0, countRows(t, db, "track_artists"))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		tracks, err := getReleaseTracks(db, 1)
+		assert.NoError(t, err)
+
+		assert.NoError(t, deleteTrack(db, 1, tracks[2].Id))
+		assert.ErrorIs(t, deleteTrack(db, 1, tracks[2].Id), errNotFound)
+		_, err = getTrack(db, 1, tracks[2].Id)
+		assert.ErrorIs(t, err, errNotFound)
+
+		assert.NoError(t, deleteRelease(db, 1))
+		assert.Equal(t, 0, countRows(t, db, "tracks"))
+	})
+}
+
+func TestTrackSearch(t *testing.T) {
+	db := openMigratedTestDB(t)
+	mustExec(t, db, "INSERT INTO artists (id, name) VALUES (1, 'Queen'), (2, 'Bowie')")
+	mustExec(t, db, "INSERT INTO releases (id, name, year) VALUES (1, 'Hot Space', 1982), (2, 'Heroes', 1977)")
+	mustExec(t, db, "INSERT INTO release_artists (release_id, artist_id) VALUES (1, 1), (2, 2)")
+
+	track, err := createTrack(db, 1, TrackInput{Title: "Under Pressure"})
+	assert.NoError(t, err)
+	_, err = createTrack(db, 2, TrackInput{Title: "Sons of the Silent Age"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"Queen"}, searchCredits(t, db, "pressure"))
+	assert.Equal(t, []string{"Queen"}, searchCredits(t, db, "track:pressure"))
+	assert.Equal(t, []string{"Bowie"}, searchCredits(t, db, "-track:pressure"))
+	assert.Empty(t, searchCredits(t, db, "release:pressure"))
+
+	input := track.input()
+	input.Title = "Cool Cat"
+	_, err = updateTrack(db, 1, track.Id, input)
+	assert.NoError(t, err)
+	assert.Empty(t, searchCredits(t, db, "pressure"))
+	assert.Equal(t, []string{"Queen"}, searchCredits(t, db, "track:cool"))
+
+	assert.NoError(t, deleteTrack(db, 1, track.Id))
+	assert.Empty(t, searchCredits(t, db, "track:cool"))
+
+	// Reindexing keeps track titles
+	assert.NoError(t, ReindexFts(db))
+	assert.Equal(t, []string{"Bowie"}, searchCredits(t, db, "track:silent"))
+}
@@ -0,0 +1,46 @@
+DROP TRIGGER IF EXISTS releases_ai;
+DROP TRIGGER IF EXISTS releases_au;
+DROP TRIGGER IF EXISTS releases_ad;
+DROP TRIGGER IF EXISTS release_artists_ai;
+DROP TRIGGER IF EXISTS release_artists_au;
+DROP TRIGGER IF EXISTS release_artists_ad;
+DROP TRIGGER IF EXISTS artists_au;
+DROP TRIGGER IF EXISTS artists_ad;
+
+-- Restore the triggers from 000003
+CREATE TRIGGER releases_ai AFTER INSERT ON releases
+BEGIN
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id;
+END;
+
+CREATE TRIGGER releases_au AFTER UPDATE ON releases
+BEGIN
+    UPDATE releases_fts
+    SET release_name = NEW.name,
+        release_year = NEW.year
+    WHERE release_name = OLD.name AND release_year = OLD.year;
+END;
+
+CREATE TRIGGER releases_ad AFTER DELETE ON releases
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = OLD.id;
+END;
+
+CREATE TRIGGER artists_au AFTER UPDATE ON artists
+BEGIN
+    UPDATE releases_fts
+    SET artist_name = NEW.name
+    WHERE artist_name = OLD.name;
+END;
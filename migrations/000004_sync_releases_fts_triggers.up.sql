@@ -0,0 +1,187 @@
+-- Replace the triggers from 000003, which re-inserted every joined row on each
+-- release insert and matched updates by name, with triggers that rebuild the
+-- rows of the affected releases only. Each trigger deletes the release's rows
+-- and re-inserts one row per distinct release/artist pair.
+DROP TRIGGER IF EXISTS releases_ai;
+DROP TRIGGER IF EXISTS releases_au;
+DROP TRIGGER IF EXISTS releases_ad;
+DROP TRIGGER IF EXISTS artists_au;
+
+-- Trigger to update full text search table after release inserts
+CREATE TRIGGER releases_ai AFTER INSERT ON releases
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = NEW.id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT DISTINCT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id
+    WHERE releases.id = NEW.id;
+END;
+
+-- Trigger to update full text search table after release updates
+CREATE TRIGGER releases_au AFTER UPDATE ON releases
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (OLD.id, NEW.id);
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT DISTINCT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id
+    WHERE releases.id = NEW.id;
+END;
+
+-- Trigger to update full text search table after release deletes
+CREATE TRIGGER releases_ad AFTER DELETE ON releases
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = OLD.id;
+END;
+
+-- Trigger to update full text search table after release_artist inserts
+CREATE TRIGGER release_artists_ai AFTER INSERT ON release_artists
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = NEW.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT DISTINCT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id
+    WHERE releases.id = NEW.release_id;
+END;
+
+-- Trigger to update full text search table after release_artist updates
+CREATE TRIGGER release_artists_au AFTER UPDATE ON release_artists
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT DISTINCT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id
+    WHERE releases.id IN (OLD.release_id, NEW.release_id);
+END;
+
+-- Trigger to update full text search table after release_artist deletes
+CREATE TRIGGER release_artists_ad AFTER DELETE ON release_artists
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = OLD.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT DISTINCT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id
+    WHERE releases.id = OLD.release_id;
+END;
+
+-- Trigger to update full text search table after artist updates
+CREATE TRIGGER artists_au AFTER UPDATE ON artists
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (
+        SELECT release_id FROM release_artists WHERE artist_id IN (OLD.id, NEW.id)
+    );
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT DISTINCT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id
+    WHERE releases.id IN (
+        SELECT release_id FROM release_artists WHERE artist_id IN (OLD.id, NEW.id)
+    );
+END;
+
+-- Trigger to update full text search table after artist deletes
+CREATE TRIGGER artists_ad AFTER DELETE ON artists
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (
+        SELECT release_id FROM release_artists WHERE artist_id = OLD.id
+    );
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT DISTINCT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id
+    WHERE releases.id IN (
+        SELECT release_id FROM release_artists WHERE artist_id = OLD.id
+    );
+END;
+
+-- Rebuild the full text search table so rows left behind by the old triggers
+-- are dropped
+DELETE FROM releases_fts;
+
+INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+SELECT DISTINCT
+    releases.id AS release_id,
+    releases.name AS release_name,
+    releases.year AS release_year,
+    artists.name AS artist_name
+FROM
+    release_artists
+        JOIN
+    artists ON release_artists.artist_id = artists.id
+        JOIN
+    releases ON release_artists.release_id = releases.id;
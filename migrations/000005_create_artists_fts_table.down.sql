@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS artists_fts_ai;
+DROP TRIGGER IF EXISTS artists_fts_au;
+DROP TRIGGER IF EXISTS artists_fts_ad;
+DROP TABLE IF EXISTS artists_fts;
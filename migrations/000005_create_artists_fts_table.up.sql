@@ -0,0 +1,29 @@
+-- Create full text search table for artists, keyed by artist id
+CREATE VIRTUAL TABLE artists_fts USING fts5
+(
+    name,
+    tokenize="trigram"
+);
+
+-- Populate full text search table for artists
+INSERT INTO artists_fts (rowid, name)
+SELECT id, name FROM artists;
+
+-- Trigger to update full text search table after artist inserts
+CREATE TRIGGER artists_fts_ai AFTER INSERT ON artists
+BEGIN
+    INSERT INTO artists_fts (rowid, name) VALUES (NEW.id, NEW.name);
+END;
+
+-- Trigger to update full text search table after artist updates
+CREATE TRIGGER artists_fts_au AFTER UPDATE ON artists
+BEGIN
+    DELETE FROM artists_fts WHERE rowid = OLD.id;
+    INSERT INTO artists_fts (rowid, name) VALUES (NEW.id, NEW.name);
+END;
+
+-- Trigger to update full text search table after artist deletes
+CREATE TRIGGER artists_fts_ad AFTER DELETE ON artists
+BEGIN
+    DELETE FROM artists_fts WHERE rowid = OLD.id;
+END;
@@ -0,0 +1,189 @@
+-- Bulk imports pause the releases_fts sync triggers and rebuild the index once
+-- at the end, since each trigger deletes by the unindexed release_id column and
+-- scans the whole index. ReindexFts clears the flag again, so an interrupted
+-- import is recovered by running reindex-fts.
+CREATE TABLE fts_sync
+(
+    paused INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT INTO fts_sync (paused) VALUES (0);
+
+DROP TRIGGER IF EXISTS releases_ai;
+DROP TRIGGER IF EXISTS releases_au;
+DROP TRIGGER IF EXISTS releases_ad;
+DROP TRIGGER IF EXISTS release_artists_ai;
+DROP TRIGGER IF EXISTS release_artists_au;
+DROP TRIGGER IF EXISTS release_artists_ad;
+DROP TRIGGER IF EXISTS artists_au;
+DROP TRIGGER IF EXISTS artists_ad;
+
+-- Trigger to update full text search table after release inserts
+CREATE TRIGGER releases_ai AFTER INSERT ON releases
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = NEW.id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT DISTINCT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id
+    WHERE releases.id = NEW.id;
+END;
+
+-- Trigger to update full text search table after release updates
+CREATE TRIGGER releases_au AFTER UPDATE ON releases
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (OLD.id, NEW.id);
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT DISTINCT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id
+    WHERE releases.id = NEW.id;
+END;
+
+-- Trigger to update full text search table after release deletes
+CREATE TRIGGER releases_ad AFTER DELETE ON releases
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = OLD.id;
+END;
+
+-- Trigger to update full text search table after release_artist inserts
+CREATE TRIGGER release_artists_ai AFTER INSERT ON release_artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = NEW.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT DISTINCT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id
+    WHERE releases.id = NEW.release_id;
+END;
+
+-- Trigger to update full text search table after release_artist updates
+CREATE TRIGGER release_artists_au AFTER UPDATE ON release_artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT DISTINCT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id
+    WHERE releases.id IN (OLD.release_id, NEW.release_id);
+END;
+
+-- Trigger to update full text search table after release_artist deletes
+CREATE TRIGGER release_artists_ad AFTER DELETE ON release_artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = OLD.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT DISTINCT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id
+    WHERE releases.id = OLD.release_id;
+END;
+
+-- Trigger to update full text search table after artist updates
+CREATE TRIGGER artists_au AFTER UPDATE ON artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (
+        SELECT release_id FROM release_artists WHERE artist_id IN (OLD.id, NEW.id)
+    );
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT DISTINCT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id
+    WHERE releases.id IN (
+        SELECT release_id FROM release_artists WHERE artist_id IN (OLD.id, NEW.id)
+    );
+END;
+
+-- Trigger to update full text search table after artist deletes
+CREATE TRIGGER artists_ad AFTER DELETE ON artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (
+        SELECT release_id FROM release_artists WHERE artist_id = OLD.id
+    );
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT DISTINCT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id
+    WHERE releases.id IN (
+        SELECT release_id FROM release_artists WHERE artist_id = OLD.id
+    );
+END;
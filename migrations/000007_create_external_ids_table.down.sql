@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS artists_external_ids_ad;
+DROP TRIGGER IF EXISTS releases_external_ids_ad;
+
+DROP INDEX IF EXISTS release_artists_artist_id;
+DROP INDEX IF EXISTS release_artists_release_id;
+
+DROP TABLE IF EXISTS external_ids;
@@ -0,0 +1,29 @@
+-- Map releases and artists to their IDs in the catalogs they were imported
+-- from, so re-imports update the rows they created earlier
+CREATE TABLE external_ids
+(
+    source      TEXT    NOT NULL,
+    entity      TEXT    NOT NULL,
+    external_id TEXT    NOT NULL,
+    local_id    INTEGER NOT NULL,
+    PRIMARY KEY (source, entity, external_id)
+);
+
+CREATE INDEX external_ids_local_id ON external_ids (entity, local_id);
+
+-- Imports replace the artists of each release they update
+CREATE INDEX release_artists_release_id ON release_artists (release_id);
+CREATE INDEX release_artists_artist_id ON release_artists (artist_id);
+
+-- Forget the external IDs of deleted rows
+CREATE TRIGGER releases_external_ids_ad AFTER DELETE ON releases
+BEGIN
+    DELETE FROM external_ids
+    WHERE entity = 'release' AND local_id = OLD.id;
+END;
+
+CREATE TRIGGER artists_external_ids_ad AFTER DELETE ON artists
+BEGIN
+    DELETE FROM external_ids
+    WHERE entity = 'artist' AND local_id = OLD.id;
+END;
@@ -0,0 +1,9 @@
+-- The number of lines of a dump committed by an import that hasn't finished,
+-- so running it again resumes after them
+CREATE TABLE import_checkpoints
+(
+    source TEXT    NOT NULL,
+    dump   TEXT    NOT NULL,
+    line   INTEGER NOT NULL,
+    PRIMARY KEY (source, dump)
+);
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS releases_library_files_ad;
+DROP TABLE IF EXISTS library_files;
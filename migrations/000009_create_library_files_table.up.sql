@@ -0,0 +1,22 @@
+-- Audio files found by scanning a music library, with the tags the catalog
+-- is built from, so rescans only read the files that changed
+CREATE TABLE library_files
+(
+    path          TEXT    NOT NULL PRIMARY KEY,
+    size          INTEGER NOT NULL,
+    mtime         INTEGER NOT NULL, -- Unix time in nanoseconds
+    album         TEXT    NOT NULL DEFAULT '',
+    album_artists TEXT    NOT NULL DEFAULT '', -- One name per line
+    artists       TEXT    NOT NULL DEFAULT '', -- One name per line
+    year          INTEGER,
+    release_key   TEXT, -- Files with the same key are one release, NULL without an album
+    release_id    INTEGER
+);
+
+CREATE INDEX library_files_release_key ON library_files (release_key);
+
+-- Unlink the files of deleted releases
+CREATE TRIGGER releases_library_files_ad AFTER DELETE ON releases
+BEGIN
+    UPDATE library_files SET release_id = NULL WHERE release_id = OLD.id;
+END;
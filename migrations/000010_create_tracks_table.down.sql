@@ -0,0 +1,212 @@
+DROP TRIGGER IF EXISTS tracks_ai;
+DROP TRIGGER IF EXISTS tracks_au;
+DROP TRIGGER IF EXISTS tracks_ad;
+DROP TRIGGER IF EXISTS releases_ai;
+DROP TRIGGER IF EXISTS releases_au;
+DROP TRIGGER IF EXISTS releases_ad;
+DROP TRIGGER IF EXISTS release_artists_ai;
+DROP TRIGGER IF EXISTS release_artists_au;
+DROP TRIGGER IF EXISTS release_artists_ad;
+DROP TRIGGER IF EXISTS artists_au;
+DROP TRIGGER IF EXISTS artists_ad;
+DROP TRIGGER IF EXISTS artists_track_artists_ad;
+DROP TRIGGER IF EXISTS tracks_track_artists_ad;
+DROP TRIGGER IF EXISTS releases_tracks_ad;
+
+DROP VIEW IF EXISTS releases_fts_rows;
+DROP TABLE IF EXISTS track_artists;
+DROP TABLE IF EXISTS tracks;
+
+DROP TABLE releases_fts;
+
+CREATE VIRTUAL TABLE releases_fts USING fts5
+(
+    release_id UNINDEXED,
+    release_name,
+    release_year,
+    artist_name,
+    tokenize="trigram"
+);
+
+-- Trigger to update full text search table after release inserts
+CREATE TRIGGER releases_ai AFTER INSERT ON releases
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = NEW.id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT DISTINCT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id
+    WHERE releases.id = NEW.id;
+END;
+
+-- Trigger to update full text search table after release updates
+CREATE TRIGGER releases_au AFTER UPDATE ON releases
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (OLD.id, NEW.id);
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT DISTINCT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id
+    WHERE releases.id = NEW.id;
+END;
+
+-- Trigger to update full text search table after release deletes
+CREATE TRIGGER releases_ad AFTER DELETE ON releases
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = OLD.id;
+END;
+
+-- Trigger to update full text search table after release_artist inserts
+CREATE TRIGGER release_artists_ai AFTER INSERT ON release_artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = NEW.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT DISTINCT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id
+    WHERE releases.id = NEW.release_id;
+END;
+
+-- Trigger to update full text search table after release_artist updates
+CREATE TRIGGER release_artists_au AFTER UPDATE ON release_artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT DISTINCT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id
+    WHERE releases.id IN (OLD.release_id, NEW.release_id);
+END;
+
+-- Trigger to update full text search table after release_artist deletes
+CREATE TRIGGER release_artists_ad AFTER DELETE ON release_artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = OLD.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT DISTINCT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id
+    WHERE releases.id = OLD.release_id;
+END;
+
+-- Trigger to update full text search table after artist updates
+CREATE TRIGGER artists_au AFTER UPDATE ON artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (
+        SELECT release_id FROM release_artists WHERE artist_id IN (OLD.id, NEW.id)
+    );
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT DISTINCT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id
+    WHERE releases.id IN (
+        SELECT release_id FROM release_artists WHERE artist_id IN (OLD.id, NEW.id)
+    );
+END;
+
+-- Trigger to update full text search table after artist deletes
+CREATE TRIGGER artists_ad AFTER DELETE ON artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (
+        SELECT release_id FROM release_artists WHERE artist_id = OLD.id
+    );
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+    SELECT DISTINCT
+        releases.id AS release_id,
+        releases.name AS release_name,
+        releases.year AS release_year,
+        artists.name AS artist_name
+    FROM
+        release_artists
+            JOIN
+        artists ON release_artists.artist_id = artists.id
+            JOIN
+        releases ON release_artists.release_id = releases.id
+    WHERE releases.id IN (
+        SELECT release_id FROM release_artists WHERE artist_id = OLD.id
+    );
+END;
+
+INSERT INTO releases_fts (release_id, release_name, release_year, artist_name)
+SELECT DISTINCT
+    releases.id AS release_id,
+    releases.name AS release_name,
+    releases.year AS release_year,
+    artists.name AS artist_name
+FROM
+    release_artists
+        JOIN
+    artists ON release_artists.artist_id = artists.id
+        JOIN
+    releases ON release_artists.release_id = releases.id;
@@ -0,0 +1,218 @@
+-- Tracklists of releases. Disc and position order the tracks, duration is
+-- in seconds and NULL when unknown.
+CREATE TABLE tracks
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    release_id INTEGER NOT NULL REFERENCES releases (id),
+    disc       INTEGER NOT NULL DEFAULT 1,
+    position   INTEGER NOT NULL,
+    title      TEXT    NOT NULL,
+    duration   INTEGER,
+    UNIQUE (release_id, disc, position)
+);
+
+-- Artists credited on a track, when they differ from the release's artists
+CREATE TABLE track_artists
+(
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    track_id  INTEGER NOT NULL REFERENCES tracks (id),
+    artist_id INTEGER NOT NULL REFERENCES artists (id)
+);
+
+CREATE INDEX track_artists_track_id ON track_artists (track_id);
+CREATE INDEX track_artists_artist_id ON track_artists (artist_id);
+
+-- Tracks go with their release, and credits with their track or artist
+CREATE TRIGGER releases_tracks_ad AFTER DELETE ON releases
+BEGIN
+    DELETE FROM tracks WHERE release_id = OLD.id;
+END;
+
+CREATE TRIGGER tracks_track_artists_ad AFTER DELETE ON tracks
+BEGIN
+    DELETE FROM track_artists WHERE track_id = OLD.id;
+END;
+
+CREATE TRIGGER artists_track_artists_ad AFTER DELETE ON artists
+BEGIN
+    DELETE FROM track_artists WHERE artist_id = OLD.id;
+END;
+
+-- The rows of releases_fts, one per release/artist pair with the titles of
+-- every track of the release, so searching for a song finds its release. The
+-- sync triggers and ReindexFts insert from it.
+CREATE VIEW releases_fts_rows AS
+SELECT DISTINCT
+    releases.id AS release_id,
+    releases.name AS release_name,
+    releases.year AS release_year,
+    artists.name AS artist_name,
+    (SELECT group_concat(tracks.title, char(10)) FROM tracks WHERE tracks.release_id = releases.id) AS track_titles
+FROM
+    release_artists
+        JOIN
+    artists ON release_artists.artist_id = artists.id
+        JOIN
+    releases ON release_artists.release_id = releases.id;
+
+-- FTS5 tables can't add columns, so releases_fts is recreated with
+-- track_titles and the sync triggers with it
+DROP TRIGGER IF EXISTS releases_ai;
+DROP TRIGGER IF EXISTS releases_au;
+DROP TRIGGER IF EXISTS releases_ad;
+DROP TRIGGER IF EXISTS release_artists_ai;
+DROP TRIGGER IF EXISTS release_artists_au;
+DROP TRIGGER IF EXISTS release_artists_ad;
+DROP TRIGGER IF EXISTS artists_au;
+DROP TRIGGER IF EXISTS artists_ad;
+
+DROP TABLE releases_fts;
+
+CREATE VIRTUAL TABLE releases_fts USING fts5
+(
+    release_id UNINDEXED,
+    release_name,
+    release_year,
+    artist_name,
+    track_titles,
+    tokenize="trigram"
+);
+
+-- Trigger to update full text search table after release inserts
+CREATE TRIGGER releases_ai AFTER INSERT ON releases
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = NEW.id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id = NEW.id;
+END;
+
+-- Trigger to update full text search table after release updates
+CREATE TRIGGER releases_au AFTER UPDATE ON releases
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (OLD.id, NEW.id);
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id IN (OLD.id, NEW.id);
+END;
+
+-- Trigger to update full text search table after release deletes
+CREATE TRIGGER releases_ad AFTER DELETE ON releases
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = OLD.id;
+END;
+
+-- Trigger to update full text search table after release_artist inserts
+CREATE TRIGGER release_artists_ai AFTER INSERT ON release_artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = NEW.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id = NEW.release_id;
+END;
+
+-- Trigger to update full text search table after release_artist updates
+CREATE TRIGGER release_artists_au AFTER UPDATE ON release_artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+END;
+
+-- Trigger to update full text search table after release_artist deletes
+CREATE TRIGGER release_artists_ad AFTER DELETE ON release_artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = OLD.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id = OLD.release_id;
+END;
+
+-- Trigger to update full text search table after artist updates
+CREATE TRIGGER artists_au AFTER UPDATE ON artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (
+        SELECT release_id FROM release_artists WHERE artist_id IN (OLD.id, NEW.id)
+    );
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id IN (
+        SELECT release_id FROM release_artists WHERE artist_id IN (OLD.id, NEW.id)
+    );
+END;
+
+-- Trigger to update full text search table after artist deletes
+CREATE TRIGGER artists_ad AFTER DELETE ON artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (
+        SELECT release_id FROM release_artists WHERE artist_id = OLD.id
+    );
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id IN (
+        SELECT release_id FROM release_artists WHERE artist_id = OLD.id
+    );
+END;
+
+-- Trigger to update full text search table after track inserts
+CREATE TRIGGER tracks_ai AFTER INSERT ON tracks
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = NEW.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id = NEW.release_id;
+END;
+
+-- Trigger to update full text search table after track updates
+CREATE TRIGGER tracks_au AFTER UPDATE ON tracks
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+END;
+
+-- Trigger to update full text search table after track deletes
+CREATE TRIGGER tracks_ad AFTER DELETE ON tracks
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = OLD.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id = OLD.release_id;
+END;
+
+INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles)
+SELECT * FROM releases_fts_rows;
@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS releases_release_genres_ad;
+DROP TRIGGER IF EXISTS genres_release_genres_ad;
+DROP TRIGGER IF EXISTS releases_release_tags_ad;
+DROP TRIGGER IF EXISTS tags_release_tags_ad;
+DROP TRIGGER IF EXISTS genres_styles_ad;
+
+DROP TABLE IF EXISTS release_tags;
+DROP TABLE IF EXISTS release_genres;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS genres;
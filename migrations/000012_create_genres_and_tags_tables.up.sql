@@ -0,0 +1,66 @@
+-- Genres are a vocabulary kept up on the admin pages. A genre with a parent is
+-- a style of that genre, like Prog Rock under Rock. Styles are one level deep.
+CREATE TABLE genres
+(
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    name      TEXT    NOT NULL,
+    slug      TEXT    NOT NULL UNIQUE,
+    parent_id INTEGER REFERENCES genres (id)
+);
+
+CREATE INDEX genres_parent_id ON genres (parent_id);
+
+-- Tags are free-form and created when they're first added to a release
+CREATE TABLE tags
+(
+    id   INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT    NOT NULL,
+    slug TEXT    NOT NULL UNIQUE
+);
+
+CREATE TABLE release_genres
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    release_id INTEGER NOT NULL REFERENCES releases (id),
+    genre_id   INTEGER NOT NULL REFERENCES genres (id),
+    UNIQUE (release_id, genre_id)
+);
+
+CREATE INDEX release_genres_genre_id ON release_genres (genre_id);
+
+CREATE TABLE release_tags
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    release_id INTEGER NOT NULL REFERENCES releases (id),
+    tag_id     INTEGER NOT NULL REFERENCES tags (id),
+    UNIQUE (release_id, tag_id)
+);
+
+CREATE INDEX release_tags_tag_id ON release_tags (tag_id);
+
+-- Genre and tag links go with their release, genre or tag
+CREATE TRIGGER releases_release_genres_ad AFTER DELETE ON releases
+BEGIN
+    DELETE FROM release_genres WHERE release_id = OLD.id;
+END;
+
+CREATE TRIGGER genres_release_genres_ad AFTER DELETE ON genres
+BEGIN
+    DELETE FROM release_genres WHERE genre_id = OLD.id;
+END;
+
+CREATE TRIGGER releases_release_tags_ad AFTER DELETE ON releases
+BEGIN
+    DELETE FROM release_tags WHERE release_id = OLD.id;
+END;
+
+CREATE TRIGGER tags_release_tags_ad AFTER DELETE ON tags
+BEGIN
+    DELETE FROM release_tags WHERE tag_id = OLD.id;
+END;
+
+-- Styles of a deleted genre become genres of their own
+CREATE TRIGGER genres_styles_ad AFTER DELETE ON genres
+BEGIN
+    UPDATE genres SET parent_id = NULL WHERE parent_id = OLD.id;
+END;
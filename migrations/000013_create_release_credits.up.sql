@@ -0,0 +1,298 @@
+-- release_artists becomes a list of artist credits. Each credit has a role, a
+-- position in the credit and the phrase joining it to the next credit, so
+-- "Queen & David Bowie" or "Eminem feat. Rihanna" can be shown as credited.
+-- The table is rebuilt, which also fixes the foreign keys swapped in 000002.
+-- The releases_fts triggers and view read release_artists, so they're dropped
+-- first and recreated with the credit of every release.
+DROP TRIGGER IF EXISTS releases_ai;
+DROP TRIGGER IF EXISTS releases_au;
+DROP TRIGGER IF EXISTS releases_ad;
+DROP TRIGGER IF EXISTS release_artists_ai;
+DROP TRIGGER IF EXISTS release_artists_au;
+DROP TRIGGER IF EXISTS release_artists_ad;
+DROP TRIGGER IF EXISTS artists_au;
+DROP TRIGGER IF EXISTS artists_ad;
+DROP TRIGGER IF EXISTS tracks_ai;
+DROP TRIGGER IF EXISTS tracks_au;
+DROP TRIGGER IF EXISTS tracks_ad;
+DROP TRIGGER IF EXISTS release_labels_ai;
+DROP TRIGGER IF EXISTS release_labels_au;
+DROP TRIGGER IF EXISTS release_labels_ad;
+DROP TRIGGER IF EXISTS labels_au;
+
+DROP TABLE releases_fts;
+DROP VIEW releases_fts_rows;
+
+-- role is what the artist did on the release. Main and featuring credits make
+-- up the credit shown with the release, producers and remixers are listed
+-- separately. join_phrase is the text between this credit and the next, like
+-- "&", "feat." or "vs.", empty for a comma.
+CREATE TABLE release_credits
+(
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    release_id  INTEGER NOT NULL REFERENCES releases (id),
+    artist_id   INTEGER NOT NULL REFERENCES artists (id),
+    role        TEXT    NOT NULL DEFAULT 'main' CHECK (role IN ('main', 'featuring', 'producer', 'remixer')),
+    position    INTEGER NOT NULL DEFAULT 0,
+    join_phrase TEXT    NOT NULL DEFAULT '',
+    UNIQUE (release_id, artist_id, role)
+);
+
+-- Existing artists become main credits in the order they were added
+INSERT INTO release_credits (id, release_id, artist_id, role, position)
+SELECT
+    MIN(id),
+    release_id,
+    artist_id,
+    'main',
+    row_number() OVER (PARTITION BY release_id ORDER BY MIN(id))
+FROM release_artists
+WHERE release_id IS NOT NULL
+  AND artist_id IS NOT NULL
+GROUP BY release_id, artist_id;
+
+DROP TABLE release_artists;
+ALTER TABLE release_credits RENAME TO release_artists;
+
+CREATE INDEX release_artists_release_id ON release_artists (release_id, position);
+CREATE INDEX release_artists_artist_id ON release_artists (artist_id);
+
+-- The rows of releases_fts as in 000011, with the credit of the release as
+-- it's shown: its main and featuring credits in order with their join phrases.
+-- Without a join phrase, credits are joined with a comma, or with "feat."
+-- before a featuring credit.
+CREATE VIEW releases_fts_rows AS
+SELECT DISTINCT
+    releases.id AS release_id,
+    releases.name AS release_name,
+    releases.year AS release_year,
+    artists.name AS artist_name,
+    (SELECT group_concat(tracks.title, char(10)) FROM tracks WHERE tracks.release_id = releases.id) AS track_titles,
+    (SELECT group_concat(labels.name, char(10)) FROM labels WHERE labels.id IN (
+        SELECT release_labels.label_id FROM release_labels WHERE release_labels.release_id = releases.id
+    )) AS label_names,
+    COALESCE((SELECT group_concat(credited, '' ORDER BY position, id) FROM (
+        SELECT
+            credits.id,
+            credits.position,
+            credited_artists.name || CASE
+                WHEN lead(credits.id) OVER credit_order IS NULL THEN ''
+                WHEN credits.join_phrase = '' AND lead(credits.role) OVER credit_order = 'featuring' THEN ' feat. '
+                WHEN credits.join_phrase IN ('', ',') THEN ', '
+                ELSE ' ' || credits.join_phrase || ' '
+            END AS credited
+        FROM release_artists AS credits
+        JOIN artists AS credited_artists ON credited_artists.id = credits.artist_id
+        WHERE credits.release_id = releases.id
+          AND credits.role IN ('main', 'featuring')
+        WINDOW credit_order AS (ORDER BY credits.position, credits.id)
+    )), '') AS artist_credit
+FROM
+    release_artists
+        JOIN
+    artists ON release_artists.artist_id = artists.id
+        JOIN
+    releases ON release_artists.release_id = releases.id;
+
+CREATE VIRTUAL TABLE releases_fts USING fts5
+(
+    release_id UNINDEXED,
+    release_name,
+    release_year,
+    artist_name,
+    track_titles,
+    label_names,
+    artist_credit,
+    tokenize="trigram"
+);
+
+-- Trigger to update full text search table after release inserts
+CREATE TRIGGER releases_ai AFTER INSERT ON releases
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = NEW.id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id = NEW.id;
+END;
+
+-- Trigger to update full text search table after release updates
+CREATE TRIGGER releases_au AFTER UPDATE ON releases
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (OLD.id, NEW.id);
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id IN (OLD.id, NEW.id);
+END;
+
+-- Trigger to update full text search table after release deletes
+CREATE TRIGGER releases_ad AFTER DELETE ON releases
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = OLD.id;
+END;
+
+-- Trigger to update full text search table after release_artist inserts
+CREATE TRIGGER release_artists_ai AFTER INSERT ON release_artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = NEW.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id = NEW.release_id;
+END;
+
+-- Trigger to update full text search table after release_artist updates
+CREATE TRIGGER release_artists_au AFTER UPDATE ON release_artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+END;
+
+-- Trigger to update full text search table after release_artist deletes
+CREATE TRIGGER release_artists_ad AFTER DELETE ON release_artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = OLD.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id = OLD.release_id;
+END;
+
+-- Trigger to update full text search table after artist updates
+CREATE TRIGGER artists_au AFTER UPDATE ON artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (
+        SELECT release_id FROM release_artists WHERE artist_id IN (OLD.id, NEW.id)
+    );
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id IN (
+        SELECT release_id FROM release_artists WHERE artist_id IN (OLD.id, NEW.id)
+    );
+END;
+
+-- Trigger to update full text search table after artist deletes
+CREATE TRIGGER artists_ad AFTER DELETE ON artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (
+        SELECT release_id FROM release_artists WHERE artist_id = OLD.id
+    );
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id IN (
+        SELECT release_id FROM release_artists WHERE artist_id = OLD.id
+    );
+END;
+
+-- Trigger to update full text search table after track inserts
+CREATE TRIGGER tracks_ai AFTER INSERT ON tracks
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = NEW.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id = NEW.release_id;
+END;
+
+-- Trigger to update full text search table after track updates
+CREATE TRIGGER tracks_au AFTER UPDATE ON tracks
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+END;
+
+-- Trigger to update full text search table after track deletes
+CREATE TRIGGER tracks_ad AFTER DELETE ON tracks
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = OLD.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id = OLD.release_id;
+END;
+
+-- Trigger to update full text search table after release_label inserts
+CREATE TRIGGER release_labels_ai AFTER INSERT ON release_labels
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = NEW.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id = NEW.release_id;
+END;
+
+-- Trigger to update full text search table after release_label updates
+CREATE TRIGGER release_labels_au AFTER UPDATE ON release_labels
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+END;
+
+-- Trigger to update full text search table after release_label deletes
+CREATE TRIGGER release_labels_ad AFTER DELETE ON release_labels
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = OLD.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id = OLD.release_id;
+END;
+
+-- Trigger to update full text search table after label updates
+CREATE TRIGGER labels_au AFTER UPDATE ON labels
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (
+        SELECT release_id FROM release_labels WHERE label_id IN (OLD.id, NEW.id)
+    );
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id IN (
+        SELECT release_id FROM release_labels WHERE label_id IN (OLD.id, NEW.id)
+    );
+END;
+
+INSERT INTO releases_fts (release_id, release_name, release_year, artist_name, track_titles, label_names, artist_credit)
+SELECT * FROM releases_fts_rows;
@@ -0,0 +1,267 @@
+-- releases_fts gets one row per release instead of one per release/artist
+-- pair, so a release with several artists is one search result and counts
+-- once. The names of every credited artist go in artist_names, one per line.
+DROP TRIGGER IF EXISTS releases_ai;
+DROP TRIGGER IF EXISTS releases_au;
+DROP TRIGGER IF EXISTS releases_ad;
+DROP TRIGGER IF EXISTS release_artists_ai;
+DROP TRIGGER IF EXISTS release_artists_au;
+DROP TRIGGER IF EXISTS release_artists_ad;
+DROP TRIGGER IF EXISTS artists_au;
+DROP TRIGGER IF EXISTS artists_ad;
+DROP TRIGGER IF EXISTS tracks_ai;
+DROP TRIGGER IF EXISTS tracks_au;
+DROP TRIGGER IF EXISTS tracks_ad;
+DROP TRIGGER IF EXISTS release_labels_ai;
+DROP TRIGGER IF EXISTS release_labels_au;
+DROP TRIGGER IF EXISTS release_labels_ad;
+DROP TRIGGER IF EXISTS labels_au;
+
+DROP TABLE releases_fts;
+DROP VIEW releases_fts_rows;
+
+-- The rows of releases_fts as in 000013, grouped by release. Artists credited
+-- with several roles are named once, in credit order. Releases without
+-- artists are still left out.
+CREATE VIEW releases_fts_rows AS
+SELECT
+    releases.id AS release_id,
+    releases.name AS release_name,
+    releases.year AS release_year,
+    (SELECT group_concat(name, char(10) ORDER BY position, id) FROM (
+        SELECT artists.name, MIN(release_artists.position) AS position, MIN(release_artists.id) AS id
+        FROM release_artists
+        JOIN artists ON artists.id = release_artists.artist_id
+        WHERE release_artists.release_id = releases.id
+        GROUP BY artists.id
+    )) AS artist_names,
+    (SELECT group_concat(tracks.title, char(10)) FROM tracks WHERE tracks.release_id = releases.id) AS track_titles,
+    (SELECT group_concat(labels.name, char(10)) FROM labels WHERE labels.id IN (
+        SELECT release_labels.label_id FROM release_labels WHERE release_labels.release_id = releases.id
+    )) AS label_names,
+    COALESCE((SELECT group_concat(credited, '' ORDER BY position, id) FROM (
+        SELECT
+            credits.id,
+            credits.position,
+            credited_artists.name || CASE
+                WHEN lead(credits.id) OVER credit_order IS NULL THEN ''
+                WHEN credits.join_phrase = '' AND lead(credits.role) OVER credit_order = 'featuring' THEN ' feat. '
+                WHEN credits.join_phrase IN ('', ',') THEN ', '
+                ELSE ' ' || credits.join_phrase || ' '
+            END AS credited
+        FROM release_artists AS credits
+        JOIN artists AS credited_artists ON credited_artists.id = credits.artist_id
+        WHERE credits.release_id = releases.id
+          AND credits.role IN ('main', 'featuring')
+        WINDOW credit_order AS (ORDER BY credits.position, credits.id)
+    )), '') AS artist_credit
+FROM releases
+WHERE EXISTS (
+    SELECT 1
+    FROM release_artists
+    JOIN artists ON artists.id = release_artists.artist_id
+    WHERE release_artists.release_id = releases.id
+);
+
+CREATE VIRTUAL TABLE releases_fts USING fts5
+(
+    release_id UNINDEXED,
+    release_name,
+    release_year,
+    artist_names,
+    track_titles,
+    label_names,
+    artist_credit,
+    tokenize="trigram"
+);
+
+-- Trigger to update full text search table after release inserts
+CREATE TRIGGER releases_ai AFTER INSERT ON releases
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = NEW.id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id = NEW.id;
+END;
+
+-- Trigger to update full text search table after release updates
+CREATE TRIGGER releases_au AFTER UPDATE ON releases
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (OLD.id, NEW.id);
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id IN (OLD.id, NEW.id);
+END;
+
+-- Trigger to update full text search table after release deletes
+CREATE TRIGGER releases_ad AFTER DELETE ON releases
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = OLD.id;
+END;
+
+-- Trigger to update full text search table after release_artist inserts
+CREATE TRIGGER release_artists_ai AFTER INSERT ON release_artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = NEW.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id = NEW.release_id;
+END;
+
+-- Trigger to update full text search table after release_artist updates
+CREATE TRIGGER release_artists_au AFTER UPDATE ON release_artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+END;
+
+-- Trigger to update full text search table after release_artist deletes
+CREATE TRIGGER release_artists_ad AFTER DELETE ON release_artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = OLD.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id = OLD.release_id;
+END;
+
+-- Trigger to update full text search table after artist updates
+CREATE TRIGGER artists_au AFTER UPDATE ON artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (
+        SELECT release_id FROM release_artists WHERE artist_id IN (OLD.id, NEW.id)
+    );
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id IN (
+        SELECT release_id FROM release_artists WHERE artist_id IN (OLD.id, NEW.id)
+    );
+END;
+
+-- Trigger to update full text search table after artist deletes
+CREATE TRIGGER artists_ad AFTER DELETE ON artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (
+        SELECT release_id FROM release_artists WHERE artist_id = OLD.id
+    );
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id IN (
+        SELECT release_id FROM release_artists WHERE artist_id = OLD.id
+    );
+END;
+
+-- Trigger to update full text search table after track inserts
+CREATE TRIGGER tracks_ai AFTER INSERT ON tracks
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = NEW.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id = NEW.release_id;
+END;
+
+-- Trigger to update full text search table after track updates
+CREATE TRIGGER tracks_au AFTER UPDATE ON tracks
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+END;
+
+-- Trigger to update full text search table after track deletes
+CREATE TRIGGER tracks_ad AFTER DELETE ON tracks
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = OLD.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id = OLD.release_id;
+END;
+
+-- Trigger to update full text search table after release_label inserts
+CREATE TRIGGER release_labels_ai AFTER INSERT ON release_labels
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = NEW.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id = NEW.release_id;
+END;
+
+-- Trigger to update full text search table after release_label updates
+CREATE TRIGGER release_labels_au AFTER UPDATE ON release_labels
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+END;
+
+-- Trigger to update full text search table after release_label deletes
+CREATE TRIGGER release_labels_ad AFTER DELETE ON release_labels
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id = OLD.release_id;
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id = OLD.release_id;
+END;
+
+-- Trigger to update full text search table after label updates
+CREATE TRIGGER labels_au AFTER UPDATE ON labels
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE release_id IN (
+        SELECT release_id FROM release_labels WHERE label_id IN (OLD.id, NEW.id)
+    );
+
+    INSERT INTO releases_fts (release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT * FROM releases_fts_rows
+    WHERE release_id IN (
+        SELECT release_id FROM release_labels WHERE label_id IN (OLD.id, NEW.id)
+    );
+END;
+
+INSERT INTO releases_fts (release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+SELECT * FROM releases_fts_rows;
@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS releases_release_formats_ad;
+DROP TRIGGER IF EXISTS masters_releases_ad;
+
+DROP TABLE IF EXISTS release_formats;
+
+DROP INDEX IF EXISTS releases_master_id;
+ALTER TABLE releases DROP COLUMN country;
+ALTER TABLE releases DROP COLUMN master_id;
+
+DROP TABLE IF EXISTS masters;
@@ -0,0 +1,43 @@
+-- A master groups the versions of a release, like an album's original
+-- pressing, its remasters and its pressings in other countries. Each version
+-- is a release with its own year, country and formats.
+CREATE TABLE masters
+(
+    id   INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT    NOT NULL
+);
+
+-- master_id is NULL for releases that aren't a version of a master. country
+-- is free text like "Japan", empty when unknown.
+ALTER TABLE releases ADD COLUMN master_id INTEGER REFERENCES masters (id);
+ALTER TABLE releases ADD COLUMN country TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX releases_master_id ON releases (master_id);
+
+-- The formats a release came out on, like 2 x vinyl with the descriptors LP
+-- and Album plus a CD. A release can have several formats, kept in order.
+-- descriptors are comma separated, like "LP, Album, Limited Edition".
+CREATE TABLE release_formats
+(
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    release_id  INTEGER NOT NULL REFERENCES releases (id),
+    name        TEXT    NOT NULL CHECK (name IN ('vinyl', 'cd', 'cassette', 'digital')),
+    quantity    INTEGER NOT NULL DEFAULT 1 CHECK (quantity > 0),
+    descriptors TEXT    NOT NULL DEFAULT '',
+    position    INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX release_formats_release_id ON release_formats (release_id, position);
+CREATE INDEX release_formats_name ON release_formats (name);
+
+-- Versions of a deleted master become releases of their own
+CREATE TRIGGER masters_releases_ad AFTER DELETE ON masters
+BEGIN
+    UPDATE releases SET master_id = NULL WHERE master_id = OLD.id;
+END;
+
+-- Formats go with their release
+CREATE TRIGGER releases_release_formats_ad AFTER DELETE ON releases
+BEGIN
+    DELETE FROM release_formats WHERE release_id = OLD.id;
+END;
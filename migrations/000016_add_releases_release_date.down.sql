@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS releases_country;
+ALTER TABLE releases DROP COLUMN release_date;
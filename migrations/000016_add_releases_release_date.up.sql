@@ -0,0 +1,6 @@
+-- release_date is when the release came out as precisely as it's known:
+-- 1975, 1975-11 or 1975-11-21, empty when only the year is known. Its year
+-- is always the release's year.
+ALTER TABLE releases ADD COLUMN release_date TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX releases_country ON releases (country COLLATE NOCASE);
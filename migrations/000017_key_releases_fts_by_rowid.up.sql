@@ -0,0 +1,212 @@
+-- The sync triggers of 000014 found the rows of releases_fts to replace by
+-- release_id, which FTS5 can't index, so every change scanned the whole
+-- table. Rows now have the id of their release as their rowid, and are
+-- deleted by it.
+DROP TRIGGER IF EXISTS releases_ai;
+DROP TRIGGER IF EXISTS releases_au;
+DROP TRIGGER IF EXISTS releases_ad;
+DROP TRIGGER IF EXISTS release_artists_ai;
+DROP TRIGGER IF EXISTS release_artists_au;
+DROP TRIGGER IF EXISTS release_artists_ad;
+DROP TRIGGER IF EXISTS artists_au;
+DROP TRIGGER IF EXISTS artists_ad;
+DROP TRIGGER IF EXISTS tracks_ai;
+DROP TRIGGER IF EXISTS tracks_au;
+DROP TRIGGER IF EXISTS tracks_ad;
+DROP TRIGGER IF EXISTS release_labels_ai;
+DROP TRIGGER IF EXISTS release_labels_au;
+DROP TRIGGER IF EXISTS release_labels_ad;
+DROP TRIGGER IF EXISTS labels_au;
+
+-- Trigger to update full text search table after release inserts
+CREATE TRIGGER releases_ai AFTER INSERT ON releases
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE rowid = NEW.id;
+
+    INSERT INTO releases_fts (rowid, release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT release_id, * FROM releases_fts_rows
+    WHERE release_id = NEW.id;
+END;
+
+-- Trigger to update full text search table after release updates
+CREATE TRIGGER releases_au AFTER UPDATE ON releases
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE rowid IN (OLD.id, NEW.id);
+
+    INSERT INTO releases_fts (rowid, release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT release_id, * FROM releases_fts_rows
+    WHERE release_id IN (OLD.id, NEW.id);
+END;
+
+-- Trigger to update full text search table after release deletes
+CREATE TRIGGER releases_ad AFTER DELETE ON releases
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE rowid = OLD.id;
+END;
+
+-- Trigger to update full text search table after release_artist inserts
+CREATE TRIGGER release_artists_ai AFTER INSERT ON release_artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE rowid = NEW.release_id;
+
+    INSERT INTO releases_fts (rowid, release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT release_id, * FROM releases_fts_rows
+    WHERE release_id = NEW.release_id;
+END;
+
+-- Trigger to update full text search table after release_artist updates
+CREATE TRIGGER release_artists_au AFTER UPDATE ON release_artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE rowid IN (OLD.release_id, NEW.release_id);
+
+    INSERT INTO releases_fts (rowid, release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT release_id, * FROM releases_fts_rows
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+END;
+
+-- Trigger to update full text search table after release_artist deletes
+CREATE TRIGGER release_artists_ad AFTER DELETE ON release_artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE rowid = OLD.release_id;
+
+    INSERT INTO releases_fts (rowid, release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT release_id, * FROM releases_fts_rows
+    WHERE release_id = OLD.release_id;
+END;
+
+-- Trigger to update full text search table after artist updates
+CREATE TRIGGER artists_au AFTER UPDATE ON artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE rowid IN (
+        SELECT release_id FROM release_artists WHERE artist_id IN (OLD.id, NEW.id)
+    );
+
+    INSERT INTO releases_fts (rowid, release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT release_id, * FROM releases_fts_rows
+    WHERE release_id IN (
+        SELECT release_id FROM release_artists WHERE artist_id IN (OLD.id, NEW.id)
+    );
+END;
+
+-- Trigger to update full text search table after artist deletes
+CREATE TRIGGER artists_ad AFTER DELETE ON artists
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE rowid IN (
+        SELECT release_id FROM release_artists WHERE artist_id = OLD.id
+    );
+
+    INSERT INTO releases_fts (rowid, release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT release_id, * FROM releases_fts_rows
+    WHERE release_id IN (
+        SELECT release_id FROM release_artists WHERE artist_id = OLD.id
+    );
+END;
+
+-- Trigger to update full text search table after track inserts
+CREATE TRIGGER tracks_ai AFTER INSERT ON tracks
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE rowid = NEW.release_id;
+
+    INSERT INTO releases_fts (rowid, release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT release_id, * FROM releases_fts_rows
+    WHERE release_id = NEW.release_id;
+END;
+
+-- Trigger to update full text search table after track updates
+CREATE TRIGGER tracks_au AFTER UPDATE ON tracks
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE rowid IN (OLD.release_id, NEW.release_id);
+
+    INSERT INTO releases_fts (rowid, release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT release_id, * FROM releases_fts_rows
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+END;
+
+-- Trigger to update full text search table after track deletes
+CREATE TRIGGER tracks_ad AFTER DELETE ON tracks
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE rowid = OLD.release_id;
+
+    INSERT INTO releases_fts (rowid, release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT release_id, * FROM releases_fts_rows
+    WHERE release_id = OLD.release_id;
+END;
+
+-- Trigger to update full text search table after release_label inserts
+CREATE TRIGGER release_labels_ai AFTER INSERT ON release_labels
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE rowid = NEW.release_id;
+
+    INSERT INTO releases_fts (rowid, release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT release_id, * FROM releases_fts_rows
+    WHERE release_id = NEW.release_id;
+END;
+
+-- Trigger to update full text search table after release_label updates
+CREATE TRIGGER release_labels_au AFTER UPDATE ON release_labels
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE rowid IN (OLD.release_id, NEW.release_id);
+
+    INSERT INTO releases_fts (rowid, release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT release_id, * FROM releases_fts_rows
+    WHERE release_id IN (OLD.release_id, NEW.release_id);
+END;
+
+-- Trigger to update full text search table after release_label deletes
+CREATE TRIGGER release_labels_ad AFTER DELETE ON release_labels
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE rowid = OLD.release_id;
+
+    INSERT INTO releases_fts (rowid, release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT release_id, * FROM releases_fts_rows
+    WHERE release_id = OLD.release_id;
+END;
+
+-- Trigger to update full text search table after label updates
+CREATE TRIGGER labels_au AFTER UPDATE ON labels
+WHEN (SELECT paused FROM fts_sync) = 0
+BEGIN
+    DELETE FROM releases_fts
+    WHERE rowid IN (
+        SELECT release_id FROM release_labels WHERE label_id IN (OLD.id, NEW.id)
+    );
+
+    INSERT INTO releases_fts (rowid, release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+    SELECT release_id, * FROM releases_fts_rows
+    WHERE release_id IN (
+        SELECT release_id FROM release_labels WHERE label_id IN (OLD.id, NEW.id)
+    );
+END;
+
+DELETE FROM releases_fts;
+
+INSERT INTO releases_fts (rowid, release_id, release_name, release_year, artist_names, track_titles, label_names, artist_credit)
+SELECT release_id, * FROM releases_fts_rows;
@@ -0,0 +1,45 @@
+-- Back to indexing only releases with artists, as in 000014
+DROP VIEW releases_fts_rows;
+
+CREATE VIEW releases_fts_rows AS
+SELECT
+    releases.id AS release_id,
+    releases.name AS release_name,
+    releases.year AS release_year,
+    (SELECT group_concat(name, char(10) ORDER BY position, id) FROM (
+        SELECT artists.name, MIN(release_artists.position) AS position, MIN(release_artists.id) AS id
+        FROM release_artists
+        JOIN artists ON artists.id = release_artists.artist_id
+        WHERE release_artists.release_id = releases.id
+        GROUP BY artists.id
+    )) AS artist_names,
+    (SELECT group_concat(tracks.title, char(10)) FROM tracks WHERE tracks.release_id = releases.id) AS track_titles,
+    (SELECT group_concat(labels.name, char(10)) FROM labels WHERE labels.id IN (
+        SELECT release_labels.label_id FROM release_labels WHERE release_labels.release_id = releases.id
+    )) AS label_names,
+    COALESCE((SELECT group_concat(credited, '' ORDER BY position, id) FROM (
+        SELECT
+            credits.id,
+            credits.position,
+            credited_artists.name || CASE
+                WHEN lead(credits.id) OVER credit_order IS NULL THEN ''
+                WHEN credits.join_phrase = '' AND lead(credits.role) OVER credit_order = 'featuring' THEN ' feat. '
+                WHEN credits.join_phrase IN ('', ',') THEN ', '
+                ELSE ' ' || credits.join_phrase || ' '
+            END AS credited
+        FROM release_artists AS credits
+        JOIN artists AS credited_artists ON credited_artists.id = credits.artist_id
+        WHERE credits.release_id = releases.id
+          AND credits.role IN ('main', 'featuring')
+        WINDOW credit_order AS (ORDER BY credits.position, credits.id)
+    )), '') AS artist_credit
+FROM releases
+WHERE EXISTS (
+    SELECT 1
+    FROM release_artists
+    JOIN artists ON artists.id = release_artists.artist_id
+    WHERE release_artists.release_id = releases.id
+);
+
+DELETE FROM releases_fts
+WHERE rowid NOT IN (SELECT release_id FROM releases_fts_rows);
@@ -0,0 +1 @@
+ALTER TABLE library_files DROP COLUMN release_date;
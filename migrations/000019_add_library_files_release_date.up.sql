@@ -0,0 +1,6 @@
+-- The date of a file's DATE tag, normalized like releases.release_date, so
+-- scanned releases get a release date and not just a year. Files scanned
+-- before this are read again by the next scan to fill it in.
+ALTER TABLE library_files ADD COLUMN release_date TEXT NOT NULL DEFAULT '';
+
+UPDATE library_files SET mtime = 0;
@@ -1,2 +1,2 @@
-go test -tags "sqlite_fts5" -v -cover -coverprofile=coverage.out ./internal/... && \
+go test -tags "sqlite_fts5" -v -cover -coverprofile=coverage.out ./... && \
 go tool cover -html=coverage.out -o coverage.html